@@ -0,0 +1,8 @@
+// Package telemetryv1 is the gRPC ingestion API, generated from ingest.proto.
+//
+// The stubs are generated with protoc 29.3, protoc-gen-go v1.36.6 and
+// protoc-gen-go-grpc v1.5.1; `make proto` installs the plugins at those
+// versions and runs go generate.
+package telemetryv1
+
+//go:generate protoc --proto_path=../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/telemetry/v1/ingest.proto
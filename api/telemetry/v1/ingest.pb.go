@@ -0,0 +1,440 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: api/telemetry/v1/ingest.proto
+
+package telemetryv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Event is the protobuf form of the JSON event envelope.
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional UUID or ULID used for deduplication.
+	EventId   string `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	EventType string `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	// Defaults to the time the event is stored.
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Arbitrary event data, validated against the event type's schema.
+	Data *structpb.Struct `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	// Schema version data conforms to; latest when zero.
+	SchemaVersion int32 `protobuf:"varint,5,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_telemetry_v1_ingest_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *Event) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *Event) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Event) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Event) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+type IngestRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*Event               `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestRequest) Reset() {
+	*x = IngestRequest{}
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestRequest) ProtoMessage() {}
+
+func (x *IngestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestRequest.ProtoReflect.Descriptor instead.
+func (*IngestRequest) Descriptor() ([]byte, []int) {
+	return file_api_telemetry_v1_ingest_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *IngestRequest) GetEvents() []*Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type IngestResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Events stored, including quarantined ones.
+	Accepted int32 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// Events stored with quarantined = true because their type has no schema.
+	Quarantined int32 `protobuf:"varint,2,opt,name=quarantined,proto3" json:"quarantined,omitempty"`
+	Rejected    int32 `protobuf:"varint,3,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	// The first 100 rejections.
+	Errors          []*EventError `protobuf:"bytes,4,rep,name=errors,proto3" json:"errors,omitempty"`
+	ErrorsTruncated bool          `protobuf:"varint,5,opt,name=errors_truncated,json=errorsTruncated,proto3" json:"errors_truncated,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *IngestResponse) Reset() {
+	*x = IngestResponse{}
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestResponse) ProtoMessage() {}
+
+func (x *IngestResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestResponse.ProtoReflect.Descriptor instead.
+func (*IngestResponse) Descriptor() ([]byte, []int) {
+	return file_api_telemetry_v1_ingest_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IngestResponse) GetAccepted() int32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *IngestResponse) GetQuarantined() int32 {
+	if x != nil {
+		return x.Quarantined
+	}
+	return 0
+}
+
+func (x *IngestResponse) GetRejected() int32 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *IngestResponse) GetErrors() []*EventError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *IngestResponse) GetErrorsTruncated() bool {
+	if x != nil {
+		return x.ErrorsTruncated
+	}
+	return false
+}
+
+// EventError reports why a single event was rejected.
+type EventError struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position of the event in the request, or in the stream.
+	Index         int32        `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Reason        string       `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Violations    []*Violation `protobuf:"bytes,3,rep,name=violations,proto3" json:"violations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventError) Reset() {
+	*x = EventError{}
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventError) ProtoMessage() {}
+
+func (x *EventError) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventError.ProtoReflect.Descriptor instead.
+func (*EventError) Descriptor() ([]byte, []int) {
+	return file_api_telemetry_v1_ingest_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EventError) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *EventError) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *EventError) GetViolations() []*Violation {
+	if x != nil {
+		return x.Violations
+	}
+	return nil
+}
+
+// Violation is a JSON Schema failure at a JSON pointer into the event data.
+type Violation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pointer       string                 `protobuf:"bytes,1,opt,name=pointer,proto3" json:"pointer,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Violation) Reset() {
+	*x = Violation{}
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Violation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Violation) ProtoMessage() {}
+
+func (x *Violation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_telemetry_v1_ingest_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Violation.ProtoReflect.Descriptor instead.
+func (*Violation) Descriptor() ([]byte, []int) {
+	return file_api_telemetry_v1_ingest_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Violation) GetPointer() string {
+	if x != nil {
+		return x.Pointer
+	}
+	return ""
+}
+
+func (x *Violation) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_api_telemetry_v1_ingest_proto protoreflect.FileDescriptor
+
+const file_api_telemetry_v1_ingest_proto_rawDesc = "" +
+	"\n" +
+	"\x1dapi/telemetry/v1/ingest.proto\x12\ftelemetry.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xcf\x01\n" +
+	"\x05Event\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventType\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12+\n" +
+	"\x04data\x18\x04 \x01(\v2\x17.google.protobuf.StructR\x04data\x12%\n" +
+	"\x0eschema_version\x18\x05 \x01(\x05R\rschemaVersion\"<\n" +
+	"\rIngestRequest\x12+\n" +
+	"\x06events\x18\x01 \x03(\v2\x13.telemetry.v1.EventR\x06events\"\xc7\x01\n" +
+	"\x0eIngestResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x05R\baccepted\x12 \n" +
+	"\vquarantined\x18\x02 \x01(\x05R\vquarantined\x12\x1a\n" +
+	"\brejected\x18\x03 \x01(\x05R\brejected\x120\n" +
+	"\x06errors\x18\x04 \x03(\v2\x18.telemetry.v1.EventErrorR\x06errors\x12)\n" +
+	"\x10errors_truncated\x18\x05 \x01(\bR\x0ferrorsTruncated\"s\n" +
+	"\n" +
+	"EventError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x127\n" +
+	"\n" +
+	"violations\x18\x03 \x03(\v2\x17.telemetry.v1.ViolationR\n" +
+	"violations\"?\n" +
+	"\tViolation\x12\x18\n" +
+	"\apointer\x18\x01 \x01(\tR\apointer\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage2\xa1\x01\n" +
+	"\rIngestService\x12C\n" +
+	"\x06Ingest\x12\x1b.telemetry.v1.IngestRequest\x1a\x1c.telemetry.v1.IngestResponse\x12K\n" +
+	"\fIngestStream\x12\x1b.telemetry.v1.IngestRequest\x1a\x1c.telemetry.v1.IngestResponse(\x01BEZCgithub.com/kakhavain/telemetry-tracker/api/telemetry/v1;telemetryv1b\x06proto3"
+
+var (
+	file_api_telemetry_v1_ingest_proto_rawDescOnce sync.Once
+	file_api_telemetry_v1_ingest_proto_rawDescData []byte
+)
+
+func file_api_telemetry_v1_ingest_proto_rawDescGZIP() []byte {
+	file_api_telemetry_v1_ingest_proto_rawDescOnce.Do(func() {
+		file_api_telemetry_v1_ingest_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_telemetry_v1_ingest_proto_rawDesc), len(file_api_telemetry_v1_ingest_proto_rawDesc)))
+	})
+	return file_api_telemetry_v1_ingest_proto_rawDescData
+}
+
+var file_api_telemetry_v1_ingest_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_api_telemetry_v1_ingest_proto_goTypes = []any{
+	(*Event)(nil),                 // 0: telemetry.v1.Event
+	(*IngestRequest)(nil),         // 1: telemetry.v1.IngestRequest
+	(*IngestResponse)(nil),        // 2: telemetry.v1.IngestResponse
+	(*EventError)(nil),            // 3: telemetry.v1.EventError
+	(*Violation)(nil),             // 4: telemetry.v1.Violation
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 6: google.protobuf.Struct
+}
+var file_api_telemetry_v1_ingest_proto_depIdxs = []int32{
+	5, // 0: telemetry.v1.Event.timestamp:type_name -> google.protobuf.Timestamp
+	6, // 1: telemetry.v1.Event.data:type_name -> google.protobuf.Struct
+	0, // 2: telemetry.v1.IngestRequest.events:type_name -> telemetry.v1.Event
+	3, // 3: telemetry.v1.IngestResponse.errors:type_name -> telemetry.v1.EventError
+	4, // 4: telemetry.v1.EventError.violations:type_name -> telemetry.v1.Violation
+	1, // 5: telemetry.v1.IngestService.Ingest:input_type -> telemetry.v1.IngestRequest
+	1, // 6: telemetry.v1.IngestService.IngestStream:input_type -> telemetry.v1.IngestRequest
+	2, // 7: telemetry.v1.IngestService.Ingest:output_type -> telemetry.v1.IngestResponse
+	2, // 8: telemetry.v1.IngestService.IngestStream:output_type -> telemetry.v1.IngestResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_api_telemetry_v1_ingest_proto_init() }
+func file_api_telemetry_v1_ingest_proto_init() {
+	if File_api_telemetry_v1_ingest_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_telemetry_v1_ingest_proto_rawDesc), len(file_api_telemetry_v1_ingest_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_telemetry_v1_ingest_proto_goTypes,
+		DependencyIndexes: file_api_telemetry_v1_ingest_proto_depIdxs,
+		MessageInfos:      file_api_telemetry_v1_ingest_proto_msgTypes,
+	}.Build()
+	File_api_telemetry_v1_ingest_proto = out.File
+	file_api_telemetry_v1_ingest_proto_goTypes = nil
+	file_api_telemetry_v1_ingest_proto_depIdxs = nil
+}
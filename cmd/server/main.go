@@ -4,38 +4,85 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	telemetryv1 "github.com/kakhavain/telemetry-tracker/api/telemetry/v1"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
 	"github.com/kakhavain/telemetry-tracker/internal/config"
 	"github.com/kakhavain/telemetry-tracker/internal/handlers"
+	"github.com/kakhavain/telemetry-tracker/internal/health"
 	"github.com/kakhavain/telemetry-tracker/internal/metrics"
 	"github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/migrate"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/queue"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/retention"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"github.com/kakhavain/telemetry-tracker/internal/signing"
+	"github.com/kakhavain/telemetry-tracker/internal/spool"
 	"github.com/kakhavain/telemetry-tracker/internal/storage"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	chimid "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+)
+
+const (
+	// queueReadyThreshold is the queue saturation at which an instance
+	// stops receiving traffic, shortly before it would start shedding load.
+	queueReadyThreshold = 0.9
+	// exporterHealthWindow is how long an OpenTelemetry export error keeps
+	// the exporter check failing.
+	exporterHealthWindow = time.Minute
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize observability.
-	obs, err := observability.InitObservability("otel")
+	obs, err := observability.New(observability.Config{
+		Mode:              cfg.ObservabilityMode,
+		Endpoint:          cfg.OTLPEndpoint,
+		Protocol:          cfg.OTLPProtocol,
+		Insecure:          cfg.OTLPInsecure,
+		Certificate:       cfg.OTLPCertificate,
+		ClientCertificate: cfg.OTLPClientCertificate,
+		ClientKey:         cfg.OTLPClientKey,
+		Headers:           cfg.OTLPHeaders,
+		ServiceName:       cfg.ServiceName,
+		ServiceVersion:    cfg.ServiceVersion,
+		Environment:       cfg.Environment,
+		Prometheus:        cfg.PrometheusEnabled,
+	})
 	if err != nil {
 		slog.Error("Failed to initialize observability", "error", err)
 		os.Exit(1)
@@ -46,13 +93,7 @@ func main() {
 		}
 	}()
 
-	cfg, err := config.Load()
-	if err != nil {
-		slog.Error("Failed to load configuration", "error", err)
-		os.Exit(1)
-	}
-
-	slog.Info("Configuration loaded")
+	slog.Info("Configuration loaded", "observability_mode", cfg.ObservabilityMode)
 
 	metricsRegistry, err := metrics.NewRegistry(obs.Meter())
 	if err != nil {
@@ -91,29 +132,312 @@ func main() {
 		}
 	}
 
-	store, err := storage.NewPostgresStore(ctx, cfg.DSN, obs)
+	if cfg.AutoMigrate && cfg.StorageDriver == "postgres" {
+		migrator, err := migrate.Open(ctx, cfg.StorageDSN, obs.Logger())
+		if err != nil {
+			slog.Error("Failed to open database for migrations", "error", err)
+			os.Exit(1)
+		}
+		n, err := migrator.Up(ctx)
+		migrator.Close()
+		if err != nil {
+			slog.Error("Failed to apply migrations", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Database migrations up to date", "applied", n)
+	}
+
+	store, err := storage.Open(ctx, cfg.StorageDriver, cfg.StorageDSN, obs)
 	if err != nil {
-		slog.Error("Failed to connect to database", "error", err, "dsn_details", "host="+cfg.DBHost)
+		slog.Error("Failed to open storage", "error", err, "driver", cfg.StorageDriver, "dsn_details", "host="+cfg.DBHost)
 		os.Exit(1)
 	}
 	defer store.Close()
 
+	// With the spool enabled a database outage is absorbed on disk, so an
+	// unreachable database degrades readiness instead of failing it; taking
+	// every replica out of rotation would turn the outage into lost events.
+	healthChecks := health.NewRegistry(cfg.HealthCheckTimeout)
+	healthChecks.Register(health.Readiness, health.Checker{
+		Name:     "database",
+		Check:    store.Ping,
+		Optional: cfg.SpoolEnabled,
+	})
+	if cfg.ObservabilityMode == "otel" {
+		healthChecks.Register(health.Readiness, health.Checker{
+			Name:     "otel_exporter",
+			Check:    observability.ExporterHealth(exporterHealthWindow),
+			Optional: true,
+		})
+	}
+
+	if pg, ok := store.(*storage.PostgresStore); ok {
+		interval, err := storage.ParsePartitionInterval(cfg.PartitionInterval)
+		if err != nil {
+			slog.Error("Invalid partition configuration", "error", err)
+			os.Exit(1)
+		}
+		go pg.PartitionManager(storage.PartitionConfig{
+			Interval:      interval,
+			Premake:       cfg.PartitionPremake,
+			CheckInterval: cfg.PartitionCheckInterval,
+		}).Run(ctx)
+	}
+
+	retentionPolicy, err := retention.ParsePolicy(cfg.RetentionDefault, cfg.RetentionRules)
+	if err != nil {
+		slog.Error("Invalid retention configuration", "error", err)
+		os.Exit(1)
+	}
+	if retentionPolicy.Enabled() {
+		go retention.New(store, retentionPolicy, retention.Config{
+			Interval:  cfg.RetentionInterval,
+			BatchSize: cfg.RetentionBatchSize,
+		}, metricsRegistry, obs).Run(ctx)
+		slog.Info("Retention enabled", "default", cfg.RetentionDefault, "rules", cfg.RetentionRules)
+	}
+
 	appRouter := chi.NewRouter()
 	appRouter.Use(
 		chimid.RequestID,
 		chimid.RealIP,
 		middleware.RequestTelemetry(obs.Logger(), metricsRegistry),
 		middleware.TracingMiddleware(obs.Tracer()),
-		chimid.Timeout(60*time.Second),
 	)
 
 	eventHandler := handlers.NewEventHandler(store, metricsRegistry, obs)
+
+	unknownPolicy, err := schema.ParsePolicy(cfg.UnknownEventPolicy)
+	if err != nil {
+		slog.Error("Invalid schema configuration", "error", err)
+		os.Exit(1)
+	}
+	schemaRegistry := schema.NewRegistry(unknownPolicy)
+	if cfg.SchemaDir != "" {
+		if err := schemaRegistry.LoadDir(cfg.SchemaDir); err != nil {
+			slog.Error("Failed to load schemas", "error", err, "dir", cfg.SchemaDir)
+			os.Exit(1)
+		}
+		slog.Info("Schemas loaded", "dir", cfg.SchemaDir, "count", len(schemaRegistry.List()))
+	}
+	eventHandler.Schemas = schemaRegistry
+	eventHandler.LogEventTypeAttribute = cfg.LogEventTypeAttribute
+
+	if cfg.SpoolEnabled {
+		syncPolicy, err := spool.ParseSyncPolicy(cfg.SpoolSync)
+		if err != nil {
+			slog.Error("Invalid spool configuration", "error", err)
+			os.Exit(1)
+		}
+		eventSpool, err := spool.Open(store, spool.Config{
+			Dir:            cfg.SpoolDir,
+			SegmentBytes:   int64(cfg.SpoolSegmentBytes),
+			Sync:           syncPolicy,
+			SyncInterval:   cfg.SpoolSyncInterval,
+			ReplayInterval: cfg.SpoolReplayInterval,
+			WriteTimeout:   cfg.SpoolWriteTimeout,
+		}, metricsRegistry, obs)
+		if err != nil {
+			slog.Error("Failed to open spool", "error", err, "dir", cfg.SpoolDir)
+			os.Exit(1)
+		}
+		defer eventSpool.Close()
+		eventHandler.Store = eventSpool
+		healthChecks.Register(health.Readiness, health.Checker{
+			Name: "spool",
+			Check: func(context.Context) error {
+				if depth := eventSpool.Depth(); depth > 0 {
+					return fmt.Errorf("%d events spooled awaiting replay", depth)
+				}
+				return nil
+			},
+			Optional: true,
+		})
+	}
+
+	var eventQueue *queue.Queue
+	if cfg.QueueEnabled {
+		eventQueue = queue.New(eventHandler.Store, queue.Config{
+			Capacity:      cfg.QueueCapacity,
+			Workers:       cfg.QueueWorkers,
+			FlushSize:     cfg.QueueFlushSize,
+			FlushInterval: cfg.QueueFlushInterval,
+		}, metricsRegistry, obs)
+		eventHandler.Store = eventQueue
+		healthChecks.Register(health.Readiness, health.Checker{
+			Name: "queue",
+			Check: func(context.Context) error {
+				if s := eventQueue.Saturation(); s >= queueReadyThreshold {
+					return fmt.Errorf("queue is %.0f%% full", s*100)
+				}
+				return nil
+			},
+		})
+		slog.Info("Write-behind queue enabled",
+			"capacity", cfg.QueueCapacity,
+			"workers", cfg.QueueWorkers,
+			"flush_size", cfg.QueueFlushSize,
+			"flush_interval", cfg.QueueFlushInterval,
+		)
+	}
+
+	// Every event route is scoped to a tenant: the API key's when
+	// authentication is enabled, otherwise the default tenant. gRPC calls
+	// pass the same checks as their HTTP counterparts.
+	keyManager := apikey.NewManager(store, cfg.APIKeyCacheTTL)
+	tenantScope := middleware.StaticTenant(storage.DefaultTenant)
+	grpcChecks := []middleware.GRPCCheck{middleware.GRPCStaticTenant(storage.DefaultTenant)}
+	if cfg.AuthEnabled {
+		tenantScope = middleware.RequireAPIKey(keyManager)
+		grpcChecks = []middleware.GRPCCheck{middleware.GRPCAPIKey(keyManager)}
+		if cfg.AdminToken == "" {
+			slog.Warn("AUTH_ENABLED is set without ADMIN_TOKEN; API keys cannot be managed")
+		}
+	} else {
+		slog.Info("AUTH_ENABLED not set; events are not authenticated")
+	}
+
+	// Producers of tenants with a signing secret must sign what they ingest.
+	signingManager := signing.NewManager(store, cfg.APIKeyCacheTTL, cfg.SignatureTolerance)
+	var ingest chi.Middlewares
+	var grpcIngest []middleware.GRPCCheck
+	if cfg.SigningEnabled {
+		ingest = append(ingest, middleware.RequireSignature(signingManager))
+		grpcIngest = append(grpcIngest, middleware.GRPCSignature(signingManager))
+		slog.Info("Request signing enabled", "tolerance", cfg.SignatureTolerance)
+	}
+
+	// Rate limits apply to every event route; quotas only to ingestion.
+	tiers, err := ratelimit.ParseTiers(cfg.RateLimitTiers, cfg.QuotaDailyEvents, cfg.TenantTiers)
+	if err != nil {
+		slog.Error("Invalid rate limit configuration", "error", err)
+		os.Exit(1)
+	}
+	eventScope := chi.Middlewares{tenantScope}
+	if cfg.RateLimitEnabled {
+		keyBy, err := ratelimit.ParseKeyBy(cfg.RateLimitKey)
+		if err != nil {
+			slog.Error("Invalid rate limit configuration", "error", err)
+			os.Exit(1)
+		}
+		limiter := ratelimit.NewLimiter()
+		go limiter.Run(ctx)
+		eventScope = append(eventScope, middleware.RateLimit(limiter, tiers, keyBy, metricsRegistry))
+		grpcChecks = append(grpcChecks, middleware.GRPCRateLimit(limiter, tiers, keyBy, metricsRegistry))
+		slog.Info("Rate limiting enabled", "key", keyBy, "tiers", cfg.RateLimitTiers)
+	}
+	var quota *ratelimit.Quota
+	if len(tiers.Quotas) > 0 {
+		quota = ratelimit.NewQuota(store, tiers, cfg.QuotaFlushInterval, obs)
+		go quota.Run(ctx)
+		eventHandler.Quota = quota
+		ingest = append(ingest, middleware.EnforceQuota(quota, tiers, metricsRegistry))
+		grpcIngest = append(grpcIngest, middleware.GRPCQuota(quota, tiers, metricsRegistry))
+		slog.Info("Daily event quotas enabled", "quotas", cfg.QuotaDailyEvents)
+	}
+
+	// Bodies are decompressed last, once a request is known to be allowed;
+	// signatures cover the body as sent. Streams have no size limit
+	// uncompressed, so compressed ones are only held to the ratio.
+	streamIngest := append(slices.Clip(ingest), middleware.Decompress(middleware.DecompressLimits{
+		MaxRatio: int64(cfg.DecompressMaxRatio),
+	}, metricsRegistry))
+	ingest = append(ingest, middleware.Decompress(middleware.DecompressLimits{
+		MaxCompressedBytes:   int64(cfg.DecompressMaxCompressedBytes),
+		MaxDecompressedBytes: int64(cfg.DecompressMaxBytes),
+		MaxRatio:             int64(cfg.DecompressMaxRatio),
+	}, metricsRegistry))
+
+	var corsOrigins []string
+	for _, origin := range strings.Split(cfg.CORSAllowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			corsOrigins = append(corsOrigins, origin)
+		}
+	}
+
+	queryHandler := handlers.NewQueryHandler(store, metricsRegistry, obs)
 	healthHandler := handlers.NewHealthHandler(obs)
-	appRouter.Post("/events", eventHandler.ServeHTTP)
-	appRouter.Get("/healthz", healthHandler.ServeHTTP)
+	healthHandler.Checks = healthChecks
+	appRouter.Group(func(r chi.Router) {
+		r.Use(chimid.Timeout(60 * time.Second))
+		r.Get("/healthz", healthHandler.ServeHTTP)
+		r.Get("/livez", healthHandler.ServeLive)
+		r.Get("/readyz", healthHandler.ServeReady)
+		r.Group(func(r chi.Router) {
+			r.Use(eventScope...)
+			r.With(ingest...).Post("/events", eventHandler.ServeHTTP)
+			r.Get("/events", queryHandler.ServeHTTP)
+			r.Get("/events/aggregate", queryHandler.ServeAggregate)
+			r.With(ingest...).Post("/events/batch", eventHandler.ServeBatch)
+			r.With(ingest...).Post("/v1/logs", eventHandler.ServeOTLPLogs)
+			r.With(ingest...).Post("/v1/track", eventHandler.ServeSegment("track"))
+			r.With(ingest...).Post("/v1/identify", eventHandler.ServeSegment("identify"))
+			r.With(ingest...).Post("/v1/page", eventHandler.ServeSegment("page"))
+			r.With(ingest...).Post("/v1/batch", eventHandler.ServeSegmentBatch)
+		})
+		// Browsers send beacons and pixels cross-origin and without headers,
+		// so the API key may come in the query string. Preflights carry no
+		// credentials and are answered before authentication.
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.CORS(corsOrigins))
+			r.Options("/events/beacon", middleware.Preflight)
+			r.Options("/p.gif", middleware.Preflight)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.APIKeyFromQuery)
+				r.Use(eventScope...)
+				r.Use(ingest...)
+				r.Post("/events/beacon", eventHandler.ServeBeacon)
+				r.Get("/p.gif", eventHandler.ServePixel)
+			})
+		})
+	})
+	if cfg.AdminToken != "" {
+		schemaHandler := handlers.NewSchemaHandler(schemaRegistry, obs)
+		keyHandler := handlers.NewAPIKeyHandler(keyManager, obs)
+		signingHandler := handlers.NewSigningSecretHandler(signingManager, obs)
+		appRouter.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.RequireAdminToken(cfg.AdminToken))
+			r.Route("/schemas", schemaHandler.Routes)
+			r.Route("/keys", keyHandler.Routes)
+			r.Route("/signing-secrets", signingHandler.Routes)
+		})
+	} else {
+		slog.Info("ADMIN_TOKEN not set; admin API disabled")
+	}
+	// The scrape endpoint lives on the app router unless METRICS_ADDR moves
+	// it to its own listener, e.g. to keep it off a public port.
+	var metricsServer *http.Server
+	if metricsHandler := obs.MetricsHandler(); metricsHandler != nil {
+		if cfg.MetricsAddr == "" {
+			appRouter.Method(http.MethodGet, "/metrics", metricsHandler)
+		} else {
+			metricsRouter := chi.NewRouter()
+			metricsRouter.Method(http.MethodGet, "/metrics", metricsHandler)
+			metricsServer = &http.Server{
+				Addr:         cfg.MetricsAddr,
+				Handler:      metricsRouter,
+				ReadTimeout:  5 * time.Second,
+				WriteTimeout: 10 * time.Second,
+			}
+		}
+	}
+	// NDJSON streams may legitimately run longer than the request timeout;
+	// the handler enforces its own idle deadline instead.
+	appRouter.With(eventScope...).With(streamIngest...).Post("/events/stream", eventHandler.ServeStream)
 
 	otelHandler := otelhttp.NewHandler(appRouter, "telemetry-tracker-router")
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddr != "" {
+		grpcChecks = append(grpcChecks, grpcIngest...)
+		grpcServer = grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.ChainUnaryInterceptor(middleware.UnaryServerInterceptor(obs.Logger(), grpcChecks...)),
+			grpc.ChainStreamInterceptor(middleware.StreamServerInterceptor(obs.Logger(), grpcChecks...)),
+		)
+		telemetryv1.RegisterIngestServiceServer(grpcServer, handlers.NewIngestServer(eventHandler))
+	}
+
 	server := &http.Server{
 		Addr:         ":" + cfg.ServerPort,
 		Handler:      otelHandler,
@@ -130,6 +454,29 @@ func main() {
 		}
 	}()
 
+	if metricsServer != nil {
+		go func() {
+			slog.Info("Starting metrics server", "address", metricsServer.Addr)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Metrics server error", "error", err)
+			}
+		}()
+	}
+
+	if grpcServer != nil {
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			slog.Error("Failed to listen for gRPC", "error", err, "address", cfg.GRPCAddr)
+			os.Exit(1)
+		}
+		go func() {
+			slog.Info("Starting gRPC server", "address", cfg.GRPCAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("gRPC server error", "error", err)
+			}
+		}()
+	}
+
 	<-sigChan
 	slog.Info("Shutting down server...")
 
@@ -138,5 +485,36 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("Server forced to shutdown", "error", err)
 	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Metrics server forced to shutdown", "error", err)
+		}
+	}
+	if grpcServer != nil {
+		// GracefulStop waits for open streams, which a client may hold
+		// indefinitely; cut them off at the shutdown deadline.
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			slog.Error("gRPC server forced to shutdown", "error", shutdownCtx.Err())
+			grpcServer.Stop()
+		}
+	}
+	if eventQueue != nil {
+		slog.Info("Draining event queue...")
+		if err := eventQueue.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Event queue did not drain before shutdown deadline", "error", err)
+		}
+	}
+	if quota != nil {
+		if err := quota.Flush(shutdownCtx); err != nil {
+			slog.Error("Failed to flush quota usage", "error", err)
+		}
+	}
 	slog.Info("Server gracefully stopped")
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/config"
+	"github.com/kakhavain/telemetry-tracker/internal/migrate"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [N]   revert the last N applied migrations (default 1)
+  status     list migrations and whether they are applied`
+
+// runMigrate implements the "migrate" subcommand and returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+	if cfg.StorageDriver != "postgres" {
+		slog.Error("Migrations only apply to the postgres storage driver", "driver", cfg.StorageDriver)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	migrator, err := migrate.Open(ctx, cfg.StorageDSN, slog.Default())
+	if err != nil {
+		slog.Error("Failed to open database for migrations", "error", err)
+		return 1
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			slog.Error("Migration failed", "error", err)
+			return 1
+		}
+		slog.Info("Migrations applied", "count", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			slog.Error("Migration rollback failed", "error", err)
+			return 1
+		}
+		slog.Info("Migrations reverted", "count", n)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			slog.Error("Failed to read migration status", "error", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		tw.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
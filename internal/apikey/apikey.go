@@ -0,0 +1,198 @@
+// Package apikey issues, rotates and revokes the API keys that authenticate
+// clients, and resolves a presented key to the tenant it belongs to.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+// keyPrefix marks telemetry-tracker keys so that they are recognizable in
+// configuration and secret scanners.
+const keyPrefix = "tt_"
+
+// displayLen is how much of a key is kept in the clear to identify it.
+const displayLen = len(keyPrefix) + 8
+
+var (
+	// ErrInvalidKey is returned for a key that is unknown, expired or revoked.
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrInactive is returned when rotating a key that is expired or revoked.
+	ErrInactive = errors.New("api key is expired or revoked")
+	// ErrInvalidTenant is returned for a malformed tenant ID.
+	ErrInvalidTenant = errors.New("tenant ID must be 1-64 letters, digits, '.', '_' or '-'")
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidTenantID reports whether id can name a tenant.
+func ValidTenantID(id string) bool {
+	return tenantPattern.MatchString(id)
+}
+
+// Hash returns the digest a key is stored and looked up by. Keys carry 256
+// random bits, so a fast unsalted hash is enough to protect them at rest.
+func Hash(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate api key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Manager manages the keys in a KeyStore. Authenticated keys are cached for
+// a short time so that ingestion does not query the database per request;
+// a key revoked through another instance stops working once its cache
+// entry expires.
+type Manager struct {
+	store    storage.KeyStore
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedKey // by hash
+}
+
+type cachedKey struct {
+	key     storage.APIKey
+	fetched time.Time
+}
+
+// NewManager creates a Manager. A cacheTTL of zero disables caching.
+func NewManager(store storage.KeyStore, cacheTTL time.Duration) *Manager {
+	return &Manager{store: store, cacheTTL: cacheTTL, now: time.Now, cache: make(map[string]cachedKey)}
+}
+
+// Create issues a key for tenantID and returns it with its stored record.
+// The key itself is not retrievable afterwards. A positive lifetime makes
+// the key expire.
+func (m *Manager) Create(ctx context.Context, tenantID, name string, lifetime time.Duration) (string, storage.APIKey, error) {
+	if !ValidTenantID(tenantID) {
+		return "", storage.APIKey{}, ErrInvalidTenant
+	}
+	key, err := generate()
+	if err != nil {
+		return "", storage.APIKey{}, err
+	}
+	now := m.now().UTC().Truncate(time.Microsecond)
+	record := storage.APIKey{
+		ID:        uuid.NewString(),
+		TenantID:  tenantID,
+		Name:      name,
+		Prefix:    key[:displayLen],
+		Hash:      Hash(key),
+		CreatedAt: now,
+	}
+	if lifetime > 0 {
+		expires := now.Add(lifetime)
+		record.ExpiresAt = &expires
+	}
+	if err := m.store.CreateAPIKey(ctx, record); err != nil {
+		return "", storage.APIKey{}, err
+	}
+	return key, record, nil
+}
+
+// Rotate issues a replacement for key id with the same tenant and name,
+// and makes the old key expire after grace so that clients can switch
+// over. A zero grace expires it immediately.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration) (string, storage.APIKey, error) {
+	old, err := m.store.GetAPIKey(ctx, id)
+	if err != nil {
+		return "", storage.APIKey{}, err
+	}
+	now := m.now().UTC().Truncate(time.Microsecond)
+	if !old.Active(now) {
+		return "", storage.APIKey{}, ErrInactive
+	}
+	// The replacement is stored first, so a failure leaves the old key working.
+	key, record, err := m.Create(ctx, old.TenantID, old.Name, 0)
+	if err != nil {
+		return "", storage.APIKey{}, err
+	}
+	if err := m.store.ExpireAPIKey(ctx, id, now.Add(grace)); err != nil {
+		return "", storage.APIKey{}, err
+	}
+	m.forget(old.Hash)
+	return key, record, nil
+}
+
+// Revoke stops key id from authenticating and returns its updated record.
+func (m *Manager) Revoke(ctx context.Context, id string) (storage.APIKey, error) {
+	if err := m.store.RevokeAPIKey(ctx, id, m.now().UTC().Truncate(time.Microsecond)); err != nil {
+		return storage.APIKey{}, err
+	}
+	record, err := m.store.GetAPIKey(ctx, id)
+	if err != nil {
+		return storage.APIKey{}, err
+	}
+	m.forget(record.Hash)
+	return record, nil
+}
+
+// Get returns the record of key id.
+func (m *Manager) Get(ctx context.Context, id string) (storage.APIKey, error) {
+	return m.store.GetAPIKey(ctx, id)
+}
+
+// List returns the keys of tenantID, or of every tenant when it is empty.
+func (m *Manager) List(ctx context.Context, tenantID string) ([]storage.APIKey, error) {
+	return m.store.ListAPIKeys(ctx, tenantID)
+}
+
+// Authenticate returns the record of an active key. It returns
+// ErrInvalidKey when the key is unknown, expired or revoked, and any other
+// error when the store could not be consulted.
+func (m *Manager) Authenticate(ctx context.Context, key string) (storage.APIKey, error) {
+	// Anything that is not one of our keys cannot match; skip the lookup.
+	if !strings.HasPrefix(key, keyPrefix) {
+		return storage.APIKey{}, ErrInvalidKey
+	}
+	hash := Hash(key)
+	now := m.now()
+
+	m.mu.Lock()
+	cached, ok := m.cache[string(hash)]
+	m.mu.Unlock()
+	if !ok || now.Sub(cached.fetched) >= m.cacheTTL {
+		record, err := m.store.LookupAPIKey(ctx, hash)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return storage.APIKey{}, ErrInvalidKey
+		}
+		if err != nil {
+			return storage.APIKey{}, fmt.Errorf("unable to look up api key: %w", err)
+		}
+		cached = cachedKey{key: record, fetched: now}
+		if m.cacheTTL > 0 {
+			m.mu.Lock()
+			m.cache[string(hash)] = cached
+			m.mu.Unlock()
+		}
+	}
+	if !cached.key.Active(now) {
+		return storage.APIKey{}, ErrInvalidKey
+	}
+	return cached.key, nil
+}
+
+func (m *Manager) forget(hash []byte) {
+	m.mu.Lock()
+	delete(m.cache, string(hash))
+	m.mu.Unlock()
+}
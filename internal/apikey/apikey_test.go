@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	m := NewManager(storage.NewMemoryStore(), time.Minute)
+	m.now = func() time.Time { return now }
+
+	_, _, err := m.Create(ctx, "not a tenant", "", 0)
+	be.True(t, errors.Is(err, ErrInvalidTenant))
+
+	key, record, err := m.Create(ctx, "acme", "ios", 0)
+	be.NilErr(t, err)
+	be.True(t, strings.HasPrefix(key, record.Prefix))
+	be.Equal(t, "acme", record.TenantID)
+
+	got, err := m.Authenticate(ctx, key)
+	be.NilErr(t, err)
+	be.Equal(t, record.ID, got.ID)
+
+	for _, bad := range []string{"", "tt_unknown", key + "x", strings.TrimPrefix(key, "tt_")} {
+		_, err := m.Authenticate(ctx, bad)
+		be.True(t, errors.Is(err, ErrInvalidKey))
+	}
+
+	// The old key keeps working for the grace period, then stops.
+	newKey, replacement, err := m.Rotate(ctx, record.ID, time.Hour)
+	be.NilErr(t, err)
+	be.Equal(t, "acme", replacement.TenantID)
+	be.Equal(t, "ios", replacement.Name)
+	_, err = m.Authenticate(ctx, key)
+	be.NilErr(t, err)
+	now = now.Add(time.Hour)
+	_, err = m.Authenticate(ctx, key)
+	be.True(t, errors.Is(err, ErrInvalidKey))
+	_, _, err = m.Rotate(ctx, record.ID, time.Hour)
+	be.True(t, errors.Is(err, ErrInactive))
+
+	// Revocation takes effect immediately despite the cached lookup.
+	_, err = m.Authenticate(ctx, newKey)
+	be.NilErr(t, err)
+	_, err = m.Revoke(ctx, replacement.ID)
+	be.NilErr(t, err)
+	_, err = m.Authenticate(ctx, newKey)
+	be.True(t, errors.Is(err, ErrInvalidKey))
+
+	keys, err := m.List(ctx, "acme")
+	be.NilErr(t, err)
+	be.Equal(t, 2, len(keys))
+}
+
+func TestManagerExpiringKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	m := NewManager(storage.NewMemoryStore(), 0)
+	m.now = func() time.Time { return now }
+
+	key, record, err := m.Create(ctx, "acme", "", 24*time.Hour)
+	be.NilErr(t, err)
+	be.True(t, record.ExpiresAt.Equal(now.Add(24*time.Hour)))
+	_, err = m.Authenticate(ctx, key)
+	be.NilErr(t, err)
+
+	now = now.Add(24 * time.Hour)
+	_, err = m.Authenticate(ctx, key)
+	be.True(t, errors.Is(err, ErrInvalidKey))
+}
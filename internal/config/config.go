@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config holds application configuration
@@ -16,6 +17,75 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DSN        string // Constructed or provided connection string
+
+	StorageDriver string // Storage backend: postgres, sqlite or memory
+	StorageDSN    string // Backend data source; defaults to DSN for postgres
+	AutoMigrate   bool   // Apply pending database migrations on startup
+
+	PartitionInterval      string        // Events partition size: day or month
+	PartitionPremake       int           // Future partitions created ahead of time
+	PartitionCheckInterval time.Duration // How often partitions are checked
+
+	RetentionDefault   string        // TTL for event types without a rule; empty keeps them forever
+	RetentionRules     string        // Per-type TTLs, e.g. "heartbeat=7d,purchase=2y"
+	RetentionInterval  time.Duration // Time between retention runs
+	RetentionBatchSize int           // Maximum rows deleted per statement
+
+	QueueEnabled       bool          // Buffer events in memory and write them asynchronously
+	QueueCapacity      int           // Maximum number of buffered events before rejecting with 503
+	QueueWorkers       int           // Number of flush workers
+	QueueFlushSize     int           // Events per batch insert
+	QueueFlushInterval time.Duration // Maximum time an event waits in the queue
+
+	SpoolEnabled        bool          // Spool events to disk when the database is unavailable
+	SpoolDir            string        // Directory holding spool segment files
+	SpoolSegmentBytes   int           // Size at which a spool segment is sealed
+	SpoolSync           string        // fsync policy: always, interval or never
+	SpoolSyncInterval   time.Duration // fsync period for the interval policy
+	SpoolReplayInterval time.Duration // How often spooled events are replayed
+	SpoolWriteTimeout   time.Duration // Database write budget before an event is spooled
+
+	DecompressMaxCompressedBytes int // Largest compressed request body accepted
+	DecompressMaxBytes           int // Largest request body after decompression
+	DecompressMaxRatio           int // Largest decompressed-to-compressed size ratio of a request body
+
+	SchemaDir          string // Directory of JSON Schemas loaded at startup; empty disables file loading
+	UnknownEventPolicy string // allow, reject or quarantine events with no registered schema
+	AdminToken         string // Bearer token for /admin endpoints; empty disables them
+
+	LogEventTypeAttribute string // OTLP log attribute read as event_type for records without an event name
+	GRPCAddr              string // Listen address for the gRPC ingest service, e.g. ":9090"; empty disables it
+	CORSAllowedOrigins    string // Comma-separated origins allowed to send beacons and pixels; "*" allows any
+
+	AuthEnabled    bool          // Require an API key for /events and scope data to its tenant
+	APIKeyCacheTTL time.Duration // How long an authenticated key is trusted before it is looked up again
+
+	SigningEnabled     bool          // Require HMAC signatures from tenants with signing secrets
+	SignatureTolerance time.Duration // How far a signature timestamp may be from the current time
+
+	RateLimitEnabled   bool          // Throttle /events requests with per-key token buckets
+	RateLimitKey       string        // What a bucket is shared by: ip, tenant or api_key
+	RateLimitTiers     string        // Request rate per tier, e.g. "default=100/s:200,pro=1000/s"
+	QuotaDailyEvents   string        // Events per UTC day per tier, e.g. "default=1000000"; empty disables quotas
+	TenantTiers        string        // Tier per tenant, e.g. "acme=pro"; others are in the default tier
+	QuotaFlushInterval time.Duration // How often quota usage is written to the database
+
+	ObservabilityMode     string // otel, debug, local or noop
+	OTLPEndpoint          string // Collector URL or host:port; empty uses the exporter default
+	OTLPProtocol          string // grpc or http/protobuf
+	OTLPInsecure          bool   // Disable TLS for an endpoint without a scheme
+	OTLPCertificate       string // CA bundle for verifying the collector
+	OTLPClientCertificate string // Client certificate for mutual TLS
+	OTLPClientKey         string // Client key for mutual TLS
+	OTLPHeaders           string // Comma-separated key=value headers sent with every export
+	ServiceName           string // service.name resource attribute
+	ServiceVersion        string // service.version resource attribute
+	Environment           string // deployment.environment resource attribute
+
+	PrometheusEnabled bool   // Expose metrics for Prometheus scraping
+	MetricsAddr       string // Listen address for /metrics; empty serves it on the app port
+
+	HealthCheckTimeout time.Duration // Budget for each /livez and /readyz check
 }
 
 // Load loads configuration from environment variables
@@ -40,6 +110,156 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid APP_PORT: %w", err)
 	}
 
+	queueEnabled, err := getEnvBool("QUEUE_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	queueCapacity, err := getEnvInt("QUEUE_CAPACITY", 10000)
+	if err != nil {
+		return nil, err
+	}
+	queueWorkers, err := getEnvInt("QUEUE_WORKERS", 2)
+	if err != nil {
+		return nil, err
+	}
+	queueFlushSize, err := getEnvInt("QUEUE_FLUSH_SIZE", 500)
+	if err != nil {
+		return nil, err
+	}
+	queueFlushInterval, err := getEnvDuration("QUEUE_FLUSH_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolEnabled, err := getEnvBool("SPOOL_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	spoolDir := getEnv("SPOOL_DIR", "/var/lib/telemetry-tracker/spool")
+	spoolSegmentBytes, err := getEnvInt("SPOOL_SEGMENT_BYTES", 64<<20)
+	if err != nil {
+		return nil, err
+	}
+	spoolSync := getEnv("SPOOL_SYNC", "interval")
+	spoolSyncInterval, err := getEnvDuration("SPOOL_SYNC_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	spoolReplayInterval, err := getEnvDuration("SPOOL_REPLAY_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	spoolWriteTimeout, err := getEnvDuration("SPOOL_WRITE_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	storageDriver := getEnv("STORAGE_DRIVER", "postgres")
+	storageDSN := getEnv("STORAGE_DSN", "")
+	autoMigrate, err := getEnvBool("AUTO_MIGRATE", false)
+	if err != nil {
+		return nil, err
+	}
+
+	partitionInterval := getEnv("PARTITION_INTERVAL", "month")
+	partitionPremake, err := getEnvInt("PARTITION_PREMAKE", 3)
+	if err != nil {
+		return nil, err
+	}
+	partitionCheckInterval, err := getEnvDuration("PARTITION_CHECK_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	retentionDefault := getEnv("RETENTION_DEFAULT", "")
+	retentionRules := getEnv("RETENTION_RULES", "")
+	retentionInterval, err := getEnvDuration("RETENTION_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	retentionBatchSize, err := getEnvInt("RETENTION_BATCH_SIZE", 5000)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressMaxCompressedBytes, err := getEnvInt("DECOMPRESS_MAX_COMPRESSED_BYTES", 10<<20)
+	if err != nil {
+		return nil, err
+	}
+	decompressMaxBytes, err := getEnvInt("DECOMPRESS_MAX_BYTES", 64<<20)
+	if err != nil {
+		return nil, err
+	}
+	decompressMaxRatio, err := getEnvInt("DECOMPRESS_MAX_RATIO", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaDir := getEnv("SCHEMA_DIR", "")
+	unknownEventPolicy := getEnv("UNKNOWN_EVENT_POLICY", "allow")
+	adminToken := getEnv("ADMIN_TOKEN", "")
+	logEventTypeAttribute := getEnv("OTLP_EVENT_TYPE_ATTRIBUTE", "event.name")
+	grpcAddr := getEnv("GRPC_ADDR", "")
+	corsAllowedOrigins := getEnv("CORS_ALLOWED_ORIGINS", "*")
+
+	authEnabled, err := getEnvBool("AUTH_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyCacheTTL, err := getEnvDuration("API_KEY_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	signingEnabled, err := getEnvBool("SIGNING_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	signatureTolerance, err := getEnvDuration("SIGNATURE_TOLERANCE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitEnabled, err := getEnvBool("RATE_LIMIT_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitKey := getEnv("RATE_LIMIT_KEY", "ip")
+	rateLimitTiers := getEnv("RATE_LIMIT_TIERS", "default=100/s:200")
+	quotaDailyEvents := getEnv("QUOTA_DAILY_EVENTS", "")
+	tenantTiers := getEnv("TENANT_TIERS", "")
+	quotaFlushInterval, err := getEnvDuration("QUOTA_FLUSH_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// The OTLP settings use the standard OpenTelemetry variable names.
+	observabilityMode := getEnv("OBSERVABILITY_MODE", "otel")
+	otlpEndpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	otlpProtocol := getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	otlpInsecure, err := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", false)
+	if err != nil {
+		return nil, err
+	}
+	otlpCertificate := getEnv("OTEL_EXPORTER_OTLP_CERTIFICATE", "")
+	otlpClientCertificate := getEnv("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", "")
+	otlpClientKey := getEnv("OTEL_EXPORTER_OTLP_CLIENT_KEY", "")
+	otlpHeaders := getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")
+	serviceName := getEnv("OTEL_SERVICE_NAME", "telemetry-tracker")
+	serviceVersion := getEnv("SERVICE_VERSION", "")
+	environment := getEnv("DEPLOYMENT_ENVIRONMENT", "")
+
+	prometheusEnabled, err := getEnvBool("PROMETHEUS_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	metricsAddr := getEnv("METRICS_ADDR", "")
+
+	healthCheckTimeout, err := getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
 
 	// Prefer DATABASE_URL if provided, otherwise construct DSN
 	dsn := os.Getenv("DATABASE_URL")
@@ -52,6 +272,14 @@ func Load() (*Config, error) {
 		slog.Info("Using DATABASE_URL environment variable for DB connection")
 		// Optionally parse DSN here to populate individual fields if needed elsewhere
 	}
+	if storageDSN == "" {
+		switch storageDriver {
+		case "postgres":
+			storageDSN = dsn
+		case "sqlite":
+			storageDSN = "telemetry.db"
+		}
+	}
 
 
 	return &Config{
@@ -62,6 +290,75 @@ func Load() (*Config, error) {
 		DBPassword: dbPassword, // Be careful logging this
 		DBName:     dbName,
 		DSN:        dsn,
+
+		StorageDriver: storageDriver,
+		StorageDSN:    storageDSN,
+		AutoMigrate:   autoMigrate,
+
+		PartitionInterval:      partitionInterval,
+		PartitionPremake:       partitionPremake,
+		PartitionCheckInterval: partitionCheckInterval,
+
+		RetentionDefault:   retentionDefault,
+		RetentionRules:     retentionRules,
+		RetentionInterval:  retentionInterval,
+		RetentionBatchSize: retentionBatchSize,
+
+		QueueEnabled:       queueEnabled,
+		QueueCapacity:      queueCapacity,
+		QueueWorkers:       queueWorkers,
+		QueueFlushSize:     queueFlushSize,
+		QueueFlushInterval: queueFlushInterval,
+
+		SpoolEnabled:        spoolEnabled,
+		SpoolDir:            spoolDir,
+		SpoolSegmentBytes:   spoolSegmentBytes,
+		SpoolSync:           spoolSync,
+		SpoolSyncInterval:   spoolSyncInterval,
+		SpoolReplayInterval: spoolReplayInterval,
+		SpoolWriteTimeout:   spoolWriteTimeout,
+
+		DecompressMaxCompressedBytes: decompressMaxCompressedBytes,
+		DecompressMaxBytes:           decompressMaxBytes,
+		DecompressMaxRatio:           decompressMaxRatio,
+
+		SchemaDir:          schemaDir,
+		UnknownEventPolicy: unknownEventPolicy,
+		AdminToken:         adminToken,
+
+		LogEventTypeAttribute: logEventTypeAttribute,
+		GRPCAddr:              grpcAddr,
+		CORSAllowedOrigins:    corsAllowedOrigins,
+
+		AuthEnabled:    authEnabled,
+		APIKeyCacheTTL: apiKeyCacheTTL,
+
+		SigningEnabled:     signingEnabled,
+		SignatureTolerance: signatureTolerance,
+
+		RateLimitEnabled:   rateLimitEnabled,
+		RateLimitKey:       rateLimitKey,
+		RateLimitTiers:     rateLimitTiers,
+		QuotaDailyEvents:   quotaDailyEvents,
+		TenantTiers:        tenantTiers,
+		QuotaFlushInterval: quotaFlushInterval,
+
+		ObservabilityMode:     observabilityMode,
+		OTLPEndpoint:          otlpEndpoint,
+		OTLPProtocol:          otlpProtocol,
+		OTLPInsecure:          otlpInsecure,
+		OTLPCertificate:       otlpCertificate,
+		OTLPClientCertificate: otlpClientCertificate,
+		OTLPClientKey:         otlpClientKey,
+		OTLPHeaders:           otlpHeaders,
+		ServiceName:           serviceName,
+		ServiceVersion:        serviceVersion,
+		Environment:           environment,
+
+		PrometheusEnabled: prometheusEnabled,
+		MetricsAddr:       metricsAddr,
+
+		HealthCheckTimeout: healthCheckTimeout,
 	}, nil
 }
 
@@ -71,4 +368,49 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// getEnvInt reads a positive integer environment variable or returns the default.
+func getEnvInt(key string, fallback int) (int, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive, got %d", key, n)
+	}
+	return n, nil
+}
+
+// getEnvBool reads a boolean environment variable or returns the default.
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// getEnvDuration reads a Go duration (e.g. "500ms", "2s") or returns the default.
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive, got %s", key, d)
+	}
+	return d, nil
+}
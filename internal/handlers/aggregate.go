@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// maxAggregateBuckets caps the number of time buckets a single request may span.
+const maxAggregateBuckets = 10000
+
+// bucketSizes maps the supported bucket units to their nominal length, used
+// to bound the requested range.
+var bucketSizes = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// pathSegmentPattern restricts group_by path segments to plain JSON keys.
+var pathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// aggregatePoint is the count for one time bucket.
+type aggregatePoint struct {
+	Bucket string `json:"bucket"`
+	Count  int64  `json:"count"`
+}
+
+// aggregateSeries is the count over time for one event type and group.
+type aggregateSeries struct {
+	EventType string           `json:"event_type"`
+	Group     *string          `json:"group,omitempty"`
+	Total     int64            `json:"total"`
+	Points    []aggregatePoint `json:"points"`
+}
+
+// aggregateResponse is the body returned by GET /events/aggregate.
+type aggregateResponse struct {
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	Since    time.Time         `json:"since"`
+	Until    time.Time         `json:"until"`
+	GroupBy  string            `json:"group_by,omitempty"`
+	Series   []aggregateSeries `json:"series"`
+}
+
+// ServeAggregate handles GET requests to /events/aggregate. It returns event
+// counts per event type and time bucket as one series per event type (and
+// group_by value), ready to plot. Supported query parameters:
+//
+//	bucket      minute, hour (default) or day
+//	tz          IANA time zone that buckets align to (default UTC)
+//	since       start of the range (RFC 3339, default 24h before until)
+//	until       end of the range, exclusive (RFC 3339, default now)
+//	event_type  repeatable; restricts the event types counted
+//	data        JSON object the event data must contain
+//	group_by    path into data to group by as well, e.g. data.platform
+func (h *QueryHandler) ServeAggregate(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "AggregateEvents")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for aggregate handler")
+	}
+
+	q, loc, err := parseAggregateQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		logger.Warn("Invalid aggregate query", slog.Any("error", err))
+		span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	q.TenantID = appmiddleware.GetTenantFromContext(ctx)
+	span.SetAttributes(attribute.String("bucket", q.Bucket), attribute.String("timezone", q.Timezone))
+
+	rows, err := h.Reader.AggregateEvents(ctx, q)
+	if err != nil {
+		h.Metrics.DBErrorsTotal.Add(ctx, 1)
+		logger.Error("Failed to aggregate events", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to aggregate events")
+		return
+	}
+
+	resp := aggregateResponse{
+		Bucket:   q.Bucket,
+		Timezone: q.Timezone,
+		Since:    q.Since,
+		Until:    q.Until,
+		Series:   buildSeries(rows, loc),
+	}
+	if len(q.GroupByPath) > 0 {
+		resp.GroupBy = "data." + strings.Join(q.GroupByPath, ".")
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// buildSeries pivots rows ordered by bucket into one series per event type
+// and group, ordered by event type then group.
+func buildSeries(rows []storage.AggregateRow, loc *time.Location) []aggregateSeries {
+	type key struct {
+		eventType string
+		group     string
+		hasGroup  bool
+	}
+	index := make(map[key]int)
+	series := make([]aggregateSeries, 0)
+	for _, row := range rows {
+		k := key{eventType: row.EventType}
+		if row.Group != nil {
+			k.group, k.hasGroup = *row.Group, true
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(series)
+			index[k] = i
+			series = append(series, aggregateSeries{EventType: row.EventType, Group: row.Group})
+		}
+		series[i].Total += row.Count
+		series[i].Points = append(series[i].Points, aggregatePoint{
+			Bucket: row.Bucket.In(loc).Format(time.RFC3339),
+			Count:  row.Count,
+		})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i], series[j]
+		if a.EventType != b.EventType {
+			return a.EventType < b.EventType
+		}
+		if a.Group == nil || b.Group == nil {
+			return a.Group == nil && b.Group != nil
+		}
+		return *a.Group < *b.Group
+	})
+	return series
+}
+
+// parseAggregateQuery translates URL query parameters into a storage
+// aggregate query and the location buckets are reported in.
+func parseAggregateQuery(values url.Values, now time.Time) (storage.AggregateQuery, *time.Location, error) {
+	q := storage.AggregateQuery{
+		Bucket:     "hour",
+		Timezone:   "UTC",
+		EventTypes: values["event_type"],
+	}
+
+	if raw := values.Get("bucket"); raw != "" {
+		if _, ok := bucketSizes[raw]; !ok {
+			return storage.AggregateQuery{}, nil, errors.New("'bucket' must be minute, hour or day")
+		}
+		q.Bucket = raw
+	}
+
+	if raw := values.Get("tz"); raw != "" {
+		q.Timezone = raw
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return storage.AggregateQuery{}, nil, fmt.Errorf("'tz' is not a known time zone: %s", q.Timezone)
+	}
+
+	q.Until = now.UTC()
+	if raw := values.Get("until"); raw != "" {
+		if q.Until, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return storage.AggregateQuery{}, nil, errors.New("'until' must be an RFC 3339 timestamp")
+		}
+		q.Until = q.Until.UTC()
+	}
+	q.Since = q.Until.Add(-24 * time.Hour)
+	if raw := values.Get("since"); raw != "" {
+		if q.Since, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			return storage.AggregateQuery{}, nil, errors.New("'since' must be an RFC 3339 timestamp")
+		}
+		q.Since = q.Since.UTC()
+	}
+	if !q.Since.Before(q.Until) {
+		return storage.AggregateQuery{}, nil, errors.New("'since' must be before 'until'")
+	}
+	if q.Until.Sub(q.Since)/bucketSizes[q.Bucket] > maxAggregateBuckets {
+		return storage.AggregateQuery{}, nil, fmt.Errorf("range spans more than %d %s buckets", maxAggregateBuckets, q.Bucket)
+	}
+
+	if raw := values.Get("data"); raw != "" {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+			return storage.AggregateQuery{}, nil, errors.New("'data' must be a JSON object")
+		}
+		q.DataContains = json.RawMessage(raw)
+	}
+
+	if raw := values.Get("group_by"); raw != "" {
+		path, ok := strings.CutPrefix(raw, "data.")
+		if !ok {
+			return storage.AggregateQuery{}, nil, errors.New("'group_by' must be a path into data, e.g. data.platform")
+		}
+		q.GroupByPath = strings.Split(path, ".")
+		for _, segment := range q.GroupByPath {
+			if !pathSegmentPattern.MatchString(segment) {
+				return storage.AggregateQuery{}, nil, fmt.Errorf("'group_by' has an invalid path segment: %q", segment)
+			}
+		}
+	}
+
+	return q, loc, nil
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxAPIKeyRequestBytes caps the size of an admin API key request body.
+const maxAPIKeyRequestBytes = 4 << 10
+
+// defaultRotationGrace is how long a rotated key keeps working when the
+// request does not say.
+const defaultRotationGrace = 24 * time.Hour
+
+// APIKeyHandler exposes API key management under /admin/keys.
+type APIKeyHandler struct {
+	Keys *apikey.Manager
+	Obs  observability.Provider
+}
+
+// NewAPIKeyHandler constructs an APIKeyHandler.
+func NewAPIKeyHandler(keys *apikey.Manager, obs observability.Provider) *APIKeyHandler {
+	return &APIKeyHandler{Keys: keys, Obs: obs}
+}
+
+// Routes mounts the admin API key endpoints on r.
+func (h *APIKeyHandler) Routes(r chi.Router) {
+	r.Get("/", h.List)
+	r.Post("/", h.Create)
+	r.Get("/{id}", h.Get)
+	r.Delete("/{id}", h.Revoke)
+	r.Post("/{id}/rotate", h.Rotate)
+}
+
+// createKeyRequest is the body of POST /admin/keys.
+type createKeyRequest struct {
+	TenantID  string   `json:"tenant_id"`
+	Name      string   `json:"name"`
+	ExpiresIn duration `json:"expires_in"` // Optional key lifetime
+}
+
+// rotateRequest is the optional body of POST /admin/keys/{id}/rotate and
+// /admin/signing-secrets/{id}/rotate.
+type rotateRequest struct {
+	GracePeriod *duration `json:"grace_period"` // How long the old key keeps working
+}
+
+// issuedKeyResponse carries a newly issued key. This is the only time the
+// key itself is returned.
+type issuedKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey storage.APIKey `json:"api_key"`
+}
+
+// duration decodes a Go duration string such as "720h".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("durations must be strings such as \"24h\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return errors.New("durations must not be negative")
+	}
+	*d = duration(v)
+	return nil
+}
+
+// List handles GET /admin/keys, optionally filtered by ?tenant_id=.
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ListAPIKeys")
+	defer span.End()
+
+	keys, err := h.Keys.List(ctx, r.URL.Query().Get("tenant_id"))
+	if err != nil {
+		h.logger(ctx).Error("Failed to list API keys", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []storage.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+// Get handles GET /admin/keys/{id}.
+func (h *APIKeyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "GetAPIKey")
+	defer span.End()
+
+	key, err := h.Keys.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(ctx, w, "Failed to get API key", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// Create handles POST /admin/keys. The response holds the new key, which
+// cannot be retrieved again.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "CreateAPIKey")
+	defer span.End()
+
+	var req createKeyRequest
+	if !decodeAdminRequest(w, r, &req) {
+		return
+	}
+	span.SetAttributes(attribute.String("tenant.id", req.TenantID))
+
+	key, record, err := h.Keys.Create(ctx, req.TenantID, req.Name, time.Duration(req.ExpiresIn))
+	if err != nil {
+		h.writeError(ctx, w, "Failed to create API key", err)
+		return
+	}
+	h.logger(ctx).Info("API key created", slog.String("api_key_id", record.ID), slog.String("tenant_id", record.TenantID))
+	writeJSON(w, http.StatusCreated, issuedKeyResponse{Key: key, APIKey: record})
+}
+
+// Rotate handles POST /admin/keys/{id}/rotate. The old key keeps working
+// for the grace period (24h unless given) so clients can switch over.
+func (h *APIKeyHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "RotateAPIKey")
+	defer span.End()
+
+	var req rotateRequest
+	if r.ContentLength != 0 && !decodeAdminRequest(w, r, &req) {
+		return
+	}
+	grace := defaultRotationGrace
+	if req.GracePeriod != nil {
+		grace = time.Duration(*req.GracePeriod)
+	}
+
+	id := chi.URLParam(r, "id")
+	key, record, err := h.Keys.Rotate(ctx, id, grace)
+	if err != nil {
+		h.writeError(ctx, w, "Failed to rotate API key", err)
+		return
+	}
+	h.logger(ctx).Info("API key rotated",
+		slog.String("api_key_id", id),
+		slog.String("replacement_id", record.ID),
+		slog.Duration("grace_period", grace),
+	)
+	writeJSON(w, http.StatusCreated, issuedKeyResponse{Key: key, APIKey: record})
+}
+
+// Revoke handles DELETE /admin/keys/{id}. The key stops working at once on
+// this instance and within the key cache TTL on others.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "RevokeAPIKey")
+	defer span.End()
+
+	record, err := h.Keys.Revoke(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(ctx, w, "Failed to revoke API key", err)
+		return
+	}
+	h.logger(ctx).Info("API key revoked", slog.String("api_key_id", record.ID), slog.String("tenant_id", record.TenantID))
+	writeJSON(w, http.StatusOK, record)
+}
+
+// writeError maps key management errors to responses.
+func (h *APIKeyHandler) writeError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrKeyNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, apikey.ErrInvalidTenant):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, apikey.ErrInactive):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	default:
+		h.logger(ctx).Error(msg, slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *APIKeyHandler) logger(ctx context.Context) *slog.Logger {
+	if logger := appmiddleware.GetLoggerFromContext(ctx); logger != nil {
+		return logger
+	}
+	return h.Obs.Logger()
+}
+
+// decodeAdminRequest decodes a small JSON request body into v, responding
+// 400 when it is malformed.
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIKeyRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return false
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: unexpected data after object"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// maxBatchEvents caps the number of events accepted in a single batch request.
+	maxBatchEvents = 1000
+	// maxBatchBytes caps the size of a batch request body.
+	maxBatchBytes = 5 << 20
+)
+
+const (
+	batchStatusAccepted    = "accepted"
+	batchStatusQuarantined = "quarantined"
+	batchStatusRejected    = "rejected"
+	batchStatusDuplicate   = "duplicate"
+)
+
+// batchEnvelope is the object form of a batch request: {"events": [...]}.
+type batchEnvelope struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+// batchItemResult reports the outcome for a single event in a batch.
+type batchItemResult struct {
+	Index      int                `json:"index"`
+	Status     string             `json:"status"`
+	Reason     string             `json:"reason,omitempty"`
+	Violations []schema.Violation `json:"violations,omitempty"`
+}
+
+// batchResponse is the body returned by POST /events/batch.
+type batchResponse struct {
+	Accepted   int               `json:"accepted"`
+	Rejected   int               `json:"rejected"`
+	Duplicates int               `json:"duplicates"`
+	Results    []batchItemResult `json:"results"`
+}
+
+// ServeBatch handles POST requests to /events/batch. The body is either a
+// JSON array of events, an envelope of the form {"events": [...]}, or a
+// CloudEvents batch. Each event is validated independently and the valid
+// ones are stored together.
+func (h *EventHandler) ServeBatch(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeBatch")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for batch handler")
+	}
+
+	items, decode, status := parseBatchRequest(w, r, logger)
+	if status != http.StatusOK {
+		http.Error(w, fmt.Sprintf("Request Error: %d", status), status)
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		return
+	}
+
+	tenantID := appmiddleware.GetTenantFromContext(ctx)
+	resp := batchResponse{Results: make([]batchItemResult, len(items))}
+	events := make([]storage.Event, 0, len(items))
+	// indexes maps each entry of events back to its item.
+	indexes := make([]int, 0, len(items))
+	for i, item := range items {
+		event, err := decode(item)
+		if err == nil {
+			err = h.checkSchema(ctx, &event)
+		}
+		if err != nil {
+			result := batchItemResult{Index: i, Status: batchStatusRejected, Reason: err.Error()}
+			var ve *schema.ValidationError
+			if errors.As(err, &ve) {
+				result.Violations = ve.Violations
+			}
+			resp.Results[i] = result
+			resp.Rejected++
+			continue
+		}
+		resp.Results[i] = batchItemResult{Index: i, Status: batchStatusAccepted}
+		if event.Quarantined {
+			resp.Results[i].Status = batchStatusQuarantined
+		}
+		resp.Accepted++
+		event.TenantID = tenantID
+		events = append(events, event)
+		indexes = append(indexes, i)
+
+		h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("event_type", event.EventType)),
+		)
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch.size", len(items)),
+		attribute.Int("batch.accepted", resp.Accepted),
+		attribute.Int("batch.rejected", resp.Rejected),
+	)
+	logger = logger.With(slog.Int("batch_size", len(items)))
+
+	if len(events) > 0 {
+		duplicates, err := h.Store.StoreEvents(ctx, events)
+		if err != nil {
+			status := h.storeFailed(ctx, w, len(events), err)
+			logger.Error("Failed to store event batch", slog.Any("error", err))
+			http.Error(w, http.StatusText(status), status)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to store event batch")
+			return
+		}
+		for _, d := range duplicates {
+			resp.Results[indexes[d]].Status = batchStatusDuplicate
+		}
+		resp.Accepted -= len(duplicates)
+		resp.Duplicates = len(duplicates)
+		span.SetAttributes(attribute.Int("batch.duplicates", resp.Duplicates))
+		h.Metrics.EventsStoredTotal.Add(ctx, int64(len(events)-len(duplicates)))
+		h.countUsage(ctx, len(events)-len(duplicates))
+	}
+
+	logger.Info("Event batch processed",
+		slog.Int("accepted", resp.Accepted),
+		slog.Int("rejected", resp.Rejected),
+		slog.Int("duplicates", resp.Duplicates),
+	)
+
+	// A batch of duplicates succeeds, as a repeated single event does.
+	status = http.StatusAccepted
+	if len(events) == 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// parseBatchRequest reads the batch body and returns the raw items without
+// decoding them, so that a single bad event does not fail the whole request,
+// along with the function that decodes an item.
+func parseBatchRequest(w http.ResponseWriter, r *http.Request, logger *slog.Logger) ([]json.RawMessage, func(json.RawMessage) (storage.Event, error), int) {
+	// A CloudEvents batch is always a JSON array of structured CloudEvents.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	cloudEvents := mediaType == cloudEventsBatchJSON
+	if !cloudEvents && r.Header.Get("Content-Type") != "application/json" {
+		logger.Warn("Invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
+		return nil, nil, http.StatusUnsupportedMediaType
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&raw); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			logger.Warn("Batch body too large", slog.Int64("limit", maxErr.Limit))
+			return nil, nil, http.StatusRequestEntityTooLarge
+		}
+		logger.Warn("Failed to decode JSON body", slog.Any("error", err))
+		return nil, nil, http.StatusBadRequest
+	}
+
+	var items []json.RawMessage
+	switch trimmed := bytes.TrimSpace(raw); {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			logger.Warn("Failed to decode batch array", slog.Any("error", err))
+			return nil, nil, http.StatusBadRequest
+		}
+	case len(trimmed) > 0 && trimmed[0] == '{' && !cloudEvents:
+		var envelope batchEnvelope
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&envelope); err != nil {
+			logger.Warn("Failed to decode batch envelope", slog.Any("error", err))
+			return nil, nil, http.StatusBadRequest
+		}
+		items = envelope.Events
+	default:
+		logger.Warn("Batch body is neither an array nor an envelope")
+		return nil, nil, http.StatusBadRequest
+	}
+
+	if len(items) == 0 {
+		logger.Warn("Empty event batch")
+		return nil, nil, http.StatusBadRequest
+	}
+	if len(items) > maxBatchEvents {
+		logger.Warn("Too many events in batch", slog.Int("count", len(items)), slog.Int("limit", maxBatchEvents))
+		return nil, nil, http.StatusRequestEntityTooLarge
+	}
+
+	if cloudEvents {
+		return items, decodeCloudEvent, http.StatusOK
+	}
+	return items, decodeEvent, http.StatusOK
+}
+
+// decodeEvent decodes and validates a single batch or stream entry with
+// the same rules parseEventRequest applies to a standalone event.
+func decodeEvent(item json.RawMessage) (storage.Event, error) {
+	var event storage.Event
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&event); err != nil {
+		return storage.Event{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return storage.Event{}, errors.New("invalid event: unexpected data after event")
+	}
+	if err := validateEvent(&event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
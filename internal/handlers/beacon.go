@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+// transparentGIF is a 1x1 transparent GIF, the body of every successful
+// pixel response.
+var transparentGIF = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+// pixelParams are the query parameters of a pixel request that are not
+// event data. api_key authenticates clients that cannot set headers.
+var pixelParams = map[string]bool{
+	"event_type":     true,
+	"event_id":       true,
+	"timestamp":      true,
+	"schema_version": true,
+	"data":           true,
+	"api_key":        true,
+}
+
+// ServeBeacon handles POST requests to /events/beacon. It accepts the same
+// event as /events, and also as the text/plain body navigator.sendBeacon
+// sends a string with, which browsers do not preflight.
+func (h *EventHandler) ServeBeacon(w http.ResponseWriter, r *http.Request) {
+	h.serveEvent(w, r, "ServeBeacon", parseBeaconRequest, writeJSON)
+}
+
+// ServePixel handles GET requests to /p.gif, reading the event from the
+// query string and responding with a transparent 1x1 GIF, for tracking
+// from emails and pages without JavaScript.
+func (h *EventHandler) ServePixel(w http.ResponseWriter, r *http.Request) {
+	h.serveEvent(w, r, "ServePixel", parsePixelRequest, writePixel)
+}
+
+// parseBeaconRequest reads a beacon, parsing a text/plain body as a JSON
+// event and anything else as parseEventRequest does.
+func parseBeaconRequest(r *http.Request, logger *slog.Logger) (storage.Event, int) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/plain" {
+		return parseEventRequest(r, logger)
+	}
+	event, status := decodeEventBody(r, logger)
+	if status != http.StatusOK {
+		return storage.Event{}, status
+	}
+	return checkEventRequest(r, logger, event)
+}
+
+// parsePixelRequest reads an event from the event_type, event_id,
+// timestamp, schema_version and data query parameters. data is JSON; when
+// it is absent, the remaining parameters become the data, as strings.
+func parsePixelRequest(r *http.Request, logger *slog.Logger) (storage.Event, int) {
+	query := r.URL.Query()
+	event := storage.Event{EventType: query.Get("event_type"), EventID: query.Get("event_id")}
+	if v := query.Get("timestamp"); v != "" {
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			logger.Warn("Invalid timestamp in pixel request", slog.Any("error", err))
+			return storage.Event{}, http.StatusBadRequest
+		}
+		event.Timestamp = ts
+	}
+	if v := query.Get("schema_version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			logger.Warn("Invalid schema_version in pixel request", slog.Any("error", err))
+			return storage.Event{}, http.StatusBadRequest
+		}
+		event.SchemaVersion = version
+	}
+
+	if query.Has("data") {
+		data := []byte(query.Get("data"))
+		if !json.Valid(data) {
+			logger.Warn("Invalid data in pixel request")
+			return storage.Event{}, http.StatusBadRequest
+		}
+		event.Data = data
+	} else {
+		fields := make(map[string]string)
+		for name := range query {
+			if !pixelParams[name] {
+				fields[name] = query.Get(name)
+			}
+		}
+		if len(fields) > 0 {
+			// A map of strings always marshals.
+			event.Data, _ = json.Marshal(fields)
+		}
+	}
+	return checkEventRequest(r, logger, event)
+}
+
+// writePixel responds with the tracking pixel in place of the JSON body
+// /events would return.
+func writePixel(w http.ResponseWriter, _ int, _ any) {
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(transparentGIF)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+const (
+	// cloudEventsJSON is the media type of a CloudEvent in structured mode.
+	cloudEventsJSON = "application/cloudevents+json"
+	// cloudEventsBatchJSON is the media type of a JSON array of structured
+	// CloudEvents.
+	cloudEventsBatchJSON = "application/cloudevents-batch+json"
+	// cloudEventsSpecVersion is the only CloudEvents version accepted.
+	cloudEventsSpecVersion = "1.0"
+)
+
+var errUnsupportedDataContentType = errors.New("CloudEvents data must be JSON")
+
+// cloudEvent is a CloudEvent in the JSON event format. Extension attributes
+// are accepted and dropped.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      *string         `json:"data_base64"`
+}
+
+// event maps the CloudEvent onto a storage.Event and validates it. An id
+// that is a UUID or ULID also becomes the event_id, so that redelivered
+// CloudEvents are deduplicated.
+func (ce cloudEvent) event() (storage.Event, error) {
+	switch {
+	case ce.SpecVersion != cloudEventsSpecVersion:
+		return storage.Event{}, fmt.Errorf("unsupported CloudEvents specversion %q", ce.SpecVersion)
+	case ce.ID == "":
+		return storage.Event{}, errors.New("missing CloudEvents 'id' attribute")
+	case ce.Source == "":
+		return storage.Event{}, errors.New("missing CloudEvents 'source' attribute")
+	case ce.Type == "":
+		return storage.Event{}, errors.New("missing CloudEvents 'type' attribute")
+	case ce.DataBase64 != nil:
+		return storage.Event{}, errUnsupportedDataContentType
+	case ce.DataContentType != "" && !isJSONMediaType(ce.DataContentType):
+		return storage.Event{}, errUnsupportedDataContentType
+	}
+
+	event := storage.Event{
+		EventType:     ce.Type,
+		Data:          ce.Data,
+		Source:        ce.Source,
+		SourceEventID: ce.ID,
+		Subject:       ce.Subject,
+	}
+	if ce.Time != "" {
+		ts, err := time.Parse(time.RFC3339Nano, ce.Time)
+		if err != nil {
+			return storage.Event{}, fmt.Errorf("invalid CloudEvents 'time' attribute: %w", err)
+		}
+		event.Timestamp = ts
+	}
+	if uuidPattern.MatchString(ce.ID) || ulidPattern.MatchString(ce.ID) {
+		event.EventID = ce.ID
+	}
+	if err := validateEvent(&event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+// decodeCloudEvent decodes and validates a single structured-mode CloudEvent.
+func decodeCloudEvent(item json.RawMessage) (storage.Event, error) {
+	var ce cloudEvent
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	if err := decoder.Decode(&ce); err != nil {
+		return storage.Event{}, fmt.Errorf("invalid CloudEvent: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return storage.Event{}, errors.New("invalid CloudEvent: unexpected data after event")
+	}
+	return ce.event()
+}
+
+// isBinaryCloudEvent reports whether r carries a CloudEvent in binary mode,
+// with its attributes in ce-* headers.
+func isBinaryCloudEvent(r *http.Request) bool {
+	return r.Header.Get("Ce-Specversion") != ""
+}
+
+// parseBinaryCloudEvent reads a binary-mode CloudEvent: the attributes come
+// from ce-* headers and the body is the data, described by Content-Type.
+func parseBinaryCloudEvent(r *http.Request) (storage.Event, int, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" && !isJSONMediaType(contentType) {
+		return storage.Event{}, http.StatusUnsupportedMediaType, errUnsupportedDataContentType
+	}
+
+	var data json.RawMessage
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return storage.Event{}, http.StatusRequestEntityTooLarge, err
+		}
+		return storage.Event{}, http.StatusBadRequest, fmt.Errorf("invalid CloudEvents data: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return storage.Event{}, http.StatusBadRequest, errors.New("invalid CloudEvents data: unexpected data after value")
+	}
+
+	event, err := cloudEvent{
+		SpecVersion: r.Header.Get("Ce-Specversion"),
+		ID:          r.Header.Get("Ce-Id"),
+		Source:      r.Header.Get("Ce-Source"),
+		Type:        r.Header.Get("Ce-Type"),
+		Subject:     r.Header.Get("Ce-Subject"),
+		Time:        r.Header.Get("Ce-Time"),
+		Data:        data,
+	}.event()
+	if err != nil {
+		return storage.Event{}, http.StatusBadRequest, err
+	}
+	return event, http.StatusOK, nil
+}
+
+// isJSONMediaType reports whether contentType is application/json or a
+// +json structured syntax suffix type.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -3,13 +3,21 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/kakhavain/telemetry-tracker/internal/metrics"
 	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/queue"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
 	"github.com/kakhavain/telemetry-tracker/internal/storage"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -17,22 +25,52 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// storer defines the interface for storing events.
-type storer interface {
-	StoreEvent(ctx context.Context, event storage.Event) error
-	Close()
+var (
+	errMissingEventType       = errors.New("missing 'event_type' field")
+	errInvalidEventID         = errors.New("'event_id' must be a UUID or ULID")
+	errIdempotencyKeyMismatch = errors.New("'Idempotency-Key' header does not match 'event_id'")
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	ulidPattern = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+)
+
+// eventResponse is the body returned for a single ingested event.
+type eventResponse struct {
+	Status  string `json:"status"`
+	EventID string `json:"event_id,omitempty"`
 }
 
+// errorResponse is the structured body returned for rejected requests,
+// including the violations when an event fails schema validation.
+type errorResponse struct {
+	Error      string             `json:"error"`
+	EventType  string             `json:"event_type,omitempty"`
+	Version    int                `json:"version,omitempty"`
+	Violations []schema.Violation `json:"violations,omitempty"`
+}
+
+// maxEventBytes caps the size of a single-event request body. It matches
+// the cap on a line of an NDJSON stream.
+const maxEventBytes = 1 << 20
+
 type eventTypeKey struct{}
 
 // EventHandler handles incoming telemetry events.
 type EventHandler struct {
-	Store   storer
+	Store   storage.Writer
 	Metrics *metrics.Registry
 	Obs     observability.Provider
+	Schemas *schema.Registry // Optional; when set, event data is validated against it
+	Quota   *ratelimit.Quota // Optional; when set, stored events count towards daily quotas
+
+	// LogEventTypeAttribute names the OTLP log attribute read as event_type
+	// for records without an event name.
+	LogEventTypeAttribute string
 }
 
-func NewEventHandler(store storer, metrics *metrics.Registry, obs observability.Provider) *EventHandler {
+func NewEventHandler(store storage.Writer, metrics *metrics.Registry, obs observability.Provider) *EventHandler {
 	return &EventHandler{
 		Store:   store,
 		Metrics: metrics,
@@ -40,10 +78,23 @@ func NewEventHandler(store storer, metrics *metrics.Registry, obs observability.
 	}
 }
 
-// ServeHTTP handles POST requests to /events.
+// ServeHTTP handles POST requests to /events. A CloudEvents batch is
+// handled as if it had been posted to /events/batch.
 func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == cloudEventsBatchJSON {
+		h.ServeBatch(w, r)
+		return
+	}
+	h.serveEvent(w, r, "ServeHTTP", parseEventRequest, writeJSON)
+}
+
+// serveEvent ingests the single event that parse reads from r, the
+// handling shared by /events, /events/beacon and /p.gif, and reports the
+// outcome with respond.
+func (h *EventHandler) serveEvent(w http.ResponseWriter, r *http.Request, spanName string,
+	parse func(*http.Request, *slog.Logger) (storage.Event, int), respond func(http.ResponseWriter, int, any)) {
 	// Start a span for this handler.
-	ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeHTTP")
+	ctx, span := h.Obs.Tracer().Start(r.Context(), spanName)
 	defer span.End()
 
 	logger := appmiddleware.GetLoggerFromContext(ctx)
@@ -52,60 +103,234 @@ func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		logger.Warn("Logger not found in context for event handler")
 	}
 
-	event, status := parseEventRequest(r, logger)
+	r.Body = http.MaxBytesReader(w, r.Body, maxEventBytes)
+	event, status := parse(r, logger)
 	if status != http.StatusOK {
 		http.Error(w, fmt.Sprintf("Request Error: %d", status), status)
 		span.SetAttributes(attribute.Int("http.status_code", status))
 		return
 	}
 
+	event.TenantID = appmiddleware.GetTenantFromContext(ctx)
+
 	// Enrich logger with event type.
 	logger = logger.With(slog.String("event_type", event.EventType))
 	ctx = context.WithValue(ctx, eventTypeKey{}, event.EventType)
 
+	if err := h.checkSchema(ctx, &event); err != nil {
+		logger.Warn("Event failed schema validation", slog.Any("error", err))
+		span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+		writeSchemaError(w, event, err)
+		return
+	}
+
 	// Record metric for events received.
 	h.Metrics.EventsReceivedTotal.Add(context.Background(), 1,
 		metric.WithAttributes(attribute.String("event_type", event.EventType)),
 	)
 	logger.Debug("Received event data")
 
-	// Store the event.
-	if err := h.Store.StoreEvent(ctx, event); err != nil {
-		h.Metrics.DBErrorsTotal.Add(ctx, 1)
+	// Store the event. A duplicate event_id is a successful retry, not an error.
+	err := h.Store.StoreEvent(ctx, event)
+	if errors.Is(err, storage.ErrDuplicateEvent) {
+		logger.Info("Duplicate event ignored", slog.String("event_id", event.EventID))
+		span.SetAttributes(attribute.Bool("duplicate", true))
+		respond(w, http.StatusOK, eventResponse{Status: "duplicate", EventID: event.EventID})
+		return
+	}
+	if err != nil {
+		status := h.storeFailed(ctx, w, 1, err)
 		logger.Error("Failed to store event", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, http.StatusText(status), status)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "Failed to store event")
 		return
 	}
 
 	h.Metrics.EventsStoredTotal.Add(ctx, 1)
+	h.countUsage(ctx, 1)
 	logger.Info("Event stored successfully")
 	span.AddEvent("Event stored successfully", trace.WithAttributes(attribute.String("event_type", event.EventType)))
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	_, _ = w.Write([]byte(`{"status": "accepted"}`))
+	resp := eventResponse{Status: "accepted", EventID: event.EventID}
+	if event.Quarantined {
+		resp.Status = "quarantined"
+	}
+	respond(w, http.StatusAccepted, resp)
+}
+
+// checkSchema validates event data against the schema registry, if one is
+// configured, and flags events of unregistered types for quarantine when
+// that is the configured policy.
+func (h *EventHandler) checkSchema(ctx context.Context, event *storage.Event) error {
+	if h.Schemas == nil {
+		return nil
+	}
+	quarantine, err := h.Schemas.Validate(event.EventType, event.SchemaVersion, event.Data)
+	if err != nil {
+		reason := "schema_invalid"
+		if errors.Is(err, schema.ErrUnknownEventType) || errors.Is(err, schema.ErrUnknownVersion) {
+			reason = "unknown_schema"
+		}
+		h.Metrics.EventsRejectedTotal.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("reason", reason),
+			attribute.String("event_type", event.EventType),
+		))
+		return err
+	}
+	event.Quarantined = quarantine
+	return nil
+}
+
+// writeSchemaError responds 400 with the failing JSON pointers, if any.
+func writeSchemaError(w http.ResponseWriter, event storage.Event, err error) {
+	resp := errorResponse{Error: err.Error(), EventType: event.EventType, Version: event.SchemaVersion}
+	var ve *schema.ValidationError
+	if errors.As(err, &ve) {
+		resp.Error = "schema validation failed"
+		resp.Version = ve.Version
+		resp.Violations = ve.Violations
+	}
+	writeJSON(w, http.StatusBadRequest, resp)
+}
+
+// storeFailed records a failed store call and returns the status to respond
+// with. A full or closing write-behind queue is load shedding rather than a
+// database fault, so it maps to 503 with a Retry-After hint.
+func (h *EventHandler) storeFailed(ctx context.Context, w http.ResponseWriter, events int, err error) int {
+	if h.shedLoad(ctx, events, err) {
+		w.Header().Set("Retry-After", "1")
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// shedLoad records a failed store call and reports whether it was load
+// shedding by the write-behind queue.
+func (h *EventHandler) shedLoad(ctx context.Context, events int, err error) bool {
+	if errors.Is(err, queue.ErrFull) || errors.Is(err, queue.ErrClosed) {
+		h.Metrics.EventsRejectedTotal.Add(ctx, int64(events),
+			metric.WithAttributes(attribute.String("reason", "queue_full")),
+		)
+		return true
+	}
+	h.Metrics.DBErrorsTotal.Add(ctx, 1)
+	return false
+}
+
+// countUsage counts stored events towards the tenant's daily quota.
+func (h *EventHandler) countUsage(ctx context.Context, events int) {
+	if h.Quota != nil {
+		h.Quota.Add(appmiddleware.GetTenantFromContext(ctx), events)
+	}
 }
 
+// parseEventRequest reads a single event, sent either as JSON or as a
+// CloudEvent in structured or binary mode.
 func parseEventRequest(r *http.Request, logger *slog.Logger) (storage.Event, int) {
-	if r.Header.Get("Content-Type") != "application/json" {
+	var (
+		event  storage.Event
+		status int
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case isBinaryCloudEvent(r):
+		var err error
+		if event, status, err = parseBinaryCloudEvent(r); err != nil {
+			logger.Warn("Invalid CloudEvent in request", slog.Any("error", err))
+			return storage.Event{}, status
+		}
+	case mediaType == cloudEventsJSON:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				logger.Warn("Event body too large", slog.Int64("limit", maxErr.Limit))
+				return storage.Event{}, http.StatusRequestEntityTooLarge
+			}
+			logger.Warn("Failed to read request body", slog.Any("error", err))
+			return storage.Event{}, http.StatusBadRequest
+		}
+		if event, err = decodeCloudEvent(body); err != nil {
+			logger.Warn("Invalid CloudEvent in request", slog.Any("error", err))
+			return storage.Event{}, http.StatusBadRequest
+		}
+	case r.Header.Get("Content-Type") == "application/json":
+		if event, status = decodeEventBody(r, logger); status != http.StatusOK {
+			return storage.Event{}, status
+		}
+	default:
 		logger.Warn("Invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
 		return storage.Event{}, http.StatusUnsupportedMediaType
 	}
+	return checkEventRequest(r, logger, event)
+}
 
+// checkEventRequest applies the Idempotency-Key header to a decoded event
+// and validates it.
+func checkEventRequest(r *http.Request, logger *slog.Logger, event storage.Event) (storage.Event, int) {
+	// Idempotency-Key supplies the event_id for clients that cannot add it
+	// to the body; if both are present they must agree.
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if event.EventID == "" {
+			event.EventID = key
+		} else if canonicalEventID(key) != canonicalEventID(event.EventID) {
+			logger.Warn("Invalid event in request", slog.Any("error", errIdempotencyKeyMismatch))
+			return storage.Event{}, http.StatusBadRequest
+		}
+	}
+
+	if err := validateEvent(&event); err != nil {
+		logger.Warn("Invalid event in request", slog.Any("error", err))
+		return storage.Event{}, http.StatusBadRequest
+	}
+
+	return event, http.StatusOK
+}
+
+// decodeEventBody decodes a request body holding one JSON event.
+func decodeEventBody(r *http.Request, logger *slog.Logger) (storage.Event, int) {
 	var event storage.Event
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&event); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			logger.Warn("Event body too large", slog.Int64("limit", maxErr.Limit))
+			return storage.Event{}, http.StatusRequestEntityTooLarge
+		}
 		logger.Warn("Failed to decode JSON body", slog.Any("error", err))
 		return storage.Event{}, http.StatusBadRequest
 	}
+	// A single event is expected; anything after it (e.g. an NDJSON stream
+	// sent to the wrong endpoint) would otherwise be silently dropped.
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		logger.Warn("Unexpected data after event in request body")
+		return storage.Event{}, http.StatusBadRequest
+	}
+	return event, http.StatusOK
+}
 
+// validateEvent applies the checks every ingested event must pass,
+// regardless of which endpoint received it, and canonicalizes the event_id.
+func validateEvent(event *storage.Event) error {
 	if event.EventType == "" {
-		logger.Warn("Missing 'event_type' field in request")
-		return storage.Event{}, http.StatusBadRequest
+		return errMissingEventType
+	}
+	if event.EventID != "" {
+		if !uuidPattern.MatchString(event.EventID) && !ulidPattern.MatchString(event.EventID) {
+			return errInvalidEventID
+		}
+		event.EventID = canonicalEventID(event.EventID)
 	}
+	return nil
+}
 
-	return event, http.StatusOK
+// canonicalEventID normalizes letter case so that retries differing only in
+// case deduplicate: UUIDs are lower-cased and ULIDs upper-cased.
+func canonicalEventID(id string) string {
+	if uuidPattern.MatchString(id) {
+		return strings.ToLower(id)
+	}
+	return strings.ToUpper(id)
 }
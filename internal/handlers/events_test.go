@@ -2,30 +2,52 @@ package handlers_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/gif"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/carlmjohnson/be"
 	"github.com/kakhavain/telemetry-tracker/internal/handlers"
 	"github.com/kakhavain/telemetry-tracker/internal/metrics"
 	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"github.com/kakhavain/telemetry-tracker/internal/spool"
 	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"github.com/klauspost/compress/zstd"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 type mockStorer struct {
-	StoreFunc func(ctx context.Context, event storage.Event) error
+	StoreFunc      func(ctx context.Context, event storage.Event) error
+	StoreBatchFunc func(ctx context.Context, events []storage.Event) error
 }
 
 func (m *mockStorer) StoreEvent(ctx context.Context, event storage.Event) error {
 	return m.StoreFunc(ctx, event)
 }
 
+func (m *mockStorer) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	return nil, m.StoreBatchFunc(ctx, events)
+}
+
 func (m *mockStorer) Close() {}
 
 func TestEventHandler_ServeHTTP(t *testing.T) {
@@ -33,6 +55,7 @@ func TestEventHandler_ServeHTTP(t *testing.T) {
 		name           string
 		method         string
 		contentType    string
+		idempotencyKey string
 		body           string
 		storeErr       error
 		expectedStatus int
@@ -62,6 +85,14 @@ func TestEventHandler_ServeHTTP(t *testing.T) {
 			body:           `{"event_type": login"}`,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Trailing data after event",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"event_type": "login"}
+{"event_type": "logout"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Missing event_type",
 			method:         http.MethodPost,
@@ -72,6 +103,45 @@ func TestEventHandler_ServeHTTP(t *testing.T) {
 			}`,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Event with event_id",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"event_id": "01ARZ3NDEKTSV4RRFFQ69G5FAV", "event_type": "login"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Invalid event_id",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			body:           `{"event_id": "not-an-id", "event_type": "login"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Idempotency-Key header",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			idempotencyKey: "6F9619FF-8B86-D011-B42D-00C04FC964FF",
+			body:           `{"event_id": "6f9619ff-8b86-d011-b42d-00c04fc964ff", "event_type": "login"}`,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Idempotency-Key mismatch",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			idempotencyKey: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+			body:           `{"event_id": "6f9619ff-8b86-d011-b42d-00c04fc964ff", "event_type": "login"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Duplicate event",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			idempotencyKey: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+			body:           `{"event_type": "login"}`,
+			storeErr:       storage.ErrDuplicateEvent,
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:           "Storage error",
 			method:         http.MethodPost,
@@ -101,6 +171,9 @@ func TestEventHandler_ServeHTTP(t *testing.T) {
 
 			req := httptest.NewRequest(tc.method, "/events", bytes.NewBufferString(tc.body))
 			req.Header.Set("Content-Type", tc.contentType)
+			if tc.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tc.idempotencyKey)
+			}
 
 			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 			ctx := appmiddleware.WithLogger(req.Context(), logger)
@@ -113,3 +186,1164 @@ func TestEventHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestEventHandler_DuplicateThroughSpool(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	sp, err := spool.Open(storage.NewMemoryStore(), spool.Config{Dir: t.TempDir(), ReplayInterval: time.Hour}, reg, obs)
+	be.NilErr(t, err)
+	defer sp.Close()
+	handler := handlers.NewEventHandler(sp, reg, obs)
+
+	for _, want := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusAccepted, "accepted"},
+		{http.StatusOK, "duplicate"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/events",
+			bytes.NewBufferString(`{"event_id": "01ARZ3NDEKTSV4RRFFQ69G5FAV", "event_type": "login"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		be.Equal(t, want.status, rec.Code)
+		var resp struct {
+			Status string `json:"status"`
+		}
+		be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		be.Equal(t, want.body, resp.Status)
+	}
+	be.Equal(t, int64(0), sp.Depth())
+}
+
+func TestEventHandler_ServeBatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		storeErr         error
+		expectedStatus   int
+		expectedStored   int
+		expectedRejected int
+	}{
+		{
+			name:           "Array of valid events",
+			contentType:    "application/json",
+			body:           `[{"event_type": "login"}, {"event_type": "click", "data": {"x": 1}}]`,
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 2,
+		},
+		{
+			name:           "Envelope of valid events",
+			contentType:    "application/json",
+			body:           `{"events": [{"event_type": "login", "timestamp": "2024-03-28T12:34:56Z"}]}`,
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 1,
+		},
+		{
+			name:             "Mixed valid and invalid events",
+			contentType:      "application/json",
+			body:             `[{"event_type": "login"}, {"data": {}}, {"event_type": "x", "bogus": 1}]`,
+			expectedStatus:   http.StatusAccepted,
+			expectedStored:   1,
+			expectedRejected: 2,
+		},
+		{
+			name:             "All events invalid",
+			contentType:      "application/json",
+			body:             `[{"data": {}}]`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedRejected: 1,
+		},
+		{
+			name:           "Empty batch",
+			contentType:    "application/json",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown envelope field",
+			contentType:    "application/json",
+			body:           `{"items": []}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong content type",
+			contentType:    "text/plain",
+			body:           `[{"event_type": "login"}]`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Storage error",
+			contentType:    "application/json",
+			body:           `[{"event_type": "login"}]`,
+			storeErr:       errors.New("db failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	obs, _ := observability.InitObservability("noop")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored int
+			mockStore := &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					if tc.storeErr == nil {
+						stored += len(events)
+					}
+					return tc.storeErr
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			req := httptest.NewRequest(http.MethodPost, "/events/batch", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeBatch(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, stored)
+			if tc.expectedStatus == http.StatusAccepted || tc.expectedRejected > 0 {
+				var resp struct {
+					Accepted int `json:"accepted"`
+					Rejected int `json:"rejected"`
+				}
+				be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				be.Equal(t, tc.expectedStored, resp.Accepted)
+				be.Equal(t, tc.expectedRejected, resp.Rejected)
+			}
+		})
+	}
+}
+
+func TestEventHandler_BatchDuplicates(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	store := storage.NewMemoryStore()
+	be.NilErr(t, store.StoreEvent(context.Background(), storage.Event{EventID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", EventType: "login"}))
+	tiers, err := ratelimit.ParseTiers("", "default=3", "")
+	be.NilErr(t, err)
+	handler := handlers.NewEventHandler(store, reg, obs)
+	handler.Quota = ratelimit.NewQuota(store, tiers, time.Minute, obs)
+
+	req := httptest.NewRequest(http.MethodPost, "/events/batch", bytes.NewBufferString(`[
+		{"event_id": "01ARZ3NDEKTSV4RRFFQ69G5FAV", "event_type": "login"},
+		{"event_id": "01BX5ZZKBKACTAV9WEVGEMMVRZ", "event_type": "click"},
+		{"event_id": "01BX5ZZKBKACTAV9WEVGEMMVRZ", "event_type": "click"},
+		{"event_type": "view"}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+	rec := httptest.NewRecorder()
+	handler.ServeBatch(rec, req)
+
+	be.Equal(t, http.StatusAccepted, rec.Code)
+	var resp struct {
+		Accepted   int `json:"accepted"`
+		Duplicates int `json:"duplicates"`
+		Results    []struct {
+			Status string `json:"status"`
+		} `json:"results"`
+	}
+	be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	be.Equal(t, 2, resp.Accepted)
+	be.Equal(t, 2, resp.Duplicates)
+	statuses := make([]string, len(resp.Results))
+	for i, r := range resp.Results {
+		statuses[i] = r.Status
+	}
+	be.AllEqual(t, []string{"duplicate", "accepted", "duplicate", "accepted"}, statuses)
+	// Only the two stored events count towards the quota of three.
+	allowed, _ := handler.Quota.Check("")
+	be.True(t, allowed)
+}
+
+func TestEventHandler_ServeStream(t *testing.T) {
+	tests := []struct {
+		name             string
+		contentType      string
+		body             string
+		storeErr         error
+		expectedStatus   int
+		expectedStored   int
+		expectedRejected int
+	}{
+		{
+			name:           "Valid stream",
+			contentType:    "application/x-ndjson",
+			body:           "{\"event_type\": \"login\"}\n\n{\"event_type\": \"click\", \"data\": {\"x\": 1}}\n",
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 2,
+		},
+		{
+			name:             "Stream with bad lines",
+			contentType:      "application/x-ndjson; charset=utf-8",
+			body:             "{\"event_type\": \"login\"}\n{\"event_type\": login}\n{\"data\": {}}",
+			expectedStatus:   http.StatusAccepted,
+			expectedStored:   1,
+			expectedRejected: 2,
+		},
+		{
+			name:           "Wrong content type",
+			contentType:    "application/json",
+			body:           `{"event_type": "login"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Storage error",
+			contentType:    "application/x-ndjson",
+			body:           `{"event_type": "login"}`,
+			storeErr:       errors.New("db failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	obs, _ := observability.InitObservability("noop")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored int
+			mockStore := &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					if tc.storeErr == nil {
+						stored += len(events)
+					}
+					return tc.storeErr
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			req := httptest.NewRequest(http.MethodPost, "/events/stream", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeStream(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, stored)
+			if tc.expectedStatus == http.StatusAccepted {
+				var resp struct {
+					Accepted int `json:"accepted"`
+					Rejected int `json:"rejected"`
+				}
+				be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				be.Equal(t, tc.expectedStored, resp.Accepted)
+				be.Equal(t, tc.expectedRejected, resp.Rejected)
+			}
+		})
+	}
+}
+
+func TestEventHandler_SchemaValidation(t *testing.T) {
+	registry := schema.NewRegistry(schema.PolicyQuarantine)
+	be.NilErr(t, registry.Put("login", 1, []byte(`{
+		"type": "object",
+		"required": ["user"],
+		"properties": {"user": {"type": "string"}}
+	}`)))
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid data",
+			body:           `{"event_type": "login", "data": {"user": "test"}}`,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   `"status":"accepted"`,
+		},
+		{
+			name:           "Invalid data",
+			body:           `{"event_type": "login", "data": {"user": 42}}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"pointer":"/data/user"`,
+		},
+		{
+			name:           "Unknown version",
+			body:           `{"event_type": "login", "schema_version": 2, "data": {"user": "test"}}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   schema.ErrUnknownVersion.Error(),
+		},
+		{
+			name:           "Unknown event type is quarantined",
+			body:           `{"event_type": "signup", "data": {}}`,
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   `"status":"quarantined"`,
+		},
+	}
+
+	obs, _ := observability.InitObservability("noop")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored storage.Event
+			mockStore := &mockStorer{
+				StoreFunc: func(ctx context.Context, event storage.Event) error {
+					stored = event
+					return nil
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+			handler.Schemas = registry
+
+			req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.In(t, tc.expectedBody, rec.Body.String())
+			if tc.expectedStatus == http.StatusAccepted {
+				be.Equal(t, tc.name == "Unknown event type is quarantined", stored.Quarantined)
+			}
+		})
+	}
+}
+
+func TestEventHandler_ServeOTLPLogs(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	export := &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			{Key: "service.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "checkout"}}},
+		}},
+		ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{
+			{EventName: "purchase", TimeUnixNano: 1711620000000000000, Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 42}}},
+			{Attributes: []*commonpb.KeyValue{
+				{Key: "event.name", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "refund"}}},
+			}},
+			{SeverityText: "INFO"},
+		}}},
+	}}}
+	protoBody, err := proto.Marshal(export)
+	be.NilErr(t, err)
+
+	tests := []struct {
+		name             string
+		contentType      string
+		body             []byte
+		storeErr         error
+		expectedStatus   int
+		expectedStored   int
+		expectedRejected int64
+	}{
+		{
+			name:             "Protobuf export",
+			contentType:      "application/x-protobuf",
+			body:             protoBody,
+			expectedStatus:   http.StatusOK,
+			expectedStored:   2,
+			expectedRejected: 1,
+		},
+		{
+			name:        "JSON export",
+			contentType: "application/json",
+			body: []byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{
+				"eventName":"login","timeUnixNano":"1711620000000000000",
+				"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"}]}]}]}`),
+			expectedStatus: http.StatusOK,
+			expectedStored: 1,
+		},
+		{
+			name:           "Wrong content type",
+			contentType:    "text/plain",
+			body:           protoBody,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Malformed body",
+			contentType:    "application/json",
+			body:           []byte(`{"resourceLogs":`),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Storage error",
+			contentType:    "application/x-protobuf",
+			body:           protoBody,
+			storeErr:       errors.New("db failure"),
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					if tc.storeErr == nil {
+						stored = append(stored, events...)
+					}
+					return tc.storeErr
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+			handler.LogEventTypeAttribute = "event.name"
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeOTLPLogs(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, len(stored))
+			if rec.Code != http.StatusOK {
+				return
+			}
+			be.Equal(t, tc.contentType, rec.Header().Get("Content-Type"))
+			resp := &collogspb.ExportLogsServiceResponse{}
+			if tc.contentType == "application/json" {
+				be.NilErr(t, protojson.Unmarshal(rec.Body.Bytes(), resp))
+			} else {
+				be.NilErr(t, proto.Unmarshal(rec.Body.Bytes(), resp))
+			}
+			be.Equal(t, tc.expectedRejected, resp.GetPartialSuccess().GetRejectedLogRecords())
+			be.True(t, stored[0].Timestamp.Equal(time.Unix(0, 1711620000000000000)))
+		})
+	}
+
+	t.Run("Record mapping", func(t *testing.T) {
+		var stored []storage.Event
+		handler := handlers.NewEventHandler(&mockStorer{
+			StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+				stored = append(stored, events...)
+				return nil
+			},
+		}, nil, obs)
+		handler.Metrics, _ = metrics.NewRegistry(obs.Meter())
+		handler.LogEventTypeAttribute = "event.name"
+		req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(protoBody))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		handler.ServeOTLPLogs(httptest.NewRecorder(), req)
+
+		be.Equal(t, 2, len(stored))
+		be.Equal(t, "purchase", stored[0].EventType)
+		be.Equal(t, `{"body":42,"resource":{"service.name":"checkout"}}`, string(stored[0].Data))
+		be.Equal(t, "refund", stored[1].EventType)
+		be.True(t, stored[1].Timestamp.IsZero())
+	})
+
+	t.Run("Hex IDs in JSON", func(t *testing.T) {
+		var stored []storage.Event
+		handler := handlers.NewEventHandler(&mockStorer{
+			StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+				stored = append(stored, events...)
+				return nil
+			},
+		}, nil, obs)
+		handler.Metrics, _ = metrics.NewRegistry(obs.Meter())
+		req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewBufferString(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{
+			"eventName":"login","traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"}]}]}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeOTLPLogs(httptest.NewRecorder(), req)
+
+		be.Equal(t, 1, len(stored))
+		be.Equal(t, `{"trace_id":"5b8efff798038103d269b633813fc60c","span_id":"eee19b7ec3c1b174"}`, string(stored[0].Data))
+	})
+}
+
+func TestEventHandler_CloudEvents(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	const structured = `{"specversion":"1.0","id":"A234-1234-1234","source":"/billing","type":"order.created",
+		"subject":"orders/42","time":"2024-03-28T10:00:00Z","datacontenttype":"application/json",
+		"data":{"amount":42},"traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}`
+
+	tests := []struct {
+		name           string
+		path           string
+		contentType    string
+		headers        map[string]string
+		body           string
+		maxBytes       int64
+		expectedStatus int
+		expected       []storage.Event
+	}{
+		{
+			name:           "Structured mode",
+			contentType:    "application/cloudevents+json; charset=utf-8",
+			body:           structured,
+			expectedStatus: http.StatusAccepted,
+			expected: []storage.Event{{
+				EventType: "order.created", Timestamp: time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC),
+				Data: json.RawMessage(`{"amount":42}`), Source: "/billing", SourceEventID: "A234-1234-1234", Subject: "orders/42",
+			}},
+		},
+		{
+			name:           "Binary mode",
+			contentType:    "application/json",
+			headers:        map[string]string{"Ce-Specversion": "1.0", "Ce-Id": "0190B2F4-5C1E-7A2B-9D3E-1F2A3B4C5D6E", "Ce-Source": "/auth", "Ce-Type": "login"},
+			body:           `{"user":"u1"}`,
+			expectedStatus: http.StatusAccepted,
+			expected: []storage.Event{{
+				EventID: "0190b2f4-5c1e-7a2b-9d3e-1f2a3b4c5d6e", EventType: "login",
+				Data: json.RawMessage(`{"user":"u1"}`), Source: "/auth", SourceEventID: "0190B2F4-5C1E-7A2B-9D3E-1F2A3B4C5D6E",
+			}},
+		},
+		{
+			name:           "Binary mode without data",
+			headers:        map[string]string{"Ce-Specversion": "1.0", "Ce-Id": "1", "Ce-Source": "/auth", "Ce-Type": "logout"},
+			expectedStatus: http.StatusAccepted,
+			expected:       []storage.Event{{EventType: "logout", Source: "/auth", SourceEventID: "1"}},
+		},
+		{
+			name:           "Binary mode with non-JSON data",
+			contentType:    "text/plain",
+			headers:        map[string]string{"Ce-Specversion": "1.0", "Ce-Id": "1", "Ce-Source": "/auth", "Ce-Type": "login"},
+			body:           "hello",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Missing source",
+			contentType:    "application/cloudevents+json",
+			body:           `{"specversion":"1.0","id":"1","type":"login"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported spec version",
+			contentType:    "application/cloudevents+json",
+			body:           `{"specversion":"0.3","id":"1","source":"/auth","type":"login"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Base64 data",
+			contentType:    "application/cloudevents+json",
+			body:           `{"specversion":"1.0","id":"1","source":"/auth","type":"login","data_base64":"aGk="}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Body too large",
+			contentType:    "application/cloudevents+json",
+			body:           structured,
+			maxBytes:       64,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "Body over the event size limit",
+			contentType: "application/cloudevents+json",
+			body: `{"specversion":"1.0","id":"1","source":"/auth","type":"login","data":{"pad":"` +
+				strings.Repeat("x", 1<<20) + `"}}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Batch posted to /events",
+			contentType:    "application/cloudevents-batch+json",
+			body:           `[` + structured + `,{"specversion":"1.0","id":"2","source":"/billing"}]`,
+			expectedStatus: http.StatusAccepted,
+			expected: []storage.Event{{
+				EventType: "order.created", Timestamp: time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC),
+				Data: json.RawMessage(`{"amount":42}`), Source: "/billing", SourceEventID: "A234-1234-1234", Subject: "orders/42",
+			}},
+		},
+		{
+			name:           "Batch",
+			path:           "/events/batch",
+			contentType:    "application/cloudevents-batch+json",
+			body:           `[{"specversion":"1.0","id":"1","source":"/auth","type":"login"}]`,
+			expectedStatus: http.StatusAccepted,
+			expected:       []storage.Event{{EventType: "login", Source: "/auth", SourceEventID: "1"}},
+		},
+		{
+			name:           "Batch envelope",
+			path:           "/events/batch",
+			contentType:    "application/cloudevents-batch+json",
+			body:           `{"events":[{"specversion":"1.0","id":"1","source":"/auth","type":"login"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreFunc: func(ctx context.Context, event storage.Event) error {
+					stored = append(stored, event)
+					return nil
+				},
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					stored = append(stored, events...)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			if tc.path == "" {
+				tc.path = "/events"
+			}
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			if tc.maxBytes > 0 {
+				req.Body = http.MaxBytesReader(rec, req.Body, tc.maxBytes)
+			}
+			if tc.path == "/events/batch" {
+				handler.ServeBatch(rec, req)
+			} else {
+				handler.ServeHTTP(rec, req)
+			}
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, len(tc.expected), len(stored))
+			for i, want := range tc.expected {
+				got := stored[i]
+				be.Equal(t, want.EventID, got.EventID)
+				be.Equal(t, want.EventType, got.EventType)
+				be.True(t, want.Timestamp.Equal(got.Timestamp))
+				be.Equal(t, string(want.Data), string(got.Data))
+				be.Equal(t, want.Source, got.Source)
+				be.Equal(t, want.SourceEventID, got.SourceEventID)
+				be.Equal(t, want.Subject, got.Subject)
+			}
+		})
+	}
+}
+
+func TestEventHandler_Segment(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+
+	tests := []struct {
+		name           string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expected       []storage.Event
+	}{
+		{
+			name: "Track",
+			path: "/v1/track",
+			body: `{"userId":"u-42","event":"Order Completed","properties":{"total":42},"context":{"ip":"203.0.113.7"},
+				"messageId":"0190B2F4-5C1E-7A2B-9D3E-1F2A3B4C5D6E","timestamp":"2024-03-28T10:00:00Z","integrations":{"All":true}}`,
+			expectedStatus: http.StatusOK,
+			expected: []storage.Event{{
+				EventID: "0190b2f4-5c1e-7a2b-9d3e-1f2a3b4c5d6e", EventType: "Order Completed",
+				Timestamp: time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC), UserID: "u-42",
+				Data: json.RawMessage(`{"properties":{"total":42},"context":{"ip":"203.0.113.7"}}`),
+			}},
+		},
+		{
+			name:           "Identify",
+			path:           "/v1/identify",
+			body:           `{"userId":"u-42","anonymousId":"a-7","traits":{"plan":"pro"},"messageId":"ajs-next-1"}`,
+			expectedStatus: http.StatusOK,
+			expected: []storage.Event{{
+				EventType: "identify", UserID: "u-42", AnonymousID: "a-7", Data: json.RawMessage(`{"traits":{"plan":"pro"}}`),
+			}},
+		},
+		{
+			name:           "Page",
+			path:           "/v1/page",
+			body:           `{"anonymousId":"a-7","name":"Pricing","category":"Docs","properties":{"path":"/pricing"}}`,
+			expectedStatus: http.StatusOK,
+			expected: []storage.Event{{
+				EventType: "page", AnonymousID: "a-7", Data: json.RawMessage(`{"properties":{"path":"/pricing"},"name":"Pricing","category":"Docs"}`),
+			}},
+		},
+		{
+			name:           "Path type overrides body type",
+			path:           "/v1/identify",
+			body:           `{"type":"track","userId":"u-42","event":"Signed Up"}`,
+			expectedStatus: http.StatusOK,
+			expected:       []storage.Event{{EventType: "identify", UserID: "u-42", Data: json.RawMessage(`{}`)}},
+		},
+		{
+			name:           "Track without event",
+			path:           "/v1/track",
+			body:           `{"userId":"u-42","properties":{}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing identity",
+			path:           "/v1/track",
+			body:           `{"event":"Order Completed"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid content type",
+			path:           "/v1/track",
+			contentType:    "text/plain",
+			body:           `{"userId":"u-42","event":"Order Completed"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "Batch",
+			path: "/v1/batch",
+			body: `{"batch":[
+				{"type":"track","userId":"u-42","event":"Order Completed"},
+				{"type":"screen","anonymousId":"a-7","name":"Home"},
+				{"type":"unknown","userId":"u-42"},
+				{"type":"alias","userId":"u-42","previousId":"a-7"}
+			]}`,
+			expectedStatus: http.StatusOK,
+			expected: []storage.Event{
+				{EventType: "Order Completed", UserID: "u-42", Data: json.RawMessage(`{}`)},
+				{EventType: "screen", AnonymousID: "a-7", Data: json.RawMessage(`{"name":"Home"}`)},
+				{EventType: "alias", UserID: "u-42", Data: json.RawMessage(`{"previous_id":"a-7"}`)},
+			},
+		},
+		{
+			name:           "Batch without valid messages",
+			path:           "/v1/batch",
+			body:           `{"batch":[{"type":"track","userId":"u-42"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Empty batch",
+			path:           "/v1/batch",
+			body:           `{"batch":[]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreFunc: func(ctx context.Context, event storage.Event) error {
+					stored = append(stored, event)
+					return nil
+				},
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					stored = append(stored, events...)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			if tc.contentType == "" {
+				tc.contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", tc.contentType)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			if msgType, _ := strings.CutPrefix(tc.path, "/v1/"); msgType == "batch" {
+				handler.ServeSegmentBatch(rec, req)
+			} else {
+				handler.ServeSegment(msgType)(rec, req)
+			}
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, len(tc.expected), len(stored))
+			for i, want := range tc.expected {
+				got := stored[i]
+				be.Equal(t, want.EventID, got.EventID)
+				be.Equal(t, want.EventType, got.EventType)
+				be.True(t, want.Timestamp.Equal(got.Timestamp))
+				be.Equal(t, string(want.Data), string(got.Data))
+				be.Equal(t, want.UserID, got.UserID)
+				be.Equal(t, want.AnonymousID, got.AnonymousID)
+			}
+		})
+	}
+
+	// The client's clock is a year behind; each message was sent an hour
+	// after it happened.
+	skewTests := []struct {
+		name     string
+		message  string
+		expected func(stored time.Time) bool
+	}{
+		{
+			name:    "Clock skew correction",
+			message: `{"type":"track","userId":"u-42","event":"Order Completed","originalTimestamp":"2023-03-28T10:00:00Z"}`,
+			expected: func(stored time.Time) bool {
+				skew := time.Since(stored) - time.Hour
+				return skew >= 0 && skew < time.Minute
+			},
+		},
+		{
+			name:    "Explicit timestamp is not corrected",
+			message: `{"type":"track","userId":"u-42","event":"Order Completed","timestamp":"2023-03-28T10:00:00Z"}`,
+			expected: func(stored time.Time) bool {
+				return stored.Equal(time.Date(2023, 3, 28, 10, 0, 0, 0, time.UTC))
+			},
+		},
+	}
+	for _, tc := range skewTests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(&mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					stored = append(stored, events...)
+					return nil
+				},
+			}, reg, obs)
+
+			body := `{"sentAt":"2023-03-28T11:00:00Z","batch":[` + tc.message + `]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/batch", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.ServeSegmentBatch(rec, req)
+
+			be.Equal(t, http.StatusOK, rec.Code)
+			be.Equal(t, 1, len(stored))
+			be.True(t, tc.expected(stored[0].Timestamp))
+		})
+	}
+}
+
+func TestEventHandler_ServeBeacon(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedStored int
+	}{
+		{
+			name:           "sendBeacon string",
+			contentType:    "text/plain;charset=UTF-8",
+			body:           `{"event_type":"page_hidden","data":{"visible_ms":5300}}`,
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 1,
+		},
+		{
+			name:           "JSON blob",
+			contentType:    "application/json",
+			body:           `{"event_type":"page_hidden"}`,
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 1,
+		},
+		{
+			name:           "Invalid text/plain body",
+			contentType:    "text/plain",
+			body:           `page_hidden`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Missing event type",
+			contentType:    "text/plain",
+			body:           `{"data":{}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Form body",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           `event_type=page_hidden`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreFunc: func(ctx context.Context, event storage.Event) error {
+					stored = append(stored, event)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			req := httptest.NewRequest(http.MethodPost, "/events/beacon", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeBeacon(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, len(stored))
+		})
+	}
+}
+
+func TestEventHandler_ServePixel(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       *storage.Event
+	}{
+		{
+			name:           "Parameters as data",
+			query:          "event_type=email.open&campaign=spring&recipient=42&api_key=secret",
+			expectedStatus: http.StatusOK,
+			expected:       &storage.Event{EventType: "email.open", Data: json.RawMessage(`{"campaign":"spring","recipient":"42"}`)},
+		},
+		{
+			name: "JSON data",
+			query: "event_type=email.open&event_id=01ARZ3NDEKTSV4RRFFQ69G5FAV&timestamp=2024-03-28T10:00:00Z&schema_version=2" +
+				"&data=%7B%22campaign%22%3A%22spring%22%7D&cb=123",
+			expectedStatus: http.StatusOK,
+			expected: &storage.Event{
+				EventID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", EventType: "email.open", Timestamp: time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC),
+				SchemaVersion: 2, Data: json.RawMessage(`{"campaign":"spring"}`),
+			},
+		},
+		{
+			name:           "Missing event type",
+			query:          "campaign=spring",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid data",
+			query:          "event_type=email.open&data=spring",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid timestamp",
+			query:          "event_type=email.open&timestamp=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreFunc: func(ctx context.Context, event storage.Event) error {
+					stored = append(stored, event)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+
+			req := httptest.NewRequest(http.MethodGet, "/p.gif?"+tc.query, nil)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServePixel(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expected == nil {
+				be.Equal(t, 0, len(stored))
+				return
+			}
+			be.Equal(t, "image/gif", rec.Header().Get("Content-Type"))
+			img, err := gif.Decode(rec.Body)
+			be.NilErr(t, err)
+			be.Equal(t, image.Rect(0, 0, 1, 1), img.Bounds())
+
+			be.Equal(t, 1, len(stored))
+			be.Equal(t, tc.expected.EventID, stored[0].EventID)
+			be.Equal(t, tc.expected.EventType, stored[0].EventType)
+			be.True(t, tc.expected.Timestamp.Equal(stored[0].Timestamp))
+			be.Equal(t, tc.expected.SchemaVersion, stored[0].SchemaVersion)
+			be.Equal(t, string(tc.expected.Data), string(stored[0].Data))
+		})
+	}
+}
+
+func TestEventHandler_CompressedStream(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	gzipped := func(body []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		return buf.Bytes()
+	}
+	var stream []byte
+	for i := range 3000 {
+		stream = fmt.Appendf(stream, "{\"event_type\": \"click\", \"data\": {\"x\": %d}}\n", i)
+	}
+	// An event followed by blank lines, which compress far beyond MaxRatio.
+	bomb := append([]byte("{\"event_type\": \"login\"}\n"), bytes.Repeat([]byte("\n"), 1<<20)...)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		expectedStatus int
+		expectedStored int
+	}{
+		{
+			name:           "Stream",
+			body:           gzipped(stream),
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 3000,
+		},
+		{
+			name:           "Expansion ratio exceeded",
+			body:           gzipped(bomb),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedStored: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored int
+			mockStore := &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					stored += len(events)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+			// Streams are held to the ratio only, as the server configures them.
+			serve := appmiddleware.Decompress(appmiddleware.DecompressLimits{MaxRatio: 100}, reg)(http.HandlerFunc(handler.ServeStream))
+
+			req := httptest.NewRequest(http.MethodPost, "/events/stream", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+			req.Header.Set("Content-Encoding", "gzip")
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			serve.ServeHTTP(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, stored)
+		})
+	}
+}
+
+func TestEventHandler_CompressedBodies(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	batch := []byte(`[{"event_type":"login"},{"event_type":"purchase","data":{"amount":42}}]`)
+
+	compress := func(encoding string, body []byte) []byte {
+		var buf bytes.Buffer
+		switch encoding {
+		case "gzip":
+			zw := gzip.NewWriter(&buf)
+			_, _ = zw.Write(body)
+			_ = zw.Close()
+		case "zstd":
+			zw, _ := zstd.NewWriter(&buf)
+			_, _ = zw.Write(body)
+			_ = zw.Close()
+		default:
+			buf.Write(body)
+		}
+		return buf.Bytes()
+	}
+	// A batch padded with whitespace, which compresses far beyond MaxRatio.
+	bomb := append(append([]byte("["), bytes.Repeat([]byte(" "), 512<<10)...), batch[1:]...)
+
+	tests := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		limits         appmiddleware.DecompressLimits
+		expectedStatus int
+		expectedStored int
+	}{
+		{
+			name:           "Uncompressed",
+			body:           batch,
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 2,
+		},
+		{
+			name:           "Gzip",
+			encoding:       "gzip",
+			body:           compress("gzip", batch),
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 2,
+		},
+		{
+			name:           "Zstd",
+			encoding:       "zstd",
+			body:           compress("zstd", batch),
+			expectedStatus: http.StatusAccepted,
+			expectedStored: 2,
+		},
+		{
+			name:           "Unsupported encoding",
+			encoding:       "br",
+			body:           batch,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Corrupt gzip",
+			encoding:       "gzip",
+			body:           batch,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Expansion ratio exceeded",
+			encoding:       "gzip",
+			body:           compress("gzip", bomb),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Zstd expansion ratio exceeded",
+			encoding:       "zstd",
+			body:           compress("zstd", bomb),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Decompressed size exceeded",
+			encoding:       "zstd",
+			body:           compress("zstd", batch),
+			limits:         appmiddleware.DecompressLimits{MaxCompressedBytes: 1 << 20, MaxDecompressedBytes: 32, MaxRatio: 100},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Compressed size exceeded",
+			encoding:       "gzip",
+			body:           compress("gzip", batch),
+			limits:         appmiddleware.DecompressLimits{MaxCompressedBytes: 16, MaxDecompressedBytes: 1 << 20, MaxRatio: 100},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			mockStore := &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					stored = append(stored, events...)
+					return nil
+				},
+			}
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewEventHandler(mockStore, reg, obs)
+			if tc.limits == (appmiddleware.DecompressLimits{}) {
+				tc.limits = appmiddleware.DecompressLimits{MaxCompressedBytes: 1 << 20, MaxDecompressedBytes: 4 << 20, MaxRatio: 100}
+			}
+			serve := appmiddleware.Decompress(tc.limits, reg)(http.HandlerFunc(handler.ServeBatch))
+
+			req := httptest.NewRequest(http.MethodPost, "/events/batch", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			serve.ServeHTTP(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+			be.Equal(t, tc.expectedStored, len(stored))
+		})
+	}
+}
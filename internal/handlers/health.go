@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
+	"github.com/kakhavain/telemetry-tracker/internal/health"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// HealthHandler provides a simple health check endpoint.
+// HealthHandler provides the health check and probe endpoints.
 type HealthHandler struct {
-	Obs observability.Provider
+	Obs    observability.Provider
+	Checks *health.Registry // Optional; probes report no checks when nil
 }
 
 // NewHealthHandler constructs a HealthHandler with observability.
@@ -30,3 +34,57 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("OK"))
 }
+
+// ServeLive handles GET requests to /livez.
+func (h *HealthHandler) ServeLive(w http.ResponseWriter, r *http.Request) {
+	h.serveProbe(w, r, health.Liveness, "LivenessProbe")
+}
+
+// ServeReady handles GET requests to /readyz.
+func (h *HealthHandler) ServeReady(w http.ResponseWriter, r *http.Request) {
+	h.serveProbe(w, r, health.Readiness, "ReadinessProbe")
+}
+
+// serveProbe runs the checks for probe and responds 200 unless a required
+// check failed. Check errors may describe internal dependencies, so they
+// are only included with ?verbose.
+func (h *HealthHandler) serveProbe(w http.ResponseWriter, r *http.Request, probe health.Probe, spanName string) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), spanName)
+	defer span.End()
+
+	report := health.Report{Status: health.StatusOK, Checks: []health.Result{}}
+	if h.Checks != nil {
+		report = h.Checks.Run(ctx, probe)
+	}
+
+	if !verbose(r) {
+		for i := range report.Checks {
+			report.Checks[i].Error = ""
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status == health.StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	span.SetAttributes(
+		attribute.String("health.status", report.Status),
+		attribute.Int("http.status_code", status),
+	)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
+
+// verbose reports whether the request asked for check details, either as
+// a bare ?verbose or with a boolean value.
+func verbose(r *http.Request) bool {
+	values, ok := r.URL.Query()["verbose"]
+	if !ok {
+		return false
+	}
+	if len(values) == 0 || values[0] == "" {
+		return true
+	}
+	v, err := strconv.ParseBool(values[0])
+	return err == nil && v
+}
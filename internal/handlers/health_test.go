@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/kakhavain/telemetry-tracker/internal/health"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
 )
 
@@ -46,3 +51,55 @@ func TestHealthHandler_InvalidMethod(t *testing.T) {
 		t.Errorf("expected status %v, got %v", http.StatusMethodNotAllowed, status)
 	}
 }
+
+func TestHealthHandler_Probes(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	handler := NewHealthHandler(obs)
+	handler.Checks = health.NewRegistry(time.Second)
+	dbErr := errors.New("connection refused")
+	handler.Checks.Register(health.Readiness, health.Checker{
+		Name:  "database",
+		Check: func(context.Context) error { return dbErr },
+	})
+	handler.Checks.Register(health.Readiness, health.Checker{
+		Name:     "otel_exporter",
+		Check:    func(context.Context) error { return nil },
+		Optional: true,
+	})
+
+	tests := []struct {
+		name       string
+		serve      http.HandlerFunc
+		target     string
+		wantStatus int
+		wantReport string
+		wantError  string
+	}{
+		{"live", handler.ServeLive, "/livez", http.StatusOK, health.StatusOK, ""},
+		{"ready", handler.ServeReady, "/readyz", http.StatusServiceUnavailable, health.StatusFail, ""},
+		{"ready verbose", handler.ServeReady, "/readyz?verbose", http.StatusServiceUnavailable, health.StatusFail, "connection refused"},
+		{"ready verbose false", handler.ServeReady, "/readyz?verbose=false", http.StatusServiceUnavailable, health.StatusFail, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.serve(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %v, got %v", tt.wantStatus, rr.Code)
+			}
+			var report health.Report
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			if report.Status != tt.wantReport {
+				t.Errorf("expected report status %q, got %q", tt.wantReport, report.Status)
+			}
+			for _, res := range report.Checks {
+				if res.Name == "database" && res.Error != tt.wantError {
+					t.Errorf("expected database error %q, got %q", tt.wantError, res.Error)
+				}
+			}
+		})
+	}
+}
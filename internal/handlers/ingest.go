@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	telemetryv1 "github.com/kakhavain/telemetry-tracker/api/telemetry/v1"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IngestServer implements the gRPC IngestService on top of an EventHandler,
+// so that events sent over gRPC are validated, stored and counted exactly
+// like those posted to /events/batch and /events/stream.
+type IngestServer struct {
+	telemetryv1.UnimplementedIngestServiceServer
+
+	Events *EventHandler
+}
+
+func NewIngestServer(events *EventHandler) *IngestServer {
+	return &IngestServer{Events: events}
+}
+
+// Ingest handles unary IngestService.Ingest calls.
+func (s *IngestServer) Ingest(ctx context.Context, req *telemetryv1.IngestRequest) (*telemetryv1.IngestResponse, error) {
+	h := s.Events
+	ctx, span := h.Obs.Tracer().Start(ctx, "Ingest")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for ingest service")
+	}
+
+	if err := checkIngestRequest(req); err != nil {
+		logger.Warn("Invalid ingest request", slog.Any("error", err))
+		return nil, err
+	}
+
+	resp := &telemetryv1.IngestResponse{}
+	if err := h.ingestEvents(ctx, logger, resp, 0, req.GetEvents()); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch.size", len(req.GetEvents())),
+		attribute.Int("batch.accepted", int(resp.Accepted)),
+		attribute.Int("batch.rejected", int(resp.Rejected)),
+	)
+	logger.Info("Event batch processed",
+		slog.Int("batch_size", len(req.GetEvents())),
+		slog.Int("accepted", int(resp.Accepted)),
+		slog.Int("rejected", int(resp.Rejected)),
+	)
+	return resp, nil
+}
+
+// IngestStream handles client-streaming IngestService.IngestStream calls.
+// The events of each request are stored before the next is read, so events
+// from earlier requests remain stored if the stream later fails.
+func (s *IngestServer) IngestStream(stream grpc.ClientStreamingServer[telemetryv1.IngestRequest, telemetryv1.IngestResponse]) error {
+	h := s.Events
+	ctx, span := h.Obs.Tracer().Start(stream.Context(), "IngestStream")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for ingest service")
+	}
+
+	resp := &telemetryv1.IngestResponse{}
+	var messages, events int
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Warn("Failed to read event stream", slog.Int("accepted", int(resp.Accepted)), slog.Any("error", err))
+			return err
+		}
+		if err := checkIngestRequest(req); err != nil {
+			logger.Warn("Invalid ingest request", slog.Int("message", messages), slog.Any("error", err))
+			return err
+		}
+		if err := h.ingestEvents(ctx, logger, resp, events, req.GetEvents()); err != nil {
+			return err
+		}
+		messages++
+		events += len(req.GetEvents())
+	}
+
+	span.SetAttributes(
+		attribute.Int("stream.messages", messages),
+		attribute.Int("stream.accepted", int(resp.Accepted)),
+		attribute.Int("stream.rejected", int(resp.Rejected)),
+	)
+	logger.Info("Event stream processed",
+		slog.Int("messages", messages),
+		slog.Int("accepted", int(resp.Accepted)),
+		slog.Int("rejected", int(resp.Rejected)),
+	)
+	return stream.SendAndClose(resp)
+}
+
+// checkIngestRequest applies the batch endpoint's size limits to a request.
+func checkIngestRequest(req *telemetryv1.IngestRequest) error {
+	switch n := len(req.GetEvents()); {
+	case n == 0:
+		return status.Error(codes.InvalidArgument, "empty event batch")
+	case n > maxBatchEvents:
+		return status.Errorf(codes.InvalidArgument, "too many events in batch: %d, limit %d", n, maxBatchEvents)
+	}
+	return nil
+}
+
+// ingestEvents validates events and stores the valid ones, adding the
+// outcome to resp. offset is the index of the first event in the call. A
+// failure to store is logged and returned as a gRPC status error.
+func (h *EventHandler) ingestEvents(ctx context.Context, logger *slog.Logger, resp *telemetryv1.IngestResponse, offset int, items []*telemetryv1.Event) error {
+	tenantID := appmiddleware.GetTenantFromContext(ctx)
+	events := make([]storage.Event, 0, len(items))
+	var quarantined int32
+	for i, item := range items {
+		event, err := protoEvent(item)
+		if err == nil {
+			err = h.checkSchema(ctx, &event)
+		}
+		if err != nil {
+			resp.Rejected++
+			if len(resp.Errors) >= maxStreamErrors {
+				resp.ErrorsTruncated = true
+				continue
+			}
+			eventErr := &telemetryv1.EventError{Index: int32(offset + i), Reason: err.Error()}
+			var ve *schema.ValidationError
+			if errors.As(err, &ve) {
+				for _, v := range ve.Violations {
+					eventErr.Violations = append(eventErr.Violations, &telemetryv1.Violation{Pointer: v.Pointer, Message: v.Message})
+				}
+			}
+			resp.Errors = append(resp.Errors, eventErr)
+			continue
+		}
+		if event.Quarantined {
+			quarantined++
+		}
+		event.TenantID = tenantID
+		events = append(events, event)
+
+		h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("event_type", event.EventType)),
+		)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	duplicates, err := h.Store.StoreEvents(ctx, events)
+	if err != nil {
+		logger.Error("Failed to store event batch", slog.Int("accepted", int(resp.Accepted)), slog.Any("error", err))
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "Failed to store event batch")
+		if h.shedLoad(ctx, len(events), err) {
+			return status.Error(codes.Unavailable, "event queue is full")
+		}
+		return status.Error(codes.Internal, "failed to store events")
+	}
+	h.Metrics.EventsStoredTotal.Add(ctx, int64(len(events)-len(duplicates)))
+	h.countUsage(ctx, len(events)-len(duplicates))
+	resp.Accepted += int32(len(events))
+	resp.Quarantined += quarantined
+	return nil
+}
+
+// protoEvent converts an event received over gRPC and validates it with the
+// same rules as a JSON event.
+func protoEvent(item *telemetryv1.Event) (storage.Event, error) {
+	event := storage.Event{
+		EventID:       item.GetEventId(),
+		EventType:     item.GetEventType(),
+		SchemaVersion: int(item.GetSchemaVersion()),
+	}
+	if ts := item.GetTimestamp(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return storage.Event{}, fmt.Errorf("invalid event: %w", err)
+		}
+		event.Timestamp = ts.AsTime()
+	}
+	if data := item.GetData(); data != nil {
+		raw, err := json.Marshal(data.AsMap())
+		if err != nil {
+			return storage.Event{}, fmt.Errorf("invalid event: %w", err)
+		}
+		event.Data = raw
+	}
+	if err := validateEvent(&event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
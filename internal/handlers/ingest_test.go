@@ -0,0 +1,216 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	telemetryv1 "github.com/kakhavain/telemetry-tracker/api/telemetry/v1"
+	"github.com/kakhavain/telemetry-tracker/internal/handlers"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newIngestClient serves an IngestServer over an in-memory connection, with
+// every call scoped to the "acme" tenant.
+func newIngestClient(t *testing.T, store storage.Writer) telemetryv1.IngestServiceClient {
+	t.Helper()
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	return serveIngest(t, handlers.NewEventHandler(store, reg, obs))
+}
+
+// serveIngest is newIngestClient for a configured handler, running checks
+// after the tenant is set.
+func serveIngest(t *testing.T, h *handlers.EventHandler, checks ...appmiddleware.GRPCCheck) telemetryv1.IngestServiceClient {
+	t.Helper()
+	checks = append([]appmiddleware.GRPCCheck{appmiddleware.GRPCStaticTenant("acme")}, checks...)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(appmiddleware.UnaryServerInterceptor(h.Obs.Logger(), checks...)),
+		grpc.ChainStreamInterceptor(appmiddleware.StreamServerInterceptor(h.Obs.Logger(), checks...)),
+	)
+	telemetryv1.RegisterIngestServiceServer(server, handlers.NewIngestServer(h))
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	be.NilErr(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return telemetryv1.NewIngestServiceClient(conn)
+}
+
+func TestIngestServer_Ingest(t *testing.T) {
+	data, _ := structpb.NewStruct(map[string]any{"amount": 42})
+	ts := time.Date(2025, 3, 28, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		events           []*telemetryv1.Event
+		storeErr         error
+		expectedCode     codes.Code
+		expectedAccepted int32
+		expectedErrors   []int32
+	}{
+		{
+			name: "Valid events",
+			events: []*telemetryv1.Event{
+				{EventType: "purchase", Timestamp: timestamppb.New(ts), Data: data},
+				{EventType: "login", EventId: "0190B2F4-5C1E-7A2B-9D3E-1F2A3B4C5D6E"},
+			},
+			expectedCode:     codes.OK,
+			expectedAccepted: 2,
+		},
+		{
+			name: "Invalid events are reported by index",
+			events: []*telemetryv1.Event{
+				{EventType: "purchase"},
+				{Data: data},
+				{EventType: "login", EventId: "not-an-id"},
+			},
+			expectedCode:     codes.OK,
+			expectedAccepted: 1,
+			expectedErrors:   []int32{1, 2},
+		},
+		{
+			name:         "Empty batch",
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Storage error",
+			events:       []*telemetryv1.Event{{EventType: "purchase"}},
+			storeErr:     errors.New("db failure"),
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored []storage.Event
+			client := newIngestClient(t, &mockStorer{
+				StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+					if tc.storeErr == nil {
+						stored = append(stored, events...)
+					}
+					return tc.storeErr
+				},
+			})
+
+			resp, err := client.Ingest(context.Background(), &telemetryv1.IngestRequest{Events: tc.events})
+			be.Equal(t, tc.expectedCode, status.Code(err))
+			if err != nil {
+				be.Equal(t, 0, len(stored))
+				return
+			}
+			be.Equal(t, tc.expectedAccepted, resp.GetAccepted())
+			be.Equal(t, int32(len(tc.expectedErrors)), resp.GetRejected())
+			for i, e := range resp.GetErrors() {
+				be.Equal(t, tc.expectedErrors[i], e.GetIndex())
+			}
+			be.Equal(t, int(tc.expectedAccepted), len(stored))
+			for _, event := range stored {
+				be.Equal(t, "acme", event.TenantID)
+			}
+		})
+	}
+
+	t.Run("Event mapping", func(t *testing.T) {
+		var stored []storage.Event
+		client := newIngestClient(t, &mockStorer{
+			StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+				stored = append(stored, events...)
+				return nil
+			},
+		})
+		_, err := client.Ingest(context.Background(), &telemetryv1.IngestRequest{Events: []*telemetryv1.Event{{
+			EventId:       "0190B2F4-5C1E-7A2B-9D3E-1F2A3B4C5D6E",
+			EventType:     "purchase",
+			Timestamp:     timestamppb.New(ts),
+			Data:          data,
+			SchemaVersion: 2,
+		}}})
+		be.NilErr(t, err)
+		be.Equal(t, 1, len(stored))
+		be.Equal(t, "0190b2f4-5c1e-7a2b-9d3e-1f2a3b4c5d6e", stored[0].EventID)
+		be.True(t, stored[0].Timestamp.Equal(ts))
+		be.Equal(t, `{"amount":42}`, string(stored[0].Data))
+		be.Equal(t, 2, stored[0].SchemaVersion)
+	})
+}
+
+func TestIngestServer_IngestStream(t *testing.T) {
+	var calls int
+	var stored []storage.Event
+	client := newIngestClient(t, &mockStorer{
+		StoreBatchFunc: func(ctx context.Context, events []storage.Event) error {
+			calls++
+			stored = append(stored, events...)
+			return nil
+		},
+	})
+
+	stream, err := client.IngestStream(context.Background())
+	be.NilErr(t, err)
+	be.NilErr(t, stream.Send(&telemetryv1.IngestRequest{Events: []*telemetryv1.Event{
+		{EventType: "a"}, {EventType: "b"},
+	}}))
+	be.NilErr(t, stream.Send(&telemetryv1.IngestRequest{Events: []*telemetryv1.Event{
+		{EventType: "c"}, {},
+	}}))
+	resp, err := stream.CloseAndRecv()
+	be.NilErr(t, err)
+
+	be.Equal(t, int32(3), resp.GetAccepted())
+	be.Equal(t, int32(1), resp.GetRejected())
+	be.Equal(t, int32(3), resp.GetErrors()[0].GetIndex())
+	be.Equal(t, 2, calls)
+	be.Equal(t, 3, len(stored))
+}
+
+func TestIngestServer_IngestStreamQuota(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	store := storage.NewMemoryStore()
+	tiers, err := ratelimit.ParseTiers("", "default=2", "")
+	be.NilErr(t, err)
+	h := handlers.NewEventHandler(store, reg, obs)
+	h.Quota = ratelimit.NewQuota(store, tiers, time.Minute, obs)
+	client := serveIngest(t, h, appmiddleware.GRPCQuota(h.Quota, tiers, reg))
+
+	stream, err := client.IngestStream(context.Background())
+	be.NilErr(t, err)
+	// The first message uses up the quota, so the stream fails on the next.
+	be.NilErr(t, stream.Send(&telemetryv1.IngestRequest{Events: []*telemetryv1.Event{
+		{EventType: "a"}, {EventType: "b"},
+	}}))
+	be.NilErr(t, stream.Send(&telemetryv1.IngestRequest{Events: []*telemetryv1.Event{
+		{EventType: "c"},
+	}}))
+	_, err = stream.CloseAndRecv()
+	be.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	header, err := stream.Header()
+	be.NilErr(t, err)
+	be.Equal(t, 1, len(header.Get("retry-after")))
+	page, err := store.QueryEvents(context.Background(), storage.EventQuery{TenantID: "acme", Limit: 10})
+	be.NilErr(t, err)
+	be.Equal(t, 2, len(page.Events))
+}
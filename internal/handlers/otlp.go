@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxOTLPBytes caps the size of an OTLP export request. Collectors batch
+// far more records per request than clients of /events/batch do.
+const maxOTLPBytes = 16 << 20
+
+const (
+	otlpProtobuf = "application/x-protobuf"
+	otlpJSON     = "application/json"
+)
+
+// otlpLogData is the event data a log record is stored as.
+type otlpLogData struct {
+	Body           any            `json:"body,omitempty"`
+	Attributes     map[string]any `json:"attributes,omitempty"`
+	Resource       map[string]any `json:"resource,omitempty"`
+	Scope          *otlpScope     `json:"scope,omitempty"`
+	SeverityText   string         `json:"severity_text,omitempty"`
+	SeverityNumber int32          `json:"severity_number,omitempty"`
+	TraceID        string         `json:"trace_id,omitempty"`
+	SpanID         string         `json:"span_id,omitempty"`
+}
+
+// otlpScope identifies the instrumentation scope that emitted a record.
+type otlpScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// ServeOTLPLogs handles POST requests to /v1/logs, the OTLP/HTTP logs
+// endpoint, so that OpenTelemetry SDKs and collectors can export to us
+// directly. Each log record in a protobuf or JSON export becomes an event;
+// records without an event type are reported back as a partial success.
+func (h *EventHandler) ServeOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeOTLPLogs")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for OTLP logs handler")
+	}
+
+	req, mediaType, status := parseOTLPLogsRequest(w, r, logger)
+	if status != http.StatusOK {
+		http.Error(w, fmt.Sprintf("Request Error: %d", status), status)
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		return
+	}
+
+	tenantID := appmiddleware.GetTenantFromContext(ctx)
+	var (
+		events   []storage.Event
+		rejected int64
+		firstErr error
+	)
+	for _, rl := range req.GetResourceLogs() {
+		resource := attributeMap(rl.GetResource().GetAttributes())
+		for _, sl := range rl.GetScopeLogs() {
+			for _, record := range sl.GetLogRecords() {
+				event, err := h.logRecordEvent(resource, sl.GetScope(), record)
+				if err == nil {
+					err = h.checkSchema(ctx, &event)
+				}
+				if err != nil {
+					rejected++
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				event.TenantID = tenantID
+				events = append(events, event)
+
+				h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+					metric.WithAttributes(attribute.String("event_type", event.EventType)),
+				)
+			}
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("otlp.log_records.accepted", len(events)),
+		attribute.Int64("otlp.log_records.rejected", rejected),
+	)
+
+	if len(events) > 0 {
+		duplicates, err := h.Store.StoreEvents(ctx, events)
+		if err != nil {
+			// OTLP exporters only retry 429, 502, 503 and 504, and a
+			// database fault is as transient as a full queue.
+			h.storeFailed(ctx, w, len(events), err)
+			logger.Error("Failed to store OTLP log records", slog.Any("error", err))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to store OTLP log records")
+			return
+		}
+		h.Metrics.EventsStoredTotal.Add(ctx, int64(len(events)-len(duplicates)))
+		h.countUsage(ctx, len(events)-len(duplicates))
+	}
+
+	logger.Info("OTLP log export processed",
+		slog.Int("accepted", len(events)),
+		slog.Int64("rejected", rejected),
+	)
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejected,
+			ErrorMessage:       firstErr.Error(),
+		}
+	}
+	writeOTLP(w, mediaType, resp)
+}
+
+// logRecordEvent maps a log record to an event. The event type is the
+// record's event name or, failing that, the string attribute named by
+// LogEventTypeAttribute.
+func (h *EventHandler) logRecordEvent(resource map[string]any, scope *commonpb.InstrumentationScope, record *logspb.LogRecord) (storage.Event, error) {
+	attrs := attributeMap(record.GetAttributes())
+	event := storage.Event{EventType: record.GetEventName()}
+	if event.EventType == "" && h.LogEventTypeAttribute != "" {
+		event.EventType, _ = attrs[h.LogEventTypeAttribute].(string)
+	}
+	if event.EventType == "" {
+		return storage.Event{}, fmt.Errorf("log record has no event name or %q attribute", h.LogEventTypeAttribute)
+	}
+
+	if ts := record.GetTimeUnixNano(); ts != 0 {
+		event.Timestamp = time.Unix(0, int64(ts)).UTC()
+	} else if ts := record.GetObservedTimeUnixNano(); ts != 0 {
+		event.Timestamp = time.Unix(0, int64(ts)).UTC()
+	}
+
+	data := otlpLogData{
+		Attributes:     attrs,
+		Resource:       resource,
+		SeverityText:   record.GetSeverityText(),
+		SeverityNumber: int32(record.GetSeverityNumber()),
+	}
+	if record.GetBody() != nil {
+		data.Body = anyValue(record.GetBody())
+	}
+	if scope.GetName() != "" || scope.GetVersion() != "" {
+		data.Scope = &otlpScope{Name: scope.GetName(), Version: scope.GetVersion()}
+	}
+	if id := record.GetTraceId(); len(id) > 0 {
+		data.TraceID = hex.EncodeToString(id)
+	}
+	if id := record.GetSpanId(); len(id) > 0 {
+		data.SpanID = hex.EncodeToString(id)
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("invalid log record: %w", err)
+	}
+	event.Data = raw
+
+	if err := validateEvent(&event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+// attributeMap converts OTLP attributes to a JSON object, or nil when
+// there are none.
+func attributeMap(kvs []*commonpb.KeyValue) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		m[kv.GetKey()] = anyValue(kv.GetValue())
+	}
+	return m
+}
+
+// anyValue converts an OTLP AnyValue to its JSON equivalent. Bytes become
+// base64 strings.
+func anyValue(v *commonpb.AnyValue) any {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, len(v.ArrayValue.GetValues()))
+		for i, item := range v.ArrayValue.GetValues() {
+			values[i] = anyValue(item)
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		m := attributeMap(v.KvlistValue.GetValues())
+		if m == nil {
+			m = map[string]any{}
+		}
+		return m
+	}
+	return nil
+}
+
+// parseOTLPLogsRequest decodes a protobuf or JSON export request and
+// returns it with the media type to respond in.
+func parseOTLPLogsRequest(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (*collogspb.ExportLogsServiceRequest, string, int) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != otlpProtobuf && mediaType != otlpJSON {
+		logger.Warn("Invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
+		return nil, "", http.StatusUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOTLPBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			logger.Warn("OTLP body too large", slog.Int64("limit", maxErr.Limit))
+			return nil, "", http.StatusRequestEntityTooLarge
+		}
+		logger.Warn("Failed to read OTLP body", slog.Any("error", err))
+		return nil, "", http.StatusBadRequest
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{}
+	if mediaType == otlpJSON {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+		fixJSONIDs(req)
+	} else {
+		err = proto.Unmarshal(body, req)
+	}
+	if err != nil {
+		logger.Warn("Failed to decode OTLP logs request", slog.Any("error", err), slog.String("content_type", mediaType))
+		return nil, "", http.StatusBadRequest
+	}
+	return req, mediaType, http.StatusOK
+}
+
+// fixJSONIDs undoes protojson's decoding of trace and span IDs. OTLP/JSON
+// sends them hex-encoded, while protojson decodes bytes fields as base64;
+// a 32-character hex trace ID thus arrives as 24 bytes instead of 16.
+func fixJSONIDs(req *collogspb.ExportLogsServiceRequest) {
+	for _, rl := range req.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			for _, record := range sl.GetLogRecords() {
+				record.TraceId = hexID(record.TraceId, 16)
+				record.SpanId = hexID(record.SpanId, 8)
+			}
+		}
+	}
+}
+
+func hexID(b []byte, size int) []byte {
+	if len(b) != size*3/2 {
+		return b
+	}
+	id, err := hex.DecodeString(base64.StdEncoding.EncodeToString(b))
+	if err != nil {
+		return b
+	}
+	return id
+}
+
+// writeOTLP encodes resp in the request's media type with status 200.
+func writeOTLP(w http.ResponseWriter, mediaType string, resp proto.Message) {
+	var (
+		body []byte
+		err  error
+	)
+	if mediaType == otlpJSON {
+		body, err = protojson.Marshal(resp)
+	} else {
+		body, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// defaultQueryLimit is the page size used when the request sets no limit.
+	defaultQueryLimit = 100
+	// maxQueryLimit caps the page size a client may request.
+	maxQueryLimit = 1000
+)
+
+// queryResponse is the body returned by GET /events.
+type queryResponse struct {
+	Events     []storage.StoredEvent `json:"events"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// QueryHandler serves reads of stored events.
+type QueryHandler struct {
+	Reader  storage.Reader
+	Metrics *metrics.Registry
+	Obs     observability.Provider
+}
+
+// NewQueryHandler constructs a QueryHandler.
+func NewQueryHandler(reader storage.Reader, metrics *metrics.Registry, obs observability.Provider) *QueryHandler {
+	return &QueryHandler{
+		Reader:  reader,
+		Metrics: metrics,
+		Obs:     obs,
+	}
+}
+
+// ServeHTTP handles GET requests to /events. Supported query parameters:
+//
+//	event_type                     repeatable; matches any of the given types
+//	since, until                   event timestamp range (RFC 3339), until exclusive
+//	received_since, received_until receive time range (RFC 3339), until exclusive
+//	data                           JSON object the event data must contain
+//	quarantined                    true or false
+//	limit                          page size, 1-1000 (default 100)
+//	cursor                         next_cursor from the previous page
+func (h *QueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "QueryEvents")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for query handler")
+	}
+
+	q, err := parseEventQuery(r.URL.Query())
+	if err != nil {
+		logger.Warn("Invalid event query", slog.Any("error", err))
+		span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	q.TenantID = appmiddleware.GetTenantFromContext(ctx)
+
+	page, err := h.Reader.QueryEvents(ctx, q)
+	if err != nil {
+		h.Metrics.DBErrorsTotal.Add(ctx, 1)
+		logger.Error("Failed to query events", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to query events")
+		return
+	}
+
+	resp := queryResponse{Events: page.Events}
+	if page.NextCursor != nil {
+		resp.NextCursor = page.NextCursor.Encode()
+	}
+	span.SetAttributes(attribute.Int("result_count", len(page.Events)))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseEventQuery translates URL query parameters into a storage query.
+func parseEventQuery(values url.Values) (storage.EventQuery, error) {
+	q := storage.EventQuery{
+		EventTypes: values["event_type"],
+		Limit:      defaultQueryLimit,
+	}
+
+	times := []struct {
+		param string
+		dst   *time.Time
+	}{
+		{"since", &q.Since},
+		{"until", &q.Until},
+		{"received_since", &q.ReceivedSince},
+		{"received_until", &q.ReceivedUntil},
+	}
+	for _, t := range times {
+		raw := values.Get(t.param)
+		if raw == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return storage.EventQuery{}, fmt.Errorf("'%s' must be an RFC 3339 timestamp", t.param)
+		}
+		*t.dst = ts.UTC()
+	}
+
+	if raw := values.Get("data"); raw != "" {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+			return storage.EventQuery{}, errors.New("'data' must be a JSON object")
+		}
+		q.DataContains = json.RawMessage(raw)
+	}
+
+	if raw := values.Get("quarantined"); raw != "" {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return storage.EventQuery{}, errors.New("'quarantined' must be true or false")
+		}
+		q.Quarantined = &b
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxQueryLimit {
+			return storage.EventQuery{}, fmt.Errorf("'limit' must be between 1 and %d", maxQueryLimit)
+		}
+		q.Limit = n
+	}
+
+	if raw := values.Get("cursor"); raw != "" {
+		c, err := storage.DecodeCursor(raw)
+		if err != nil {
+			return storage.EventQuery{}, errors.New("'cursor' is invalid")
+		}
+		q.After = &c
+	}
+
+	return q, nil
+}
@@ -0,0 +1,240 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/handlers"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+type mockReader struct {
+	QueryFunc     func(ctx context.Context, q storage.EventQuery) (storage.EventPage, error)
+	AggregateFunc func(ctx context.Context, q storage.AggregateQuery) ([]storage.AggregateRow, error)
+}
+
+func (m *mockReader) QueryEvents(ctx context.Context, q storage.EventQuery) (storage.EventPage, error) {
+	return m.QueryFunc(ctx, q)
+}
+
+func (m *mockReader) AggregateEvents(ctx context.Context, q storage.AggregateQuery) ([]storage.AggregateRow, error) {
+	return m.AggregateFunc(ctx, q)
+}
+
+func TestQueryHandler_ServeHTTP(t *testing.T) {
+	cursor := storage.Cursor{Timestamp: time.Date(2024, 3, 28, 12, 0, 0, 0, time.UTC), ID: 42}
+
+	tests := []struct {
+		name           string
+		query          string
+		queryErr       error
+		expectedStatus int
+		check          func(t *testing.T, q storage.EventQuery)
+	}{
+		{
+			name:           "Defaults",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, q storage.EventQuery) {
+				be.Equal(t, 100, q.Limit)
+				be.True(t, q.After == nil)
+			},
+		},
+		{
+			name:           "All filters",
+			query:          "?event_type=login&event_type=logout&since=2024-03-01T00:00:00Z&until=2024-04-01T00:00:00%2B02:00&received_since=2024-03-02T00:00:00Z&data=%7B%22platform%22%3A%22ios%22%7D&quarantined=false&limit=10&cursor=" + cursor.Encode(),
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, q storage.EventQuery) {
+				be.AllEqual(t, []string{"login", "logout"}, q.EventTypes)
+				be.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), q.Since)
+				be.Equal(t, time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC), q.Until)
+				be.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), q.ReceivedSince)
+				be.True(t, q.ReceivedUntil.IsZero())
+				be.Equal(t, `{"platform":"ios"}`, string(q.DataContains))
+				be.True(t, q.Quarantined != nil && !*q.Quarantined)
+				be.Equal(t, 10, q.Limit)
+				be.Equal(t, cursor, *q.After)
+			},
+		},
+		{name: "Invalid timestamp", query: "?since=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "Data is not an object", query: "?data=42", expectedStatus: http.StatusBadRequest},
+		{name: "Limit too large", query: "?limit=5000", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid cursor", query: "?cursor=not-a-cursor", expectedStatus: http.StatusBadRequest},
+		{name: "Query error", query: "", queryErr: errors.New("db down"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	obs, _ := observability.InitObservability("noop")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockReader{
+				QueryFunc: func(ctx context.Context, q storage.EventQuery) (storage.EventPage, error) {
+					if tc.check != nil {
+						tc.check(t, q)
+					}
+					return storage.EventPage{}, tc.queryErr
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewQueryHandler(mock, reg, obs)
+
+			req := httptest.NewRequest(http.MethodGet, "/events"+tc.query, nil)
+			req = req.WithContext(appmiddleware.WithLogger(req.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestQueryHandler_NextCursor(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+
+	next := storage.Cursor{Timestamp: time.Date(2024, 3, 28, 12, 0, 0, 123456000, time.UTC), ID: 7}
+	mock := &mockReader{
+		QueryFunc: func(ctx context.Context, q storage.EventQuery) (storage.EventPage, error) {
+			return storage.EventPage{
+				Events:     []storage.StoredEvent{{ID: 7, EventType: "login", Timestamp: next.Timestamp}},
+				NextCursor: &next,
+			}, nil
+		},
+	}
+	handler := handlers.NewQueryHandler(mock, reg, obs)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?limit=1", nil))
+	be.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Events     []storage.StoredEvent `json:"events"`
+		NextCursor string                `json:"next_cursor"`
+	}
+	be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	be.Equal(t, 1, len(resp.Events))
+
+	decoded, err := storage.DecodeCursor(resp.NextCursor)
+	be.NilErr(t, err)
+	be.Equal(t, next, decoded)
+}
+
+func TestQueryHandler_ServeAggregate(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		check          func(t *testing.T, q storage.AggregateQuery)
+	}{
+		{
+			name:           "Defaults",
+			query:          "",
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, q storage.AggregateQuery) {
+				be.Equal(t, "hour", q.Bucket)
+				be.Equal(t, "UTC", q.Timezone)
+				be.Equal(t, 24*time.Hour, q.Until.Sub(q.Since))
+				be.Equal(t, 0, len(q.GroupByPath))
+			},
+		},
+		{
+			name:           "Options",
+			query:          "?bucket=day&tz=America/New_York&since=2024-03-01T00:00:00Z&until=2024-04-01T00:00:00Z&event_type=click&group_by=data.device.os",
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, q storage.AggregateQuery) {
+				be.Equal(t, "day", q.Bucket)
+				be.Equal(t, "America/New_York", q.Timezone)
+				be.AllEqual(t, []string{"click"}, q.EventTypes)
+				be.AllEqual(t, []string{"device", "os"}, q.GroupByPath)
+			},
+		},
+		{name: "Invalid bucket", query: "?bucket=week", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown time zone", query: "?tz=Mars/Olympus", expectedStatus: http.StatusBadRequest},
+		{name: "Empty range", query: "?since=2024-03-02T00:00:00Z&until=2024-03-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "Too many buckets", query: "?bucket=minute&since=2024-01-01T00:00:00Z&until=2024-03-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "Group by outside data", query: "?group_by=event_type", expectedStatus: http.StatusBadRequest},
+		{name: "Group by injection", query: "?group_by=data.a'b", expectedStatus: http.StatusBadRequest},
+	}
+
+	obs, _ := observability.InitObservability("noop")
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockReader{
+				AggregateFunc: func(ctx context.Context, q storage.AggregateQuery) ([]storage.AggregateRow, error) {
+					if tc.check != nil {
+						tc.check(t, q)
+					}
+					return nil, nil
+				},
+			}
+
+			reg, _ := metrics.NewRegistry(obs.Meter())
+			handler := handlers.NewQueryHandler(mock, reg, obs)
+
+			rec := httptest.NewRecorder()
+			handler.ServeAggregate(rec, httptest.NewRequest(http.MethodGet, "/events/aggregate"+tc.query, nil))
+
+			be.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestQueryHandler_AggregateSeries(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+
+	ios, android := "ios", "android"
+	hour := func(h int) time.Time { return time.Date(2024, 3, 28, h, 0, 0, 0, time.UTC) }
+	mock := &mockReader{
+		AggregateFunc: func(ctx context.Context, q storage.AggregateQuery) ([]storage.AggregateRow, error) {
+			return []storage.AggregateRow{
+				{Bucket: hour(10), EventType: "view", Group: &ios, Count: 5},
+				{Bucket: hour(10), EventType: "click", Group: &ios, Count: 2},
+				{Bucket: hour(11), EventType: "click", Group: &android, Count: 1},
+				{Bucket: hour(11), EventType: "click", Group: &ios, Count: 3},
+			}, nil
+		},
+	}
+	handler := handlers.NewQueryHandler(mock, reg, obs)
+
+	rec := httptest.NewRecorder()
+	handler.ServeAggregate(rec, httptest.NewRequest(http.MethodGet, "/events/aggregate?tz=Europe/Berlin&group_by=data.platform", nil))
+	be.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		GroupBy string `json:"group_by"`
+		Series  []struct {
+			EventType string `json:"event_type"`
+			Group     string `json:"group"`
+			Total     int64  `json:"total"`
+			Points    []struct {
+				Bucket string `json:"bucket"`
+				Count  int64  `json:"count"`
+			} `json:"points"`
+		} `json:"series"`
+	}
+	be.NilErr(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	be.Equal(t, "data.platform", resp.GroupBy)
+	be.Equal(t, 3, len(resp.Series))
+	be.Equal(t, "click", resp.Series[0].EventType)
+	be.Equal(t, "android", resp.Series[0].Group)
+	be.Equal(t, "ios", resp.Series[1].Group)
+	be.Equal(t, int64(5), resp.Series[1].Total)
+	be.Equal(t, 2, len(resp.Series[1].Points))
+	be.Equal(t, "2024-03-28T11:00:00+01:00", resp.Series[1].Points[0].Bucket)
+	be.Equal(t, "view", resp.Series[2].EventType)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxSchemaBytes caps the size of a schema document uploaded through the admin API.
+const maxSchemaBytes = 1 << 20
+
+// SchemaHandler exposes the schema registry under /admin/schemas.
+type SchemaHandler struct {
+	Registry *schema.Registry
+	Obs      observability.Provider
+}
+
+// NewSchemaHandler constructs a SchemaHandler.
+func NewSchemaHandler(registry *schema.Registry, obs observability.Provider) *SchemaHandler {
+	return &SchemaHandler{Registry: registry, Obs: obs}
+}
+
+// Routes mounts the admin schema endpoints on r.
+func (h *SchemaHandler) Routes(r chi.Router) {
+	r.Get("/", h.List)
+	r.Get("/{eventType}", h.Get)
+	r.Get("/{eventType}/{version}", h.Get)
+	r.Put("/{eventType}/{version}", h.Put)
+	r.Delete("/{eventType}/{version}", h.Delete)
+}
+
+// List handles GET /admin/schemas.
+func (h *SchemaHandler) List(w http.ResponseWriter, r *http.Request) {
+	_, span := h.Obs.Tracer().Start(r.Context(), "ListSchemas")
+	defer span.End()
+
+	writeJSON(w, http.StatusOK, map[string]any{"schemas": h.Registry.List()})
+}
+
+// Get handles GET /admin/schemas/{eventType}[/{version}]. Without a version
+// the latest registered schema is returned.
+func (h *SchemaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, span := h.Obs.Tracer().Start(r.Context(), "GetSchema")
+	defer span.End()
+
+	version := 0
+	if chi.URLParam(r, "version") != "" {
+		var ok bool
+		if version, ok = parseSchemaVersion(w, r); !ok {
+			return
+		}
+	}
+	raw, resolved, ok := h.Registry.Get(chi.URLParam(r, "eventType"), version)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Schema-Version", strconv.Itoa(resolved))
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(raw)
+}
+
+// Put handles PUT /admin/schemas/{eventType}/{version}. The body is the
+// JSON Schema document; it is compiled before it replaces any existing one.
+func (h *SchemaHandler) Put(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "PutSchema")
+	defer span.End()
+
+	logger := h.logger(ctx)
+	version, ok := parseSchemaVersion(w, r)
+	if !ok {
+		return
+	}
+	eventType := chi.URLParam(r, "eventType")
+	span.SetAttributes(attribute.String("event_type", eventType), attribute.Int("schema_version", version))
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSchemaBytes))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(body) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "schema is not valid JSON"})
+		return
+	}
+	if err := h.Registry.Put(eventType, version, body); err != nil {
+		if errors.Is(err, schema.ErrPersist) {
+			logger.Error("Failed to persist schema", slog.String("event_type", eventType), slog.Int("version", version), slog.Any("error", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logger.Warn("Rejected schema", slog.String("event_type", eventType), slog.Int("version", version), slog.Any("error", err))
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error(), EventType: eventType, Version: version})
+		return
+	}
+	logger.Info("Schema registered", slog.String("event_type", eventType), slog.Int("version", version))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete handles DELETE /admin/schemas/{eventType}/{version}.
+func (h *SchemaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "DeleteSchema")
+	defer span.End()
+
+	logger := h.logger(ctx)
+	version, ok := parseSchemaVersion(w, r)
+	if !ok {
+		return
+	}
+	eventType := chi.URLParam(r, "eventType")
+	found, err := h.Registry.Delete(eventType, version)
+	if err != nil {
+		logger.Error("Failed to delete schema", slog.String("event_type", eventType), slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	logger.Info("Schema deleted", slog.String("event_type", eventType), slog.Int("version", version))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SchemaHandler) logger(ctx context.Context) *slog.Logger {
+	if logger := appmiddleware.GetLoggerFromContext(ctx); logger != nil {
+		return logger
+	}
+	return h.Obs.Logger()
+}
+
+// parseSchemaVersion reads the {version} URL parameter, accepting "3" or "v3".
+func parseSchemaVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := chi.URLParam(r, "version")
+	if len(raw) > 1 && raw[0] == 'v' {
+		raw = raw[1:]
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "schema version must be a positive integer"})
+		return 0, false
+	}
+	return version, true
+}
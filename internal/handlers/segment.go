@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/schema"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Segment message types. Track messages are stored under their event name,
+// every other type under the type itself.
+const (
+	segmentTrack    = "track"
+	segmentIdentify = "identify"
+	segmentPage     = "page"
+	segmentScreen   = "screen"
+	segmentGroup    = "group"
+	segmentAlias    = "alias"
+)
+
+var (
+	errMissingSegmentEvent    = errors.New("missing 'event' field")
+	errMissingSegmentIdentity = errors.New("missing 'userId' or 'anonymousId' field")
+)
+
+// segmentMessage is a message in the Segment HTTP API format. Fields that
+// are not stored, such as integrations, are accepted and dropped.
+type segmentMessage struct {
+	Type              string          `json:"type"`
+	MessageID         string          `json:"messageId"`
+	UserID            string          `json:"userId"`
+	AnonymousID       string          `json:"anonymousId"`
+	Event             string          `json:"event"`
+	Name              string          `json:"name"`
+	Category          string          `json:"category"`
+	GroupID           string          `json:"groupId"`
+	PreviousID        string          `json:"previousId"`
+	Properties        json.RawMessage `json:"properties"`
+	Traits            json.RawMessage `json:"traits"`
+	Context           json.RawMessage `json:"context"`
+	Timestamp         time.Time       `json:"timestamp"`
+	OriginalTimestamp time.Time       `json:"originalTimestamp"`
+	SentAt            time.Time       `json:"sentAt"`
+}
+
+// segmentBatch is the body of POST /v1/batch. Its sentAt applies to
+// messages that do not carry their own.
+type segmentBatch struct {
+	Batch  []json.RawMessage `json:"batch"`
+	SentAt time.Time         `json:"sentAt"`
+}
+
+// segmentData is the event data a Segment message is stored as.
+type segmentData struct {
+	Properties json.RawMessage `json:"properties,omitempty"`
+	Traits     json.RawMessage `json:"traits,omitempty"`
+	Context    json.RawMessage `json:"context,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Category   string          `json:"category,omitempty"`
+	GroupID    string          `json:"group_id,omitempty"`
+	PreviousID string          `json:"previous_id,omitempty"`
+}
+
+// segmentResponse is the body returned by the Segment-compatible
+// endpoints. Segment libraries only look at the status code; rejected
+// batch messages are listed for everyone else.
+type segmentResponse struct {
+	Success  bool              `json:"success"`
+	Rejected int               `json:"rejected,omitempty"`
+	Errors   []batchItemResult `json:"errors,omitempty"`
+}
+
+// ServeSegment returns a handler for POST /v1/<msgType>, the Segment HTTP
+// API endpoint for a single message of that type, so that Segment
+// libraries can send to us directly. The type in the path takes precedence
+// over one in the body, as it does for Segment.
+func (h *EventHandler) ServeSegment(msgType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeSegment")
+		defer span.End()
+		span.SetAttributes(attribute.String("segment.type", msgType))
+
+		logger := appmiddleware.GetLoggerFromContext(ctx)
+		if logger == nil {
+			logger = h.Obs.Logger()
+			logger.Warn("Logger not found in context for Segment handler")
+		}
+
+		body, status := readSegmentBody(w, r, logger)
+		if status != http.StatusOK {
+			http.Error(w, fmt.Sprintf("Request Error: %d", status), status)
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			return
+		}
+		event, err := decodeSegmentMessage(body, msgType, time.Time{}, time.Now())
+		if err != nil {
+			logger.Warn("Invalid Segment message in request", slog.Any("error", err))
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		event.TenantID = appmiddleware.GetTenantFromContext(ctx)
+		logger = logger.With(slog.String("event_type", event.EventType))
+
+		if err := h.checkSchema(ctx, &event); err != nil {
+			logger.Warn("Event failed schema validation", slog.Any("error", err))
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusBadRequest))
+			writeSchemaError(w, event, err)
+			return
+		}
+
+		h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("event_type", event.EventType)),
+		)
+
+		err = h.Store.StoreEvent(ctx, event)
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			logger.Info("Duplicate event ignored", slog.String("event_id", event.EventID))
+			span.SetAttributes(attribute.Bool("duplicate", true))
+			writeJSON(w, http.StatusOK, segmentResponse{Success: true})
+			return
+		}
+		if err != nil {
+			status := h.storeFailed(ctx, w, 1, err)
+			logger.Error("Failed to store event", slog.Any("error", err))
+			http.Error(w, http.StatusText(status), status)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to store event")
+			return
+		}
+
+		h.Metrics.EventsStoredTotal.Add(ctx, 1)
+		h.countUsage(ctx, 1)
+		logger.Info("Event stored successfully")
+		writeJSON(w, http.StatusOK, segmentResponse{Success: true})
+	}
+}
+
+// ServeSegmentBatch handles POST requests to /v1/batch, the Segment HTTP
+// API batch endpoint. As with /events/batch, each message is validated
+// independently and the valid ones are stored together.
+func (h *EventHandler) ServeSegmentBatch(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeSegmentBatch")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for Segment handler")
+	}
+
+	body, status := readSegmentBody(w, r, logger)
+	if status != http.StatusOK {
+		http.Error(w, fmt.Sprintf("Request Error: %d", status), status)
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		return
+	}
+	var batch segmentBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		logger.Warn("Failed to decode Segment batch", slog.Any("error", err))
+		http.Error(w, fmt.Sprintf("Request Error: %d", http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Batch) == 0 {
+		logger.Warn("Empty event batch")
+		http.Error(w, fmt.Sprintf("Request Error: %d", http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Batch) > maxBatchEvents {
+		logger.Warn("Too many events in batch", slog.Int("count", len(batch.Batch)), slog.Int("limit", maxBatchEvents))
+		http.Error(w, fmt.Sprintf("Request Error: %d", http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	tenantID := appmiddleware.GetTenantFromContext(ctx)
+	now := time.Now()
+	resp := segmentResponse{Success: true}
+	events := make([]storage.Event, 0, len(batch.Batch))
+	for i, item := range batch.Batch {
+		event, err := decodeSegmentMessage(item, "", batch.SentAt, now)
+		if err == nil {
+			err = h.checkSchema(ctx, &event)
+		}
+		if err != nil {
+			result := batchItemResult{Index: i, Status: batchStatusRejected, Reason: err.Error()}
+			var ve *schema.ValidationError
+			if errors.As(err, &ve) {
+				result.Violations = ve.Violations
+			}
+			resp.Errors = append(resp.Errors, result)
+			resp.Rejected++
+			continue
+		}
+		event.TenantID = tenantID
+		events = append(events, event)
+
+		h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("event_type", event.EventType)),
+		)
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch.size", len(batch.Batch)),
+		attribute.Int("batch.accepted", len(events)),
+		attribute.Int("batch.rejected", resp.Rejected),
+	)
+	logger = logger.With(slog.Int("batch_size", len(batch.Batch)))
+
+	if len(events) > 0 {
+		duplicates, err := h.Store.StoreEvents(ctx, events)
+		if err != nil {
+			status := h.storeFailed(ctx, w, len(events), err)
+			logger.Error("Failed to store event batch", slog.Any("error", err))
+			http.Error(w, http.StatusText(status), status)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to store event batch")
+			return
+		}
+		h.Metrics.EventsStoredTotal.Add(ctx, int64(len(events)-len(duplicates)))
+		h.countUsage(ctx, len(events)-len(duplicates))
+	}
+
+	logger.Info("Event batch processed",
+		slog.Int("accepted", len(events)),
+		slog.Int("rejected", resp.Rejected),
+	)
+
+	status = http.StatusOK
+	if len(events) == 0 {
+		resp.Success = false
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// readSegmentBody reads a JSON request body of at most maxBatchBytes.
+func readSegmentBody(w http.ResponseWriter, r *http.Request, logger *slog.Logger) ([]byte, int) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		logger.Warn("Invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
+		return nil, http.StatusUnsupportedMediaType
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			logger.Warn("Segment body too large", slog.Int64("limit", maxErr.Limit))
+			return nil, http.StatusRequestEntityTooLarge
+		}
+		logger.Warn("Failed to read Segment body", slog.Any("error", err))
+		return nil, http.StatusBadRequest
+	}
+	return body, http.StatusOK
+}
+
+// decodeSegmentMessage decodes a Segment message and maps it onto a
+// storage.Event. msgType, when set, overrides the message's type; sentAt
+// is used when the message has no sentAt of its own, and now is when the
+// message was received.
+func decodeSegmentMessage(item json.RawMessage, msgType string, sentAt, now time.Time) (storage.Event, error) {
+	var msg segmentMessage
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	if err := decoder.Decode(&msg); err != nil {
+		return storage.Event{}, fmt.Errorf("invalid Segment message: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return storage.Event{}, errors.New("invalid Segment message: unexpected data after message")
+	}
+	if msgType != "" {
+		msg.Type = msgType
+	}
+	if msg.SentAt.IsZero() {
+		msg.SentAt = sentAt
+	}
+	return msg.event(now)
+}
+
+// event maps the message onto a storage.Event and validates it. A
+// messageId that is a UUID or ULID also becomes the event_id, so that
+// retried messages are deduplicated.
+func (msg segmentMessage) event(now time.Time) (storage.Event, error) {
+	event := storage.Event{UserID: msg.UserID, AnonymousID: msg.AnonymousID}
+	data := segmentData{Context: msg.Context}
+	switch msg.Type {
+	case segmentTrack:
+		if msg.Event == "" {
+			return storage.Event{}, errMissingSegmentEvent
+		}
+		event.EventType = msg.Event
+		data.Properties = msg.Properties
+	case segmentIdentify:
+		data.Traits = msg.Traits
+	case segmentPage, segmentScreen:
+		data.Name = msg.Name
+		data.Category = msg.Category
+		data.Properties = msg.Properties
+	case segmentGroup:
+		data.GroupID = msg.GroupID
+		data.Traits = msg.Traits
+	case segmentAlias:
+		data.PreviousID = msg.PreviousID
+	default:
+		return storage.Event{}, fmt.Errorf("unsupported Segment message type %q", msg.Type)
+	}
+	if event.EventType == "" {
+		event.EventType = msg.Type
+	}
+	if msg.UserID == "" && msg.AnonymousID == "" {
+		return storage.Event{}, errMissingSegmentIdentity
+	}
+
+	event.Timestamp = msg.timestamp(now)
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return storage.Event{}, fmt.Errorf("invalid Segment message: %w", err)
+	}
+	event.Data = raw
+	if uuidPattern.MatchString(msg.MessageID) || ulidPattern.MatchString(msg.MessageID) {
+		event.EventID = msg.MessageID
+	}
+	if err := validateEvent(&event); err != nil {
+		return storage.Event{}, err
+	}
+	return event, nil
+}
+
+// timestamp returns when the message happened. As Segment does, an explicit
+// timestamp is taken as is, while originalTimestamp, the client's own
+// clock, is corrected for skew using sentAt when the client sent one: the
+// message happened as long before now as it did before sentAt on the
+// client's clock.
+func (msg segmentMessage) timestamp(now time.Time) time.Time {
+	if !msg.Timestamp.IsZero() {
+		return msg.Timestamp
+	}
+	ts := msg.OriginalTimestamp
+	if ts.IsZero() || msg.SentAt.IsZero() {
+		return ts
+	}
+	return now.Add(-msg.SentAt.Sub(ts)).UTC()
+}
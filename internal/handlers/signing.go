@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/signing"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SigningSecretHandler exposes signing secret management under
+// /admin/signing-secrets.
+type SigningSecretHandler struct {
+	Secrets *signing.Manager
+	Obs     observability.Provider
+}
+
+// NewSigningSecretHandler constructs a SigningSecretHandler.
+func NewSigningSecretHandler(secrets *signing.Manager, obs observability.Provider) *SigningSecretHandler {
+	return &SigningSecretHandler{Secrets: secrets, Obs: obs}
+}
+
+// Routes mounts the admin signing secret endpoints on r.
+func (h *SigningSecretHandler) Routes(r chi.Router) {
+	r.Get("/", h.List)
+	r.Post("/", h.Create)
+	r.Get("/{id}", h.Get)
+	r.Delete("/{id}", h.Revoke)
+	r.Post("/{id}/rotate", h.Rotate)
+}
+
+// createSecretRequest is the body of POST /admin/signing-secrets.
+type createSecretRequest struct {
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+}
+
+// issuedSecretResponse carries a newly issued secret. This is the only
+// time the secret itself is returned.
+type issuedSecretResponse struct {
+	Secret        string                `json:"secret"`
+	SigningSecret storage.SigningSecret `json:"signing_secret"`
+}
+
+// List handles GET /admin/signing-secrets, optionally filtered by
+// ?tenant_id=.
+func (h *SigningSecretHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ListSigningSecrets")
+	defer span.End()
+
+	secrets, err := h.Secrets.List(ctx, r.URL.Query().Get("tenant_id"))
+	if err != nil {
+		h.logger(ctx).Error("Failed to list signing secrets", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if secrets == nil {
+		secrets = []storage.SigningSecret{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"signing_secrets": secrets})
+}
+
+// Get handles GET /admin/signing-secrets/{id}.
+func (h *SigningSecretHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "GetSigningSecret")
+	defer span.End()
+
+	secret, err := h.Secrets.Get(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(ctx, w, "Failed to get signing secret", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, secret)
+}
+
+// Create handles POST /admin/signing-secrets. Once a tenant has an active
+// secret, its event requests must be signed.
+func (h *SigningSecretHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "CreateSigningSecret")
+	defer span.End()
+
+	var req createSecretRequest
+	if !decodeAdminRequest(w, r, &req) {
+		return
+	}
+	span.SetAttributes(attribute.String("tenant.id", req.TenantID))
+
+	record, err := h.Secrets.Create(ctx, req.TenantID, req.Name)
+	if err != nil {
+		h.writeError(ctx, w, "Failed to create signing secret", err)
+		return
+	}
+	h.logger(ctx).Info("Signing secret created", slog.String("signing_secret_id", record.ID), slog.String("tenant_id", record.TenantID))
+	writeJSON(w, http.StatusCreated, issuedSecretResponse{Secret: record.Secret, SigningSecret: record})
+}
+
+// Rotate handles POST /admin/signing-secrets/{id}/rotate. Signatures made
+// with the old secret keep verifying for the grace period (24h unless
+// given).
+func (h *SigningSecretHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "RotateSigningSecret")
+	defer span.End()
+
+	var req rotateRequest
+	if r.ContentLength != 0 && !decodeAdminRequest(w, r, &req) {
+		return
+	}
+	grace := defaultRotationGrace
+	if req.GracePeriod != nil {
+		grace = time.Duration(*req.GracePeriod)
+	}
+
+	id := chi.URLParam(r, "id")
+	record, err := h.Secrets.Rotate(ctx, id, grace)
+	if err != nil {
+		h.writeError(ctx, w, "Failed to rotate signing secret", err)
+		return
+	}
+	h.logger(ctx).Info("Signing secret rotated",
+		slog.String("signing_secret_id", id),
+		slog.String("replacement_id", record.ID),
+		slog.Duration("grace_period", grace),
+	)
+	writeJSON(w, http.StatusCreated, issuedSecretResponse{Secret: record.Secret, SigningSecret: record})
+}
+
+// Revoke handles DELETE /admin/signing-secrets/{id}.
+func (h *SigningSecretHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "RevokeSigningSecret")
+	defer span.End()
+
+	record, err := h.Secrets.Revoke(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(ctx, w, "Failed to revoke signing secret", err)
+		return
+	}
+	h.logger(ctx).Info("Signing secret revoked", slog.String("signing_secret_id", record.ID), slog.String("tenant_id", record.TenantID))
+	writeJSON(w, http.StatusOK, record)
+}
+
+// writeError maps signing secret management errors to responses.
+func (h *SigningSecretHandler) writeError(ctx context.Context, w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrSecretNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, apikey.ErrInvalidTenant):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, signing.ErrInactive):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	default:
+		h.logger(ctx).Error(msg, slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *SigningSecretHandler) logger(ctx context.Context) *slog.Logger {
+	if logger := appmiddleware.GetLoggerFromContext(ctx); logger != nil {
+		return logger
+	}
+	return h.Obs.Logger()
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// streamFlushEvents is the number of decoded events buffered before they
+	// are written to storage in one batch.
+	streamFlushEvents = 500
+	// streamIdleTimeout bounds how long the stream may go without delivering a line.
+	streamIdleTimeout = 30 * time.Second
+	// maxStreamLineBytes caps the size of a single NDJSON line.
+	maxStreamLineBytes = 1 << 20
+	// maxStreamErrors caps how many per-line errors are echoed back.
+	maxStreamErrors = 100
+)
+
+// streamLineError reports why a single NDJSON line was rejected.
+type streamLineError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// streamResponse is the body returned by POST /events/stream.
+type streamResponse struct {
+	Accepted        int               `json:"accepted"`
+	Rejected        int               `json:"rejected"`
+	Errors          []streamLineError `json:"errors,omitempty"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// ServeStream handles POST requests to /events/stream. The body is
+// newline-delimited JSON (application/x-ndjson) of any length; it is decoded
+// line by line and flushed to storage every streamFlushEvents events, so the
+// full body is never held in memory.
+func (h *EventHandler) ServeStream(w http.ResponseWriter, r *http.Request) {
+	ctx, span := h.Obs.Tracer().Start(r.Context(), "ServeStream")
+	defer span.End()
+
+	logger := appmiddleware.GetLoggerFromContext(ctx)
+	if logger == nil {
+		logger = h.Obs.Logger()
+		logger.Warn("Logger not found in context for stream handler")
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-ndjson" {
+		logger.Warn("Invalid content type", slog.String("content_type", r.Header.Get("Content-Type")))
+		http.Error(w, "Request Error: 415", http.StatusUnsupportedMediaType)
+		span.SetAttributes(attribute.Int("http.status_code", http.StatusUnsupportedMediaType))
+		return
+	}
+
+	// The server-wide read/write timeouts are sized for single events; a long
+	// stream instead gets an idle deadline that is pushed out on every line.
+	rc := http.NewResponseController(w)
+	extendDeadlines := func() {
+		deadline := time.Now().Add(streamIdleTimeout)
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+	}
+	extendDeadlines()
+
+	tenantID := appmiddleware.GetTenantFromContext(ctx)
+	var resp streamResponse
+	pending := make([]storage.Event, 0, streamFlushEvents)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		duplicates, err := h.Store.StoreEvents(ctx, pending)
+		if err != nil {
+			return err
+		}
+		h.Metrics.EventsStoredTotal.Add(ctx, int64(len(pending)-len(duplicates)))
+		h.countUsage(ctx, len(pending)-len(duplicates))
+		resp.Accepted += len(pending)
+		pending = pending[:0]
+		return nil
+	}
+	reject := func(line int, err error) {
+		resp.Rejected++
+		if len(resp.Errors) >= maxStreamErrors {
+			resp.ErrorsTruncated = true
+			return
+		}
+		resp.Errors = append(resp.Errors, streamLineError{Line: line, Reason: err.Error()})
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxStreamLineBytes)
+	line := 0
+	for scanner.Scan() {
+		extendDeadlines()
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		event, err := decodeEvent(raw)
+		if err == nil {
+			err = h.checkSchema(ctx, &event)
+		}
+		if err != nil {
+			reject(line, err)
+			continue
+		}
+		h.Metrics.EventsReceivedTotal.Add(ctx, 1,
+			metric.WithAttributes(attribute.String("event_type", event.EventType)),
+		)
+		event.TenantID = tenantID
+		pending = append(pending, event)
+
+		if len(pending) >= streamFlushEvents {
+			if err := flush(); err != nil {
+				h.failStream(ctx, w, logger, &resp, len(pending), err)
+				return
+			}
+		}
+	}
+
+	status := http.StatusAccepted
+	if err := scanner.Err(); err != nil {
+		logger.Warn("Failed to read event stream", slog.Int("line", line+1), slog.Any("error", err))
+		resp.Error = "stream aborted: " + err.Error()
+		status = http.StatusBadRequest
+		var maxErr *http.MaxBytesError
+		if errors.Is(err, bufio.ErrTooLong) || errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+	}
+
+	// Events decoded before a read error are still stored.
+	if err := flush(); err != nil {
+		h.failStream(ctx, w, logger, &resp, len(pending), err)
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("stream.lines", line),
+		attribute.Int("stream.accepted", resp.Accepted),
+		attribute.Int("stream.rejected", resp.Rejected),
+	)
+	logger.Info("Event stream processed",
+		slog.Int("lines", line),
+		slog.Int("accepted", resp.Accepted),
+		slog.Int("rejected", resp.Rejected),
+	)
+
+	if status == http.StatusAccepted && resp.Accepted == 0 && resp.Rejected > 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// failStream reports a storage failure part way through a stream. Events
+// flushed before the failure remain stored and are reported as accepted.
+func (h *EventHandler) failStream(ctx context.Context, w http.ResponseWriter, logger *slog.Logger, resp *streamResponse, events int, err error) {
+	span := trace.SpanFromContext(ctx)
+	status := h.storeFailed(ctx, w, events, err)
+	logger.Error("Failed to store event stream chunk", slog.Int("accepted", resp.Accepted), slog.Any("error", err))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "Failed to store event stream chunk")
+	resp.Error = "failed to store events"
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,133 @@
+// Package health runs the dependency checks behind the liveness and
+// readiness probes.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Probe selects which endpoint a check contributes to.
+type Probe string
+
+const (
+	Liveness  Probe = "liveness"  // the process is able to make progress
+	Readiness Probe = "readiness" // the instance should receive traffic
+)
+
+// Overall and per-check statuses.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // only optional checks failed
+	StatusFail     = "fail"
+)
+
+// Check reports a dependency as unhealthy by returning an error.
+type Check func(ctx context.Context) error
+
+// Checker is a named check. A failing optional check is reported but only
+// degrades the probe instead of failing it.
+type Checker struct {
+	Name     string
+	Check    Check
+	Optional bool
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Optional  bool    `json:"optional,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of every check registered for a probe.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Registry holds the checks for each probe. It is safe for concurrent use.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[Probe][]Checker
+}
+
+// NewRegistry creates an empty registry. Each check is given at most
+// timeout to complete.
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Registry{timeout: timeout, checks: make(map[Probe][]Checker)}
+}
+
+// Register adds a check to a probe.
+func (r *Registry) Register(probe Probe, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[probe] = append(r.checks[probe], c)
+}
+
+// Run executes the checks for probe concurrently and reports them in
+// registration order.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	r.mu.RLock()
+	checks := r.checks[probe]
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusOK {
+			continue
+		}
+		if !res.Optional {
+			report.Status = StatusFail
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// A check that ignores its context must not hold the probe open.
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := Result{
+		Name:      c.Name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Optional:  c.Optional,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
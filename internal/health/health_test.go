@@ -0,0 +1,59 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/health"
+)
+
+func TestRegistryRun(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("down") }
+
+	tests := []struct {
+		name     string
+		checkers []health.Checker
+		want     string
+	}{
+		{"no checks", nil, health.StatusOK},
+		{"all ok", []health.Checker{{Name: "a", Check: ok}, {Name: "b", Check: ok, Optional: true}}, health.StatusOK},
+		{"optional failed", []health.Checker{{Name: "a", Check: ok}, {Name: "b", Check: fail, Optional: true}}, health.StatusDegraded},
+		{"required failed", []health.Checker{{Name: "a", Check: fail, Optional: true}, {Name: "b", Check: fail}}, health.StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := health.NewRegistry(time.Second)
+			for _, c := range tt.checkers {
+				r.Register(health.Readiness, c)
+			}
+			report := r.Run(context.Background(), health.Readiness)
+			be.Equal(t, tt.want, report.Status)
+			be.Equal(t, len(tt.checkers), len(report.Checks))
+			for i, res := range report.Checks {
+				be.Equal(t, tt.checkers[i].Name, res.Name)
+			}
+
+			// Liveness is unaffected by readiness checks.
+			be.Equal(t, health.StatusOK, r.Run(context.Background(), health.Liveness).Status)
+		})
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := health.NewRegistry(20 * time.Millisecond)
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	r.Register(health.Readiness, health.Checker{
+		Name: "stuck",
+		// Ignores its context, like a driver call without deadline support.
+		Check: func(context.Context) error { <-block; return nil },
+	})
+
+	report := r.Run(context.Background(), health.Readiness)
+	be.Equal(t, health.StatusFail, report.Status)
+	be.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
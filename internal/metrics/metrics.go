@@ -11,6 +11,18 @@ type Registry struct {
 	HTTPRequestTotal    metric.Int64Counter
 	RequestDuration     metric.Float64Histogram
 	ResponseSizeBytes   metric.Int64Histogram
+	QueueDepth          metric.Int64UpDownCounter
+	EventsDroppedTotal  metric.Int64Counter
+	EventsRejectedTotal metric.Int64Counter
+	SpoolDepth          metric.Int64UpDownCounter
+	SpoolBytes          metric.Int64UpDownCounter
+	SpoolReplayLag      metric.Float64Gauge
+	RetentionPurged     metric.Int64Counter
+	RetentionDuration   metric.Float64Histogram
+	RateLimitedTotal    metric.Int64Counter
+
+	RequestCompressedBytes   metric.Int64Counter
+	RequestDecompressedBytes metric.Int64Counter
 }
 
 func NewRegistry(meter metric.Meter) (*Registry, error) {
@@ -35,6 +47,39 @@ func NewRegistry(meter metric.Meter) (*Registry, error) {
 	if r.ResponseSizeBytes, err = meter.Int64Histogram("telemetry_tracker.http_response_size_bytes"); err != nil {
 		return nil, err
 	}
+	if r.QueueDepth, err = meter.Int64UpDownCounter("telemetry_tracker.queue_depth"); err != nil {
+		return nil, err
+	}
+	if r.EventsDroppedTotal, err = meter.Int64Counter("telemetry_tracker.events_dropped_total"); err != nil {
+		return nil, err
+	}
+	if r.EventsRejectedTotal, err = meter.Int64Counter("telemetry_tracker.events_rejected_total"); err != nil {
+		return nil, err
+	}
+	if r.SpoolDepth, err = meter.Int64UpDownCounter("telemetry_tracker.spool_depth"); err != nil {
+		return nil, err
+	}
+	if r.SpoolBytes, err = meter.Int64UpDownCounter("telemetry_tracker.spool_bytes"); err != nil {
+		return nil, err
+	}
+	if r.SpoolReplayLag, err = meter.Float64Gauge("telemetry_tracker.spool_replay_lag_seconds"); err != nil {
+		return nil, err
+	}
+	if r.RetentionPurged, err = meter.Int64Counter("telemetry_tracker.retention_rows_purged_total"); err != nil {
+		return nil, err
+	}
+	if r.RetentionDuration, err = meter.Float64Histogram("telemetry_tracker.retention_run_duration_seconds"); err != nil {
+		return nil, err
+	}
+	if r.RateLimitedTotal, err = meter.Int64Counter("telemetry_tracker.rate_limited_requests_total"); err != nil {
+		return nil, err
+	}
+	if r.RequestCompressedBytes, err = meter.Int64Counter("telemetry_tracker.http_request_compressed_bytes_total"); err != nil {
+		return nil, err
+	}
+	if r.RequestDecompressedBytes, err = meter.Int64Counter("telemetry_tracker.http_request_decompressed_bytes_total"); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
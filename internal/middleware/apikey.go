@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	tenantKey   struct{}
+	apiKeyIDKey struct{}
+)
+
+// Authenticator resolves an API key to its stored record.
+type Authenticator interface {
+	Authenticate(ctx context.Context, key string) (storage.APIKey, error)
+}
+
+// RequireAPIKey rejects requests without an active API key, given as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", and scopes the rest
+// of the request to the key's tenant. Segment libraries send their write
+// key as a Basic auth username instead, so that is accepted too.
+func RequireAPIKey(auth Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				key = bearer
+			} else if username, _, ok := r.BasicAuth(); ok {
+				key = username
+			}
+			record, err := auth.Authenticate(r.Context(), key)
+			if err != nil {
+				if !errors.Is(err, apikey.ErrInvalidKey) {
+					// The key store is unreachable; the key may well be valid.
+					if logger := GetLoggerFromContext(r.Context()); logger != nil {
+						logger.Error("Failed to authenticate API key", slog.Any("error", err))
+					}
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="events"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ctx := WithTenant(r.Context(), record.TenantID)
+			ctx = context.WithValue(ctx, apiKeyIDKey{}, record.ID)
+			if logger := GetLoggerFromContext(ctx); logger != nil {
+				ctx = WithLogger(ctx, logger.With(
+					slog.String("tenant_id", record.TenantID),
+					slog.String("api_key_id", record.ID),
+				))
+			}
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", record.TenantID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// APIKeyFromQuery passes an API key given as the api_key query parameter
+// on to RequireAPIKey as X-API-Key, for beacons and tracking pixels, which
+// cannot set headers. A key sent in a header takes precedence.
+func APIKeyFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get("api_key"); key != "" && r.Header.Get("X-API-Key") == "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StaticTenant scopes every request to tenantID, for deployments without
+// API key authentication.
+func StaticTenant(tenantID string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenantID)))
+		})
+	}
+}
+
+// GetTenantFromContext returns the tenant a request is scoped to, or ""
+// outside RequireAPIKey and StaticTenant.
+func GetTenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
+
+// GetAPIKeyIDFromContext returns the ID of the API key a request was
+// authenticated with, or "" outside RequireAPIKey.
+func GetAPIKeyIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(apiKeyIDKey{}).(string)
+	return id
+}
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
@@ -0,0 +1,176 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+type mockAuthenticator struct {
+	AuthenticateFunc func(ctx context.Context, key string) (storage.APIKey, error)
+}
+
+func (m *mockAuthenticator) Authenticate(ctx context.Context, key string) (storage.APIKey, error) {
+	return m.AuthenticateFunc(ctx, key)
+}
+
+// scopeRecorder records the tenant and API key ID a request reached it with.
+type scopeRecorder struct {
+	called   bool
+	tenantID string
+	apiKeyID string
+}
+
+func (s *scopeRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.called = true
+	s.tenantID = appmiddleware.GetTenantFromContext(r.Context())
+	s.apiKeyID = appmiddleware.GetAPIKeyIDFromContext(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func withDiscardLogger(r *http.Request) *http.Request {
+	return r.WithContext(appmiddleware.WithLogger(r.Context(), slog.New(slog.NewTextHandler(io.Discard, nil))))
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	const validKey = "tt_valid"
+	auth := &mockAuthenticator{AuthenticateFunc: func(ctx context.Context, key string) (storage.APIKey, error) {
+		switch key {
+		case validKey:
+			return storage.APIKey{ID: "key-1", TenantID: "acme"}, nil
+		case "tt_unreachable":
+			return storage.APIKey{}, errors.New("connection refused")
+		default:
+			return storage.APIKey{}, fmt.Errorf("lookup %q: %w", key, apikey.ErrInvalidKey)
+		}
+	}}
+
+	tests := []struct {
+		name            string
+		header          http.Header
+		basicUser       string
+		expectedStatus  int
+		expectedTenant  string
+		wwwAuthenticate string
+		retryAfter      string
+	}{
+		{
+			name:           "X-API-Key header",
+			header:         http.Header{"X-Api-Key": {validKey}},
+			expectedStatus: http.StatusNoContent,
+			expectedTenant: "acme",
+		},
+		{
+			name:           "Bearer token",
+			header:         http.Header{"Authorization": {"Bearer " + validKey}},
+			expectedStatus: http.StatusNoContent,
+			expectedTenant: "acme",
+		},
+		{
+			name:           "Bearer token takes precedence over X-API-Key",
+			header:         http.Header{"Authorization": {"Bearer " + validKey}, "X-Api-Key": {"tt_other"}},
+			expectedStatus: http.StatusNoContent,
+			expectedTenant: "acme",
+		},
+		{
+			name:           "Basic auth username",
+			basicUser:      validKey,
+			expectedStatus: http.StatusNoContent,
+			expectedTenant: "acme",
+		},
+		{
+			name:            "Missing key",
+			expectedStatus:  http.StatusUnauthorized,
+			wwwAuthenticate: `Bearer realm="events"`,
+		},
+		{
+			name:            "Invalid key",
+			header:          http.Header{"X-Api-Key": {"tt_revoked"}},
+			expectedStatus:  http.StatusUnauthorized,
+			wwwAuthenticate: `Bearer realm="events"`,
+		},
+		{
+			name:           "Key store unreachable",
+			header:         http.Header{"X-Api-Key": {"tt_unreachable"}},
+			expectedStatus: http.StatusServiceUnavailable,
+			retryAfter:     "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/events", nil)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			if tt.basicUser != "" {
+				req.SetBasicAuth(tt.basicUser, "")
+			}
+			req = withDiscardLogger(req)
+			rr := httptest.NewRecorder()
+			next := &scopeRecorder{}
+
+			appmiddleware.RequireAPIKey(auth)(next).ServeHTTP(rr, req)
+
+			be.Equal(t, tt.expectedStatus, rr.Code)
+			be.Equal(t, tt.wwwAuthenticate, rr.Header().Get("WWW-Authenticate"))
+			be.Equal(t, tt.retryAfter, rr.Header().Get("Retry-After"))
+			be.Equal(t, tt.expectedStatus == http.StatusNoContent, next.called)
+			if next.called {
+				be.Equal(t, tt.expectedTenant, next.tenantID)
+				be.Equal(t, "key-1", next.apiKeyID)
+			}
+		})
+	}
+}
+
+func TestAPIKeyFromQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		header   http.Header
+		expected string
+	}{
+		{name: "Query parameter", target: "/p.gif?api_key=tt_query", expected: "tt_query"},
+		{name: "Header takes precedence", target: "/p.gif?api_key=tt_query", header: http.Header{"X-Api-Key": {"tt_header"}}, expected: "tt_header"},
+		{name: "Authorization takes precedence", target: "/p.gif?api_key=tt_query", header: http.Header{"Authorization": {"Bearer tt_bearer"}}, expected: ""},
+		{name: "No key", target: "/p.gif", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			var got string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("X-API-Key")
+			})
+			appmiddleware.APIKeyFromQuery(next).ServeHTTP(httptest.NewRecorder(), req)
+			be.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestStaticTenant(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/events", nil)
+	rr := httptest.NewRecorder()
+	next := &scopeRecorder{}
+
+	appmiddleware.StaticTenant("default")(next).ServeHTTP(rr, req)
+
+	be.Equal(t, http.StatusNoContent, rr.Code)
+	be.Equal(t, "default", next.tenantID)
+	be.Equal(t, "", next.apiKeyID)
+}
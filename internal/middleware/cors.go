@@ -0,0 +1,34 @@
+package middleware
+
+import "net/http"
+
+// CORS lets pages on the given origins call the routes it wraps from the
+// browser; "*" allows any origin. Requests from other origins are served
+// without CORS headers, so the browser withholds the response.
+func CORS(origins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" && (allowed["*"] || allowed[origin]) {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Expose-Headers", "Retry-After")
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					h.Set("Access-Control-Allow-Methods", "GET, POST")
+					h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, X-API-Key")
+					h.Set("Access-Control-Max-Age", "86400")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Preflight answers CORS preflight requests; CORS adds the headers.
+func Preflight(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
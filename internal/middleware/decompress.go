@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// zstdMaxWindow caps the window a zstd body may declare, which the decoder
+// allocates up front. RFC 8878 asks decoders to support at least 8 MB.
+const zstdMaxWindow = 8 << 20
+
+// DecompressLimits bounds the request bodies Decompress inflates, so that
+// a small compressed body cannot expand into an unbounded one. A zero size
+// limit leaves that size unbounded, as for streams, which the ratio alone
+// then protects.
+type DecompressLimits struct {
+	MaxCompressedBytes   int64 // Largest body accepted as sent, or 0
+	MaxDecompressedBytes int64 // Largest body after decompression, or 0
+	MaxRatio             int64 // Largest decompressed size per compressed byte read
+}
+
+// Decompress decodes request bodies sent with a gzip or zstd
+// Content-Encoding, so that handlers read them as if they had been sent
+// uncompressed. It must run after RequireSignature, as signatures cover
+// the body as sent. Other encodings get 415.
+//
+// Bodies are inflated as they are read. Once one exceeds a limit, reads
+// fail with an *http.MaxBytesError, which handlers answer with 413 as they
+// do for oversized uncompressed bodies.
+func Decompress(limits DecompressLimits, m *metrics.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sent := r.Body
+			if limits.MaxCompressedBytes > 0 {
+				sent = http.MaxBytesReader(w, sent, limits.MaxCompressedBytes)
+			}
+			compressed := &countingReader{r: sent}
+			var (
+				decoder io.ReadCloser
+				err     error
+			)
+			switch encoding {
+			case "gzip", "x-gzip":
+				decoder, err = gzip.NewReader(compressed)
+			case "zstd":
+				var d *zstd.Decoder
+				d, err = zstd.NewReader(compressed,
+					zstd.WithDecoderConcurrency(1),
+					zstd.WithDecoderMaxWindow(zstdMaxWindow),
+				)
+				if err == nil {
+					decoder = d.IOReadCloser()
+				}
+			default:
+				w.Header().Set("Accept-Encoding", "gzip, zstd")
+				http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				status := http.StatusBadRequest
+				if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			defer decoder.Close()
+
+			body := &inflatingReader{r: decoder, compressed: compressed, limits: limits}
+			r.Body = body
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+
+			attrs := metric.WithAttributes(attribute.String("content_encoding", encoding))
+			m.RequestCompressedBytes.Add(r.Context(), compressed.n, attrs)
+			m.RequestDecompressedBytes.Add(r.Context(), body.n, attrs)
+			if body.err != nil {
+				if logger := GetLoggerFromContext(r.Context()); logger != nil {
+					logger.Warn("Compressed request body exceeded limits",
+						slog.String("content_encoding", encoding),
+						slog.Int64("compressed_bytes", compressed.n),
+						slog.Any("error", body.err),
+					)
+				}
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// inflatingReader reads a decompressed body, failing once it exceeds the
+// decompressed size limit or the expansion ratio over the compressed bytes
+// read so far.
+type inflatingReader struct {
+	r          io.Reader
+	compressed *countingReader
+	limits     DecompressLimits
+	n          int64
+	err        error // The limit exceeded, if any
+}
+
+func (b *inflatingReader) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	switch {
+	case b.limits.MaxDecompressedBytes > 0 && b.n > b.limits.MaxDecompressedBytes:
+		b.err = &http.MaxBytesError{Limit: b.limits.MaxDecompressedBytes}
+	case b.n > b.limits.MaxRatio*b.compressed.n:
+		b.err = &http.MaxBytesError{Limit: b.limits.MaxRatio * b.compressed.n}
+	default:
+		return n, err
+	}
+	return 0, b.err
+}
+
+// Close does nothing; Decompress closes the decoder once the handler
+// returns.
+func (b *inflatingReader) Close() error {
+	return nil
+}
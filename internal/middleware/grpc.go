@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/signing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCCheck is the gRPC counterpart of an HTTP middleware. It runs before
+// a call's handler and returns the context to handle the call with, or a
+// status error to reject it.
+type GRPCCheck func(ctx context.Context) (context.Context, error)
+
+// UnaryServerInterceptor injects a request logger into the context of each
+// unary call, runs checks in order, and logs the outcome of the call.
+func UnaryServerInterceptor(logger *slog.Logger, checks ...GRPCCheck) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = withRPCLogger(ctx, logger, info.FullMethod)
+		ctx, err := runChecks(ctx, checks)
+		var resp any
+		if err == nil {
+			resp, err = handler(ctx, req)
+		}
+		logRPC(ctx, err, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor(logger *slog.Logger, checks ...GRPCCheck) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withRPCLogger(ss.Context(), logger, info.FullMethod)
+		ctx, err := runChecks(ctx, checks)
+		if err == nil {
+			err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		}
+		logRPC(ctx, err, time.Since(start))
+		return err
+	}
+}
+
+// GRPCAPIKey is RequireAPIKey for gRPC: the key is read from the
+// "authorization" (as "Bearer <key>") or "x-api-key" metadata.
+func GRPCAPIKey(auth Authenticator) GRPCCheck {
+	return func(ctx context.Context) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var key string
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			key = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			if bearer, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+				key = bearer
+			}
+		}
+		record, err := auth.Authenticate(ctx, key)
+		if err != nil {
+			if !errors.Is(err, apikey.ErrInvalidKey) {
+				// The key store is unreachable; the key may well be valid.
+				if logger := GetLoggerFromContext(ctx); logger != nil {
+					logger.Error("Failed to authenticate API key", slog.Any("error", err))
+				}
+				return ctx, status.Error(codes.Unavailable, "unable to authenticate API key")
+			}
+			return ctx, status.Error(codes.Unauthenticated, "missing or invalid API key")
+		}
+
+		ctx = WithTenant(ctx, record.TenantID)
+		ctx = context.WithValue(ctx, apiKeyIDKey{}, record.ID)
+		if logger := GetLoggerFromContext(ctx); logger != nil {
+			ctx = WithLogger(ctx, logger.With(
+				slog.String("tenant_id", record.TenantID),
+				slog.String("api_key_id", record.ID),
+			))
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", record.TenantID))
+		return ctx, nil
+	}
+}
+
+// GRPCStaticTenant is StaticTenant for gRPC.
+func GRPCStaticTenant(tenantID string) GRPCCheck {
+	return func(ctx context.Context) (context.Context, error) {
+		return WithTenant(ctx, tenantID), nil
+	}
+}
+
+// GRPCSignature rejects calls from tenants with signing secrets. A
+// signature covers the raw HTTP body, which gRPC has no equivalent of, so
+// those tenants must ingest over HTTP.
+func GRPCSignature(v SignatureVerifier) GRPCCheck {
+	return func(ctx context.Context) (context.Context, error) {
+		err := v.Verify(ctx, GetTenantFromContext(ctx), "", "", nil)
+		switch {
+		case err == nil:
+			return ctx, nil
+		case errors.Is(err, signing.ErrMissingSignature):
+			return ctx, status.Error(codes.PermissionDenied, "tenant requires signed requests, which gRPC ingestion does not support")
+		default:
+			if logger := GetLoggerFromContext(ctx); logger != nil {
+				logger.Error("Failed to load signing secrets", slog.Any("error", err))
+			}
+			return ctx, status.Error(codes.Unavailable, "unable to verify signature")
+		}
+	}
+}
+
+// GRPCRateLimit is RateLimit for gRPC. Each call, not each streamed
+// message, takes one token; rejected calls fail with ResourceExhausted and
+// a "retry-after" header in seconds.
+func GRPCRateLimit(limiter *ratelimit.Limiter, tiers ratelimit.Tiers, by ratelimit.KeyBy, m *metrics.Registry) GRPCCheck {
+	return func(ctx context.Context) (context.Context, error) {
+		tenantID := GetTenantFromContext(ctx)
+		rate, ok := tiers.Rate(tenantID)
+		if !ok {
+			return ctx, nil
+		}
+		var ip string
+		if p, ok := peer.FromContext(ctx); ok {
+			ip = clientIP(p.Addr.String())
+		}
+		if d := limiter.Allow(bucketKey(ctx, by, ip), rate); !d.Allowed {
+			m.RateLimitedTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("limit", "rate"),
+				attribute.String("tier", tiers.Tier(tenantID)),
+			))
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", ceilSeconds(d.RetryAfter)))
+			return ctx, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return ctx, nil
+	}
+}
+
+// GRPCQuota is EnforceQuota for gRPC. A streaming call is checked again
+// for every message it receives, so it cannot outlast the tenant's quota.
+func GRPCQuota(quota *ratelimit.Quota, tiers ratelimit.Tiers, m *metrics.Registry) GRPCCheck {
+	check := func(ctx context.Context) error {
+		tenantID := GetTenantFromContext(ctx)
+		if ok, reset := quota.Check(tenantID); !ok {
+			m.RateLimitedTotal.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("limit", "quota"),
+				attribute.String("tier", tiers.Tier(tenantID)),
+			))
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", ceilSeconds(reset)))
+			return status.Error(codes.ResourceExhausted, "daily event quota exceeded")
+		}
+		return nil
+	}
+	return func(ctx context.Context) (context.Context, error) {
+		if err := check(ctx); err != nil {
+			return ctx, err
+		}
+		return withMessageCheck(ctx, check), nil
+	}
+}
+
+// messageChecksKey holds the checks a stream runs on every received message.
+type messageChecksKey struct{}
+
+// withMessageCheck makes streaming calls run check on every message they
+// receive. Unary calls ignore it.
+func withMessageCheck(ctx context.Context, check func(context.Context) error) context.Context {
+	checks, _ := ctx.Value(messageChecksKey{}).([]func(context.Context) error)
+	return context.WithValue(ctx, messageChecksKey{}, append(slices.Clip(checks), check))
+}
+
+func runChecks(ctx context.Context, checks []GRPCCheck) (context.Context, error) {
+	for _, check := range checks {
+		var err error
+		if ctx, err = check(ctx); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// withRPCLogger binds logger to the call's span, as RequestTelemetry and
+// TracingMiddleware do for HTTP requests.
+func withRPCLogger(ctx context.Context, logger *slog.Logger, method string) context.Context {
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	return WithLogger(ctx, observability.ContextLogger(ctx, logger).With(
+		slog.String("rpc_method", method),
+		slog.String("remote_addr", remoteAddr),
+	))
+}
+
+func logRPC(ctx context.Context, err error, duration time.Duration) {
+	code := status.Code(err)
+	logger := GetLoggerFromContext(ctx)
+	logFn := logger.Info
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+		logFn = logger.Error
+	default:
+		logFn = logger.Warn
+	}
+	logFn("RPC completed",
+		slog.String("code", code.String()),
+		slog.Duration("duration", duration),
+	)
+}
+
+// contextStream overrides the context of a server stream with the one the
+// checks returned.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// RecvMsg receives a message and fails the call if a message check rejects
+// it.
+func (s *contextStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	checks, _ := s.ctx.Value(messageChecksKey{}).([]func(context.Context) error)
+	for _, check := range checks {
+		if err := check(s.ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
@@ -22,7 +23,9 @@ func RequestTelemetry(logger *slog.Logger, m *metrics.Registry) func(http.Handle
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			requestID := middleware.GetReqID(r.Context())
-			reqLogger := logger.With(
+			// Bind to the server span so every record logged for this
+			// request is correlated with its trace.
+			reqLogger := observability.ContextLogger(r.Context(), logger).With(
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
 				slog.String("remote_addr", r.RemoteAddr),
@@ -61,12 +64,16 @@ func RequestTelemetry(logger *slog.Logger, m *metrics.Registry) func(http.Handle
 	}
 }
 
-// TracingMiddleware starts a span for each request using the observability tracer.
+// TracingMiddleware starts a span for each request using the observability tracer
+// and rebinds the request logger to it.
 func TracingMiddleware(tracer trace.Tracer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx, span := tracer.Start(r.Context(), "HTTP "+r.Method+" "+r.URL.Path)
 			defer span.End()
+			if logger := GetLoggerFromContext(ctx); logger != nil {
+				ctx = WithLogger(ctx, observability.ContextLogger(ctx, logger))
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -89,6 +96,11 @@ func (rr *responseRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
 func GetLoggerFromContext(ctx context.Context) *slog.Logger {
 	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
 		return logger
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RateLimit throttles requests with a token bucket per key, sized by the
+// tier of the request's tenant, and answers 429 once it is empty.
+// Responses in a tier with a rate carry RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers. It must run after the
+// tenant is resolved and, for ByIP, after chimid.RealIP.
+func RateLimit(limiter *ratelimit.Limiter, tiers ratelimit.Tiers, by ratelimit.KeyBy, m *metrics.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := GetTenantFromContext(r.Context())
+			rate, ok := tiers.Rate(tenantID)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d := limiter.Allow(bucketKey(r.Context(), by, clientIP(r.RemoteAddr)), rate)
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(d.Reset))
+			if !d.Allowed {
+				m.RateLimitedTotal.Add(r.Context(), 1, metric.WithAttributes(
+					attribute.String("limit", "rate"),
+					attribute.String("tier", tiers.Tier(tenantID)),
+				))
+				h.Set("Retry-After", ceilSeconds(d.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// EnforceQuota answers 429 once the request's tenant has used its daily
+// event quota, with Retry-After pointing at the next UTC midnight.
+func EnforceQuota(quota *ratelimit.Quota, tiers ratelimit.Tiers, m *metrics.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID := GetTenantFromContext(r.Context())
+			if ok, reset := quota.Check(tenantID); !ok {
+				m.RateLimitedTotal.Add(r.Context(), 1, metric.WithAttributes(
+					attribute.String("limit", "quota"),
+					attribute.String("tier", tiers.Tier(tenantID)),
+				))
+				w.Header().Set("Retry-After", ceilSeconds(reset))
+				http.Error(w, "Daily event quota exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bucketKey names the token bucket a request draws from.
+func bucketKey(ctx context.Context, by ratelimit.KeyBy, ip string) string {
+	key := "tenant:" + GetTenantFromContext(ctx)
+	switch by {
+	case ratelimit.ByIP:
+		key += "/ip:" + ip
+	case ratelimit.ByAPIKey:
+		if id := GetAPIKeyIDFromContext(ctx); id != "" {
+			key = "key:" + id
+		}
+	}
+	return key
+}
+
+// clientIP strips the port that a remote address carries unless
+// chimid.RealIP replaced it with a forwarded address.
+func clientIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
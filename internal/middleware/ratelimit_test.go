@@ -0,0 +1,169 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/ratelimit"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func newTestMetrics(t *testing.T) (observability.Provider, *metrics.Registry) {
+	t.Helper()
+	obs, err := observability.InitObservability("noop")
+	be.NilErr(t, err)
+	reg, err := metrics.NewRegistry(obs.Meter())
+	be.NilErr(t, err)
+	return obs, reg
+}
+
+// rateLimitRequest is a request from a client identified by its API key
+// and address.
+type rateLimitRequest struct {
+	key            string
+	remoteAddr     string
+	expectedStatus int
+}
+
+func TestRateLimit(t *testing.T) {
+	// Keys key-1 and key-2 both belong to acme; key-3 belongs to globex,
+	// whose tier has no rate.
+	keys := map[string]storage.APIKey{
+		"tt_key1": {ID: "key-1", TenantID: "acme"},
+		"tt_key2": {ID: "key-2", TenantID: "acme"},
+		"tt_key3": {ID: "key-3", TenantID: "globex"},
+	}
+	auth := &mockAuthenticator{AuthenticateFunc: func(ctx context.Context, key string) (storage.APIKey, error) {
+		if record, ok := keys[key]; ok {
+			return record, nil
+		}
+		return storage.APIKey{}, apikey.ErrInvalidKey
+	}}
+	// One request per hour, so a bucket never refills during a test.
+	tiers, err := ratelimit.ParseTiers("pro=1/h", "", "acme=pro")
+	be.NilErr(t, err)
+	_, reg := newTestMetrics(t)
+
+	tests := []struct {
+		name     string
+		by       ratelimit.KeyBy
+		requests []rateLimitRequest
+	}{
+		{
+			name: "By tenant",
+			by:   ratelimit.ByTenant,
+			requests: []rateLimitRequest{
+				{"tt_key1", "192.0.2.1:1234", http.StatusNoContent},
+				{"tt_key2", "192.0.2.2:1234", http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "By IP",
+			by:   ratelimit.ByIP,
+			requests: []rateLimitRequest{
+				{"tt_key1", "192.0.2.1:1234", http.StatusNoContent},
+				{"tt_key1", "192.0.2.2:1234", http.StatusNoContent},
+				{"tt_key2", "192.0.2.1:5678", http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "By API key",
+			by:   ratelimit.ByAPIKey,
+			requests: []rateLimitRequest{
+				{"tt_key1", "192.0.2.1:1234", http.StatusNoContent},
+				{"tt_key2", "192.0.2.1:1234", http.StatusNoContent},
+				{"tt_key1", "192.0.2.2:1234", http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "Tier without a rate",
+			by:   ratelimit.ByTenant,
+			requests: []rateLimitRequest{
+				{"tt_key3", "192.0.2.1:1234", http.StatusNoContent},
+				{"tt_key3", "192.0.2.1:1234", http.StatusNoContent},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := appmiddleware.RequireAPIKey(auth)(
+				appmiddleware.RateLimit(ratelimit.NewLimiter(), tiers, tt.by, reg)(&scopeRecorder{}))
+
+			for _, r := range tt.requests {
+				req := httptest.NewRequest(http.MethodPost, "/events", nil)
+				req.Header.Set("X-API-Key", r.key)
+				req.RemoteAddr = r.remoteAddr
+				rr := httptest.NewRecorder()
+
+				handler.ServeHTTP(rr, req)
+
+				be.Equal(t, r.expectedStatus, rr.Code)
+				if r.key == "tt_key3" {
+					be.Equal(t, "", rr.Header().Get("RateLimit-Limit"))
+					continue
+				}
+				be.Equal(t, "1", rr.Header().Get("RateLimit-Limit"))
+				be.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+				be.Equal(t, "3600", rr.Header().Get("RateLimit-Reset"))
+				if r.expectedStatus == http.StatusTooManyRequests {
+					be.Equal(t, "3600", rr.Header().Get("Retry-After"))
+				} else {
+					be.Equal(t, "", rr.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestEnforceQuota(t *testing.T) {
+	tiers, err := ratelimit.ParseTiers("", "default=2", "globex=unlimited")
+	be.NilErr(t, err)
+	obs, reg := newTestMetrics(t)
+	quota := ratelimit.NewQuota(storage.NewMemoryStore(), tiers, time.Minute, obs)
+	quota.Add("acme", 2)
+	quota.Add("globex", 100)
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		expectedStatus int
+	}{
+		{name: "Tenant over quota", tenantID: "acme", expectedStatus: http.StatusTooManyRequests},
+		{name: "Tenant under quota", tenantID: "initech", expectedStatus: http.StatusNoContent},
+		{name: "Tier without a quota", tenantID: "globex", expectedStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/events", nil)
+			rr := httptest.NewRecorder()
+			handler := appmiddleware.StaticTenant(tt.tenantID)(
+				appmiddleware.EnforceQuota(quota, tiers, reg)(&scopeRecorder{}))
+
+			handler.ServeHTTP(rr, req)
+
+			be.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusTooManyRequests {
+				be.Equal(t, "", rr.Header().Get("Retry-After"))
+				return
+			}
+			be.True(t, strings.Contains(rr.Body.String(), "Daily event quota exceeded"))
+			// Retry-After points at the next UTC midnight.
+			retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+			be.NilErr(t, err)
+			untilMidnight := time.Until(time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour))
+			be.True(t, retryAfter >= 1 && retryAfter <= int(untilMidnight.Seconds())+1)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/kakhavain/telemetry-tracker/internal/signing"
+)
+
+// maxSignedBodyBytes caps the body buffered to verify its signature. It
+// matches the batch endpoint's limit; signed streams are held to it too.
+const maxSignedBodyBytes = 5 << 20
+
+// SignatureVerifier checks a request body against its signature headers.
+type SignatureVerifier interface {
+	Verify(ctx context.Context, tenantID, timestamp, signature string, body []byte) error
+}
+
+// RequireSignature rejects requests from tenants with signing secrets
+// unless they carry a valid HMAC signature of their body. It must run after
+// the tenant is resolved and before the body is read. Signed bodies are
+// buffered in full, so a signed NDJSON stream is limited in size.
+func RequireSignature(v SignatureVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timestamp := r.Header.Get(signing.TimestampHeader)
+			signature := r.Header.Get(signing.SignatureHeader)
+			var body []byte
+			if signature != "" {
+				var err error
+				body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+				if err != nil {
+					status := http.StatusBadRequest
+					if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+						status = http.StatusRequestEntityTooLarge
+					}
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			err := v.Verify(r.Context(), GetTenantFromContext(r.Context()), timestamp, signature, body)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case errors.Is(err, signing.ErrMissingSignature),
+				errors.Is(err, signing.ErrInvalidSignature),
+				errors.Is(err, signing.ErrStaleTimestamp):
+				if logger := GetLoggerFromContext(r.Context()); logger != nil {
+					logger.Warn("Rejected request signature", slog.Any("error", err))
+				}
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			default:
+				if logger := GetLoggerFromContext(r.Context()); logger != nil {
+					logger.Error("Failed to verify request signature", slog.Any("error", err))
+				}
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
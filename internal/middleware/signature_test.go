@@ -0,0 +1,152 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	appmiddleware "github.com/kakhavain/telemetry-tracker/internal/middleware"
+	"github.com/kakhavain/telemetry-tracker/internal/signing"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+// failingSecrets fails every secret lookup, as an unreachable store would.
+type failingSecrets struct {
+	storage.SecretStore
+}
+
+func (failingSecrets) ListSigningSecrets(ctx context.Context, tenantID string) ([]storage.SigningSecret, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestRequireSignature(t *testing.T) {
+	ctx := context.Background()
+	manager := signing.NewManager(storage.NewMemoryStore(), time.Minute, 5*time.Minute)
+	secret, err := manager.Create(ctx, "acme", "test")
+	be.NilErr(t, err)
+
+	body := []byte(`{"event_type": "login"}`)
+	now := time.Now().Unix()
+	large := bytes.Repeat([]byte(" "), 5<<20+1)
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		verifier       appmiddleware.SignatureVerifier
+		body           []byte
+		timestamp      string
+		signature      string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid signature",
+			tenantID:       "acme",
+			body:           body,
+			timestamp:      strconv.FormatInt(now, 10),
+			signature:      signing.Sign(secret.Secret, now, body),
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Tenant without secrets",
+			tenantID:       "globex",
+			body:           body,
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Missing signature",
+			tenantID:       "acme",
+			body:           body,
+			timestamp:      strconv.FormatInt(now, 10),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Signature of another body",
+			tenantID:       "acme",
+			body:           body,
+			timestamp:      strconv.FormatInt(now, 10),
+			signature:      signing.Sign(secret.Secret, now, []byte(`{"event_type": "logout"}`)),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Malformed signature",
+			tenantID:       "acme",
+			body:           body,
+			timestamp:      strconv.FormatInt(now, 10),
+			signature:      "v1=not-hex",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Timestamp outside tolerance",
+			tenantID:       "acme",
+			body:           body,
+			timestamp:      strconv.FormatInt(now-600, 10),
+			signature:      signing.Sign(secret.Secret, now-600, body),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Body over the buffer limit",
+			tenantID:       "acme",
+			body:           large,
+			timestamp:      strconv.FormatInt(now, 10),
+			signature:      signing.Sign(secret.Secret, now, large),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Secret store unreachable",
+			tenantID:       "acme",
+			verifier:       signing.NewManager(failingSecrets{}, time.Minute, 5*time.Minute),
+			body:           body,
+			timestamp:      strconv.FormatInt(now, 10),
+			signature:      signing.Sign(secret.Secret, now, body),
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := tt.verifier
+			if verifier == nil {
+				verifier = manager
+			}
+			req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(tt.body))
+			if tt.timestamp != "" {
+				req.Header.Set(signing.TimestampHeader, tt.timestamp)
+			}
+			if tt.signature != "" {
+				req.Header.Set(signing.SignatureHeader, tt.signature)
+			}
+			req = withDiscardLogger(req)
+			rr := httptest.NewRecorder()
+
+			var received []byte
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				received, err = io.ReadAll(r.Body)
+				be.NilErr(t, err)
+				w.WriteHeader(http.StatusAccepted)
+			})
+			handler := appmiddleware.StaticTenant(tt.tenantID)(appmiddleware.RequireSignature(verifier)(next))
+
+			handler.ServeHTTP(rr, req)
+
+			be.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusAccepted {
+				// The handler reads the whole body after verification.
+				be.Equal(t, string(tt.body), string(received))
+			} else {
+				be.Nonzero(t, rr.Body.Len())
+				be.Equal(t, 0, len(received))
+			}
+			if tt.expectedStatus == http.StatusServiceUnavailable {
+				be.Equal(t, "1", rr.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/carlmjohnson/be"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	be.NilErr(t, err)
+	be.Nonzero(t, len(migrations))
+	for i, m := range migrations {
+		// Versions are contiguous so that a gap (e.g. a lost file) is caught in review.
+		be.Equal(t, int64(i+1), m.Version)
+		be.Nonzero(t, m.Up)
+		be.Nonzero(t, m.Down)
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Missing down",
+			files: fstest.MapFS{"migrations/0001_a.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "Conflicting names",
+			files: fstest.MapFS{
+				"migrations/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"migrations/0001_b.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name:  "Bad file name",
+			files: fstest.MapFS{"migrations/create_events.sql": {Data: []byte("SELECT 1;")}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(tc.files)
+			be.Nonzero(t, err)
+		})
+	}
+}
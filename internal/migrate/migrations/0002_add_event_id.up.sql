@@ -0,0 +1,5 @@
+-- Optional client-supplied UUID/ULID for idempotent retries
+ALTER TABLE events ADD COLUMN IF NOT EXISTS event_id VARCHAR(36);
+
+-- Client retries carrying the same event_id are stored once (NULLs are not deduplicated)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_event_id ON events(event_id);
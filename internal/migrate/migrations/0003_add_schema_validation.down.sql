@@ -0,0 +1,2 @@
+ALTER TABLE events DROP COLUMN IF EXISTS quarantined;
+ALTER TABLE events DROP COLUMN IF EXISTS schema_version;
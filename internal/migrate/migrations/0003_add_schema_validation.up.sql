@@ -0,0 +1,4 @@
+-- Schema version the data was validated against (NULL = latest/none)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS schema_version INTEGER;
+-- Event type had no schema under UNKNOWN_EVENT_POLICY=quarantine
+ALTER TABLE events ADD COLUMN IF NOT EXISTS quarantined BOOLEAN NOT NULL DEFAULT FALSE;
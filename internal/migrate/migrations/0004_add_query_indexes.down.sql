@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_data;
+DROP INDEX IF EXISTS idx_events_received_at;
+DROP INDEX IF EXISTS idx_events_event_type_timestamp_id;
+DROP INDEX IF EXISTS idx_events_timestamp_id;
@@ -0,0 +1,7 @@
+-- Keyset pagination for GET /events walks (timestamp, id) newest first; the same
+-- indexes serve the timestamp range scans behind GET /events/aggregate
+CREATE INDEX IF NOT EXISTS idx_events_timestamp_id ON events(timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_event_type_timestamp_id ON events(event_type, timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_received_at ON events(received_at);
+-- JSONB containment filters (data @> '{...}')
+CREATE INDEX IF NOT EXISTS idx_events_data ON events USING GIN (data jsonb_path_ops);
@@ -0,0 +1,33 @@
+ALTER TABLE events RENAME TO events_partitioned;
+DROP INDEX IF EXISTS idx_events_timestamp_id;
+DROP INDEX IF EXISTS idx_events_event_type_timestamp_id;
+DROP INDEX IF EXISTS idx_events_received_at;
+DROP INDEX IF EXISTS idx_events_data;
+DROP INDEX IF EXISTS idx_events_event_id;
+
+CREATE TABLE events (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    data JSONB,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    event_id VARCHAR(36),
+    schema_version INTEGER,
+    quarantined BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE UNIQUE INDEX idx_events_event_id ON events(event_id);
+CREATE INDEX idx_events_timestamp_id ON events(timestamp DESC, id DESC);
+CREATE INDEX idx_events_event_type_timestamp_id ON events(event_type, timestamp DESC, id DESC);
+CREATE INDEX idx_events_received_at ON events(received_at);
+CREATE INDEX idx_events_data ON events USING GIN (data jsonb_path_ops);
+
+-- Keep the first row for each event_id; the unique index would reject the rest.
+INSERT INTO events (id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at)
+SELECT DISTINCT ON (COALESCE(event_id, id::text)) id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at
+FROM events_partitioned
+ORDER BY COALESCE(event_id, id::text), id;
+
+SELECT setval(pg_get_serial_sequence('events', 'id'), COALESCE((SELECT max(id) FROM events), 0) + 1, false);
+
+DROP TABLE events_partitioned;
+DROP TABLE event_ids;
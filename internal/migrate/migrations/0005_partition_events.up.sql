@@ -0,0 +1,47 @@
+-- Move events to a table range-partitioned on timestamp. Partitions are
+-- created ahead of time by the server; rows outside every partition land in
+-- events_default and are moved out when a covering partition is created.
+ALTER TABLE events RENAME TO events_unpartitioned;
+DROP INDEX IF EXISTS idx_events_event_id;
+DROP INDEX IF EXISTS idx_events_timestamp_id;
+DROP INDEX IF EXISTS idx_events_event_type_timestamp_id;
+DROP INDEX IF EXISTS idx_events_received_at;
+DROP INDEX IF EXISTS idx_events_data;
+
+CREATE TABLE events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+    event_id VARCHAR(36),
+    event_type VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    data JSONB,
+    schema_version INTEGER,
+    quarantined BOOLEAN NOT NULL DEFAULT FALSE,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (timestamp, id)
+) PARTITION BY RANGE (timestamp);
+
+CREATE TABLE events_default PARTITION OF events DEFAULT;
+
+CREATE INDEX idx_events_timestamp_id ON events(timestamp DESC, id DESC);
+CREATE INDEX idx_events_event_type_timestamp_id ON events(event_type, timestamp DESC, id DESC);
+CREATE INDEX idx_events_received_at ON events(received_at);
+CREATE INDEX idx_events_data ON events USING GIN (data jsonb_path_ops);
+CREATE INDEX idx_events_event_id ON events(event_id) WHERE event_id IS NOT NULL;
+
+-- Unique indexes on a partitioned table must include the partition key, so
+-- event_id idempotency is enforced through this ledger instead.
+CREATE TABLE event_ids (
+    event_id VARCHAR(36) PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO event_ids (event_id)
+SELECT DISTINCT event_id FROM events_unpartitioned WHERE event_id IS NOT NULL;
+
+INSERT INTO events (id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at)
+SELECT id, event_id, event_type, COALESCE(timestamp, received_at, CURRENT_TIMESTAMP), data, schema_version, quarantined, received_at
+FROM events_unpartitioned;
+
+SELECT setval(pg_get_serial_sequence('events', 'id'), COALESCE((SELECT max(id) FROM events), 0) + 1, false);
+
+DROP TABLE events_unpartitioned;
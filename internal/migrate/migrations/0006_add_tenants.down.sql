@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS api_keys;
+
+DROP INDEX IF EXISTS idx_events_tenant_event_type_timestamp_id;
+DROP INDEX IF EXISTS idx_events_tenant_timestamp_id;
+CREATE INDEX IF NOT EXISTS idx_events_event_type_timestamp_id ON events(event_type, timestamp DESC, id DESC);
+
+-- Keep one ledger entry per event_id; the global primary key would reject the rest.
+DELETE FROM event_ids a USING event_ids b
+WHERE a.event_id = b.event_id AND a.tenant_id > b.tenant_id;
+ALTER TABLE event_ids DROP CONSTRAINT event_ids_pkey;
+ALTER TABLE event_ids ADD PRIMARY KEY (event_id);
+ALTER TABLE event_ids DROP COLUMN tenant_id;
+
+ALTER TABLE events DROP COLUMN tenant_id;
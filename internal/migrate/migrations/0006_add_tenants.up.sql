@@ -0,0 +1,27 @@
+-- Events belong to the tenant whose API key submitted them; rows stored
+-- before authentication existed belong to the default tenant.
+ALTER TABLE events ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- Event IDs are deduplicated per tenant.
+ALTER TABLE event_ids ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE event_ids DROP CONSTRAINT event_ids_pkey;
+ALTER TABLE event_ids ADD PRIMARY KEY (tenant_id, event_id);
+
+-- Every read is scoped to a tenant. idx_events_timestamp_id stays for
+-- retention, which purges across tenants.
+DROP INDEX IF EXISTS idx_events_event_type_timestamp_id;
+CREATE INDEX idx_events_tenant_timestamp_id ON events(tenant_id, timestamp DESC, id DESC);
+CREATE INDEX idx_events_tenant_event_type_timestamp_id ON events(tenant_id, event_type, timestamp DESC, id DESC);
+
+-- API keys are stored as SHA-256 hashes; prefix identifies a key to operators.
+CREATE TABLE api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    prefix VARCHAR(16) NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX idx_api_keys_tenant_id ON api_keys(tenant_id);
@@ -0,0 +1,8 @@
+-- Events accepted per tenant per UTC day, for daily quotas. Each instance
+-- adds the counts it accepted since its last flush.
+CREATE TABLE event_usage (
+    tenant_id VARCHAR(64) NOT NULL,
+    day DATE NOT NULL,
+    events BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, day)
+);
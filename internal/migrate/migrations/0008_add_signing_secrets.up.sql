@@ -0,0 +1,13 @@
+-- HMAC signing secrets for server-side producers. Secrets are stored as
+-- is because verifying a signature needs them; a tenant may have several
+-- active at once while producers rotate.
+CREATE TABLE signing_secrets (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX idx_signing_secrets_tenant_id ON signing_secrets(tenant_id);
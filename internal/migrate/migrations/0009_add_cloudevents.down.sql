@@ -0,0 +1,3 @@
+ALTER TABLE events DROP COLUMN IF EXISTS subject;
+ALTER TABLE events DROP COLUMN IF EXISTS source_event_id;
+ALTER TABLE events DROP COLUMN IF EXISTS source;
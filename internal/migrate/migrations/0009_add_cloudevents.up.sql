@@ -0,0 +1,5 @@
+-- CloudEvents context attributes of events ingested as CloudEvents.
+-- source_event_id is the CloudEvents id, unique only within its source.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS source TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS source_event_id TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS subject TEXT;
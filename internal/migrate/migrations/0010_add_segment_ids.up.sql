@@ -0,0 +1,3 @@
+-- Segment identities of events ingested through the Segment-compatible API.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS user_id TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS anonymous_id TEXT;
@@ -0,0 +1,269 @@
+package observability
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP transport protocols, named as in OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// exportTarget is the collector address and transport settings shared by
+// the trace, metric and log exporters. Zero values leave the exporter's own
+// default (and its OTEL_EXPORTER_OTLP_* handling) in place.
+type exportTarget struct {
+	protocol string
+	endpoint string // host:port
+	urlPath  string // path prefix for OTLP/HTTP, without the /v1/<signal> suffix
+	hasURL   bool   // endpoint was given as a URL, so urlPath applies
+	insecure bool
+	tls      *tls.Config
+	headers  map[string]string
+}
+
+func newExportTarget(cfg Config) (exportTarget, error) {
+	t := exportTarget{protocol: cfg.Protocol, insecure: cfg.Insecure}
+	switch cfg.Protocol {
+	case "":
+		t.protocol = ProtocolHTTP
+	case ProtocolHTTP, ProtocolGRPC:
+	default:
+		return exportTarget{}, fmt.Errorf("unsupported OTLP protocol: %s", cfg.Protocol)
+	}
+
+	// The endpoint may be a URL, as in OTEL_EXPORTER_OTLP_ENDPOINT, whose
+	// scheme decides between plaintext and TLS, or a bare host:port.
+	if strings.Contains(cfg.Endpoint, "://") {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return exportTarget{}, fmt.Errorf("invalid OTLP endpoint: %w", err)
+		}
+		switch u.Scheme {
+		case "http":
+			t.insecure = true
+		case "https":
+			t.insecure = false
+		default:
+			return exportTarget{}, fmt.Errorf("invalid OTLP endpoint: unsupported scheme %q", u.Scheme)
+		}
+		t.endpoint = u.Host
+		t.urlPath = strings.TrimSuffix(u.Path, "/")
+		t.hasURL = true
+	} else {
+		t.endpoint = cfg.Endpoint
+	}
+
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return exportTarget{}, err
+	}
+	t.headers = headers
+
+	if t.tls, err = loadTLSConfig(cfg.Certificate, cfg.ClientCertificate, cfg.ClientKey); err != nil {
+		return exportTarget{}, err
+	}
+	return t, nil
+}
+
+// parseHeaders parses OTEL_EXPORTER_OTLP_HEADERS: comma-separated key=value
+// pairs with URL-encoded values.
+func parseHeaders(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTLP header %q: expected key=value", strings.TrimSpace(pair))
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header %q: %w", key, err)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// loadTLSConfig builds the client TLS settings from a CA bundle and an
+// optional client certificate for mutual TLS. It returns nil when none are
+// configured, so the system roots are used.
+func loadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read OTLP certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("OTLP client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load OTLP client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// newResource describes this process to the collector. OTEL_SERVICE_NAME
+// and OTEL_RESOURCE_ATTRIBUTES take precedence over the configured values.
+func newResource(ctx context.Context, cfg Config) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	if cfg.ServiceName != "" {
+		attrs = append(attrs, semconv.ServiceName(cfg.ServiceName))
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Environment))
+	}
+	return resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+	)
+}
+
+func (t exportTarget) traceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	if t.protocol == ProtocolGRPC {
+		var opts []otlptracegrpc.Option
+		if t.endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(t.endpoint))
+		}
+		if t.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else if t.tls != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(t.tls)))
+		}
+		if t.headers != nil {
+			opts = append(opts, otlptracegrpc.WithHeaders(t.headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+
+	var opts []otlptracehttp.Option
+	if t.endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(t.endpoint))
+	}
+	if t.hasURL {
+		opts = append(opts, otlptracehttp.WithURLPath(t.urlPath+"/v1/traces"))
+	}
+	if t.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if t.tls != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(t.tls))
+	}
+	if t.headers != nil {
+		opts = append(opts, otlptracehttp.WithHeaders(t.headers))
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
+
+func (t exportTarget) metricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	if t.protocol == ProtocolGRPC {
+		var opts []otlpmetricgrpc.Option
+		if t.endpoint != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpoint(t.endpoint))
+		}
+		if t.insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		} else if t.tls != nil {
+			opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(t.tls)))
+		}
+		if t.headers != nil {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(t.headers))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+
+	var opts []otlpmetrichttp.Option
+	if t.endpoint != "" {
+		opts = append(opts, otlpmetrichttp.WithEndpoint(t.endpoint))
+	}
+	if t.hasURL {
+		opts = append(opts, otlpmetrichttp.WithURLPath(t.urlPath+"/v1/metrics"))
+	}
+	if t.insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else if t.tls != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(t.tls))
+	}
+	if t.headers != nil {
+		opts = append(opts, otlpmetrichttp.WithHeaders(t.headers))
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+func (t exportTarget) logExporter(ctx context.Context) (sdklog.Exporter, error) {
+	if t.protocol == ProtocolGRPC {
+		var opts []otlploggrpc.Option
+		if t.endpoint != "" {
+			opts = append(opts, otlploggrpc.WithEndpoint(t.endpoint))
+		}
+		if t.insecure {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		} else if t.tls != nil {
+			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(t.tls)))
+		}
+		if t.headers != nil {
+			opts = append(opts, otlploggrpc.WithHeaders(t.headers))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	}
+
+	var opts []otlploghttp.Option
+	if t.endpoint != "" {
+		opts = append(opts, otlploghttp.WithEndpoint(t.endpoint))
+	}
+	if t.hasURL {
+		opts = append(opts, otlploghttp.WithURLPath(t.urlPath+"/v1/logs"))
+	}
+	if t.insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	} else if t.tls != nil {
+		opts = append(opts, otlploghttp.WithTLSClientConfig(t.tls))
+	}
+	if t.headers != nil {
+		opts = append(opts, otlploghttp.WithHeaders(t.headers))
+	}
+	return otlploghttp.New(ctx, opts...)
+}
@@ -0,0 +1,71 @@
+package observability
+
+import (
+	"testing"
+
+	"github.com/carlmjohnson/be"
+)
+
+func TestNewExportTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want exportTarget
+	}{
+		{
+			name: "defaults",
+			cfg:  Config{},
+			want: exportTarget{protocol: ProtocolHTTP},
+		},
+		{
+			name: "http url",
+			cfg:  Config{Endpoint: "http://otel-collector:4318"},
+			want: exportTarget{protocol: ProtocolHTTP, endpoint: "otel-collector:4318", hasURL: true, insecure: true},
+		},
+		{
+			name: "https url with path",
+			cfg:  Config{Endpoint: "https://ingest.example.com/otlp/", Insecure: true},
+			want: exportTarget{protocol: ProtocolHTTP, endpoint: "ingest.example.com", urlPath: "/otlp", hasURL: true},
+		},
+		{
+			name: "grpc host and port",
+			cfg:  Config{Endpoint: "collector:4317", Protocol: ProtocolGRPC, Insecure: true},
+			want: exportTarget{protocol: ProtocolGRPC, endpoint: "collector:4317", insecure: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newExportTarget(tt.cfg)
+			be.NilErr(t, err)
+			be.Equal(t, tt.want.protocol, got.protocol)
+			be.Equal(t, tt.want.endpoint, got.endpoint)
+			be.Equal(t, tt.want.urlPath, got.urlPath)
+			be.Equal(t, tt.want.hasURL, got.hasURL)
+			be.Equal(t, tt.want.insecure, got.insecure)
+		})
+	}
+
+	for _, cfg := range []Config{
+		{Protocol: "http/json"},
+		{Endpoint: "ftp://collector:4318"},
+		{Headers: "no-equals-sign"},
+		{ClientCertificate: "client.pem"},
+		{Certificate: "/nonexistent/ca.pem"},
+	} {
+		_, err := newExportTarget(cfg)
+		be.Nonzero(t, err)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("api-key=secret, x-scope = tenant%20one ,empty=")
+	be.NilErr(t, err)
+	be.Equal(t, 3, len(headers))
+	be.Equal(t, "secret", headers["api-key"])
+	be.Equal(t, "tenant one", headers["x-scope"])
+	be.Equal(t, "", headers["empty"])
+
+	headers, err = parseHeaders("")
+	be.NilErr(t, err)
+	be.Equal(t, 0, len(headers))
+}
@@ -0,0 +1,56 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// exportErrors remembers the most recent error the OpenTelemetry SDK
+// reported, which is how failed exports surface.
+var exportErrors = errorRecorder{logger: slog.New(slog.NewJSONHandler(os.Stdout, nil))}
+
+type errorRecorder struct {
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	err  error
+	when time.Time
+}
+
+// Handle implements otel.ErrorHandler. The error is logged to stdout only;
+// routing it through the OpenTelemetry log pipeline would feed it back into
+// an exporter that may be the one failing.
+func (r *errorRecorder) Handle(err error) {
+	r.mu.Lock()
+	r.err, r.when = err, time.Now()
+	r.mu.Unlock()
+	r.logger.Warn("OpenTelemetry error", "error", err)
+}
+
+func (r *errorRecorder) last() (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.when, r.err
+}
+
+// ExporterHealth returns a check that fails while the OpenTelemetry SDK has
+// reported an error within window, e.g. because the collector is unreachable.
+func ExporterHealth(window time.Duration) func(context.Context) error {
+	return func(context.Context) error {
+		when, err := exportErrors.last()
+		if err == nil || time.Since(when) > window {
+			return nil
+		}
+		return fmt.Errorf("export failed %s ago: %w", time.Since(when).Round(time.Second), err)
+	}
+}
+
+func installErrorHandler() {
+	otel.SetErrorHandler(&exportErrors)
+}
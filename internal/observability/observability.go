@@ -6,14 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
@@ -30,6 +28,9 @@ type Provider interface {
 	Logger() *slog.Logger
 	Tracer() trace.Tracer
 	Meter() metric.Meter
+	// MetricsHandler serves the Prometheus scrape endpoint, or is nil when
+	// Prometheus exposition is disabled.
+	MetricsHandler() http.Handler
 	Shutdown(ctx context.Context) error
 }
 
@@ -37,6 +38,7 @@ type ObservabilityProvider struct {
 	logger   *slog.Logger
 	tracer   trace.Tracer
 	meter    metric.Meter
+	metrics  http.Handler
 	shutdown func(context.Context) error
 }
 
@@ -52,72 +54,137 @@ func (o *ObservabilityProvider) Meter() metric.Meter {
 	return o.meter
 }
 
+func (o *ObservabilityProvider) MetricsHandler() http.Handler {
+	return o.metrics
+}
+
 func (o *ObservabilityProvider) Shutdown(ctx context.Context) error {
 	return o.shutdown(ctx)
 }
 
 // buildRootLogger installs a JSON handler at the requested level,
-// makes it the slog process default, and returns it.
-func buildRootLogger(level slog.Leveler) *slog.Logger {
-	h := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+// makes it the slog process default, and returns it. With bridge set,
+// records are also emitted to the global OpenTelemetry LoggerProvider.
+func buildRootLogger(level slog.Leveler, bridge bool) *slog.Logger {
+	handlers := []slog.Handler{&traceAttrHandler{Handler: slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level:     level,
 		AddSource: true,
-	})
-	root := slog.New(h)
+	})}}
+	if bridge {
+		handlers = append(handlers, newOTelHandler(global.GetLoggerProvider().Logger(schemaName), level))
+	}
+	root := slog.New(newLogHandler(handlers...))
 	slog.SetDefault(root)
 	return root
 }
 
+// Config selects the observability mode and, in the otel mode, where and
+// how telemetry is exported. Empty exporter fields fall back to the OTLP
+// exporters' defaults and their OTEL_EXPORTER_OTLP_* environment variables.
+type Config struct {
+	Mode string // otel, debug, local or noop
+
+	Endpoint          string // OTLP collector URL (http:// or https://) or host:port
+	Protocol          string // grpc or http/protobuf (default)
+	Insecure          bool   // Use plaintext when Endpoint has no scheme
+	Certificate       string // CA bundle used to verify the collector
+	ClientCertificate string // Client certificate for mutual TLS
+	ClientKey         string // Client key for mutual TLS
+	Headers           string // key=value pairs sent with every export, comma-separated
+
+	Prometheus bool // Also expose metrics for scraping, in every mode
+
+	ServiceName    string
+	ServiceVersion string
+	Environment    string
+}
+
+// InitObservability initializes the given mode with default exporter settings.
 func InitObservability(mode string) (Provider, error) {
-	switch mode {
+	return New(Config{Mode: mode})
+}
+
+// New initializes logging, tracing and metrics for cfg.Mode.
+func New(cfg Config) (Provider, error) {
+	var (
+		promReader  sdkmetric.Reader
+		promHandler http.Handler
+	)
+	if cfg.Prometheus {
+		var err error
+		if promReader, promHandler, err = newPrometheusReader(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.Mode {
 
 	case "otel":
-		shutdown, err := SetupOTelSDK(context.Background())
+		shutdown, err := SetupOTelSDK(context.Background(), cfg, promReader)
 		if err != nil {
 			return nil, err
 		}
-		root := buildRootLogger(slog.LevelInfo)
+		root := buildRootLogger(slog.LevelInfo, true)
 		return &ObservabilityProvider{
 			logger:   root.With("env", "otel"),
 			tracer:   otel.Tracer(schemaName),
 			meter:    otel.GetMeterProvider().Meter(schemaName),
+			metrics:  promHandler,
 			shutdown: shutdown,
 		}, nil
 
 	case "debug":
-		root := buildRootLogger(slog.LevelDebug)
+		meter, shutdown, err := localMeter(cfg, "debug", promReader)
+		if err != nil {
+			return nil, err
+		}
+		root := buildRootLogger(slog.LevelDebug, false)
 		return &ObservabilityProvider{
 			logger:   root.With("env", "debug", "debug", true),
 			tracer:   noop.NewTracerProvider().Tracer("debug"),
-			meter:    otel.GetMeterProvider().Meter("debug"),
-			shutdown: func(context.Context) error { return nil },
+			meter:    meter,
+			metrics:  promHandler,
+			shutdown: shutdown,
 		}, nil
 
 	case "local":
-		root := buildRootLogger(slog.LevelInfo)
+		meter, shutdown, err := localMeter(cfg, "local", promReader)
+		if err != nil {
+			return nil, err
+		}
+		root := buildRootLogger(slog.LevelInfo, false)
 		return &ObservabilityProvider{
 			logger:   root.With("env", "local"),
 			tracer:   noop.NewTracerProvider().Tracer("local"),
-			meter:    otel.GetMeterProvider().Meter("local"),
-			shutdown: func(context.Context) error { return nil },
+			meter:    meter,
+			metrics:  promHandler,
+			shutdown: shutdown,
 		}, nil
 
 	case "noop":
-		root := buildRootLogger(slog.LevelWarn)
+		meter, shutdown, err := localMeter(cfg, "noop", promReader)
+		if err != nil {
+			return nil, err
+		}
+		root := buildRootLogger(slog.LevelWarn, false)
 		return &ObservabilityProvider{
 			logger:   root.With("env", "noop"),
 			tracer:   noop.NewTracerProvider().Tracer("noop"),
-			meter:    otel.GetMeterProvider().Meter("noop"),
-			shutdown: func(context.Context) error { return nil },
+			meter:    meter,
+			metrics:  promHandler,
+			shutdown: shutdown,
 		}, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported observability mode: %s", mode)
+		return nil, fmt.Errorf("unsupported observability mode: %s", cfg.Mode)
 	}
 }
 
 // TODO: This was taken from https://github.com/grafana/docker-otel-lgtm, review what is actually needed
-func SetupOTelSDK(ctx context.Context) (func(context.Context) error, error) {
+//
+// Readers in extraReaders, such as the Prometheus exporter, are attached to
+// the MeterProvider alongside the OTLP exporter; nil entries are ignored.
+func SetupOTelSDK(ctx context.Context, cfg Config, extraReaders ...sdkmetric.Reader) (func(context.Context) error, error) {
 	var shutdownFuncs []func(context.Context) error
 
 	shutdown := func(ctx context.Context) error {
@@ -129,45 +196,59 @@ func SetupOTelSDK(ctx context.Context) (func(context.Context) error, error) {
 		return combinedErr
 	}
 
+	installErrorHandler()
+
+	target, err := newExportTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build OpenTelemetry resource: %w", err)
+	}
+
 	prop := propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
 		propagation.Baggage{},
 	)
 	otel.SetTextMapPropagator(prop)
 
-	traceExporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint("otel-collector:4318"),
-		otlptracehttp.WithInsecure(),
-	)
-
+	traceExporter, err := target.traceExporter(ctx)
 	if err != nil {
 		return nil, err
 	}
-	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithResource(res),
+	)
 	shutdownFuncs = append(shutdownFuncs, tp.Shutdown)
 	otel.SetTracerProvider(tp)
 
-	metricExporter, err := otlpmetrichttp.New(ctx,
-		otlpmetrichttp.WithEndpoint("otel-collector:4318"),
-		otlpmetrichttp.WithInsecure(),
-	)
-
+	metricExporter, err := target.metricExporter(ctx)
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, shutdown(ctx))
+	}
+	meterOpts := []sdkmetric.Option{
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
 	}
-	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+	for _, reader := range extraReaders {
+		if reader != nil {
+			meterOpts = append(meterOpts, sdkmetric.WithReader(reader))
+		}
+	}
+	meterProvider := sdkmetric.NewMeterProvider(meterOpts...)
 	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
 	otel.SetMeterProvider(meterProvider)
 
-	logExporter, err := otlploghttp.New(ctx,
-		otlploghttp.WithEndpoint("otel-collector:4318"),
-		otlploghttp.WithInsecure(),
-	)
-
+	logExporter, err := target.logExporter(ctx)
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, shutdown(ctx))
 	}
-	loggerProvider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)))
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		sdklog.WithResource(res),
+	)
 	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
 	global.SetLoggerProvider(loggerProvider)
 
@@ -0,0 +1,45 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newPrometheusReader returns a metric reader that collects every instrument
+// on each scrape, and the handler that serves the scrape. It uses its own
+// registry so nothing registered on the Prometheus default is exposed.
+func newPrometheusReader() (sdkmetric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create Prometheus exporter: %w", err)
+	}
+	return exporter, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// localMeter returns the meter for modes without an OTLP pipeline. Given a
+// Prometheus reader it installs a MeterProvider backed by it, so instruments
+// can still be scraped; otherwise it uses the global no-op provider.
+func localMeter(cfg Config, name string, reader sdkmetric.Reader) (metric.Meter, func(context.Context) error, error) {
+	if reader == nil {
+		return otel.GetMeterProvider().Meter(name), func(context.Context) error { return nil }, nil
+	}
+	res, err := newResource(context.Background(), cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to build OpenTelemetry resource: %w", err)
+	}
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(meterProvider)
+	return meterProvider.Meter(name), meterProvider.Shutdown, nil
+}
@@ -0,0 +1,30 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carlmjohnson/be"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	obs, err := New(Config{Mode: "noop", Prometheus: true, ServiceName: "telemetry-tracker"})
+	be.NilErr(t, err)
+	t.Cleanup(func() { _ = obs.Shutdown(context.Background()) })
+
+	counter, err := obs.Meter().Int64Counter("telemetry_tracker.events_stored_total")
+	be.NilErr(t, err)
+	counter.Add(context.Background(), 2)
+
+	rec := httptest.NewRecorder()
+	obs.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	be.Equal(t, http.StatusOK, rec.Code)
+	be.In(t, "telemetry_tracker_events_stored_total{", rec.Body.String())
+	be.In(t, `service_name="telemetry-tracker"`, rec.Body.String())
+
+	obs, err = New(Config{Mode: "noop"})
+	be.NilErr(t, err)
+	be.True(t, obs.MetricsHandler() == nil)
+}
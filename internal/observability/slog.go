@@ -0,0 +1,236 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logHandler fans each record out to several slog handlers, typically the
+// stdout JSON handler and the OpenTelemetry bridge. Records are correlated
+// with the span in their context or, for records logged without one, with
+// the span the logger was bound to by ContextLogger.
+type logHandler struct {
+	handlers []slog.Handler
+	span     trace.SpanContext
+}
+
+func newLogHandler(handlers ...slog.Handler) *logHandler {
+	return &logHandler{handlers: handlers}
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !trace.SpanContextFromContext(ctx).IsValid() && h.span.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, h.span)
+	}
+	var errs error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, r.Level) {
+			errs = errors.Join(errs, handler.Handle(ctx, r.Clone()))
+		}
+	}
+	return errs
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &logHandler{handlers: handlers, span: h.span}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &logHandler{handlers: handlers, span: h.span}
+}
+
+// ContextLogger returns logger bound to the span in ctx, so records it writes
+// carry that span's trace_id and span_id even when logged without a context.
+func ContextLogger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return logger
+	}
+	if h, ok := logger.Handler().(*logHandler); ok {
+		return slog.New(&logHandler{handlers: h.handlers, span: sc})
+	}
+	return logger.With(
+		slog.String("trace_id", sc.TraceID().String()),
+		slog.String("span_id", sc.SpanID().String()),
+	)
+}
+
+// traceAttrHandler adds the trace_id and span_id of the record's context as
+// top-level attributes, for handlers that write text such as stdout JSON.
+type traceAttrHandler struct {
+	slog.Handler
+	grouped bool
+}
+
+func (h *traceAttrHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && !h.grouped {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *traceAttrHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceAttrHandler{Handler: h.Handler.WithAttrs(attrs), grouped: h.grouped}
+}
+
+// WithGroup stops adding the IDs: inside a group they would no longer be at
+// the top level where log pipelines look for them.
+func (h *traceAttrHandler) WithGroup(name string) slog.Handler {
+	return &traceAttrHandler{Handler: h.Handler.WithGroup(name), grouped: h.grouped || name != ""}
+}
+
+// otelHandler is a slog handler that emits records to an OpenTelemetry
+// Logger. The SDK takes the trace and span IDs from the context passed to
+// Emit. Attributes inside groups are flattened to dotted keys.
+type otelHandler struct {
+	logger otellog.Logger
+	level  slog.Leveler
+	attrs  []otellog.KeyValue
+	prefix string
+}
+
+func newOTelHandler(logger otellog.Logger, level slog.Leveler) *otelHandler {
+	return &otelHandler{logger: logger, level: level}
+}
+
+func (h *otelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+	return h.logger.Enabled(ctx, otellog.EnabledParameters{Severity: severity(level)})
+}
+
+func (h *otelHandler) Handle(ctx context.Context, r slog.Record) error {
+	var rec otellog.Record
+	rec.SetTimestamp(r.Time)
+	rec.SetSeverity(severity(r.Level))
+	rec.SetSeverityText(r.Level.String())
+	rec.SetBody(otellog.StringValue(r.Message))
+	rec.AddAttributes(h.attrs...)
+
+	attrs := make([]otellog.KeyValue, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttr(attrs, h.prefix, a)
+		return true
+	})
+	rec.AddAttributes(attrs...)
+
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		rec.AddAttributes(
+			otellog.String("code.function", frame.Function),
+			otellog.String("code.filepath", frame.File),
+			otellog.Int("code.lineno", frame.Line),
+		)
+	}
+
+	h.logger.Emit(ctx, rec)
+	return nil
+}
+
+func (h *otelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = make([]otellog.KeyValue, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(h2.attrs, h.attrs)
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *otelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// severity maps slog levels onto the OpenTelemetry severity numbers, which
+// are offset by 9 and use the same step of 4 between named levels.
+func severity(level slog.Level) otellog.Severity {
+	return otellog.Severity(level + 9)
+}
+
+// appendAttr converts a slog attribute, flattening groups into dotted keys
+// and dropping empty attributes as slog handlers are required to.
+func appendAttr(kvs []otellog.KeyValue, prefix string, a slog.Attr) []otellog.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			kvs = appendAttr(kvs, prefix, ga)
+		}
+		return kvs
+	}
+	return append(kvs, otellog.KeyValue{Key: prefix + a.Key, Value: logValue(a.Value)})
+}
+
+func logValue(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return otellog.Int64Value(int64(u))
+		}
+		return otellog.StringValue(v.String())
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindDuration:
+		return otellog.StringValue(v.Duration().String())
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	}
+	switch x := v.Any().(type) {
+	case error:
+		return otellog.StringValue(x.Error())
+	case []byte:
+		return otellog.BytesValue(x)
+	case fmt.Stringer:
+		return otellog.StringValue(x.String())
+	default:
+		return otellog.StringValue(fmt.Sprint(x))
+	}
+}
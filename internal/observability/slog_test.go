@@ -0,0 +1,78 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/carlmjohnson/be"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/logtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := logtest.NewRecorder()
+	logger := slog.New(newLogHandler(
+		&traceAttrHandler{Handler: slog.NewJSONHandler(&buf, nil)},
+		newOTelHandler(recorder.Logger("test"), slog.LevelInfo),
+	)).With("request_id", "abc")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	// A logger bound to the span correlates records logged without a context.
+	ContextLogger(ctx, logger).Warn("stored",
+		slog.Int("count", 3),
+		slog.Group("db", slog.String("table", "events")),
+		slog.Any("error", errors.New("boom")),
+	)
+	logger.Debug("dropped")
+
+	var line map[string]any
+	be.NilErr(t, json.Unmarshal(buf.Bytes(), &line))
+	be.Equal(t, "stored", line["msg"].(string))
+	be.Equal(t, "abc", line["request_id"].(string))
+	be.Equal(t, sc.TraceID().String(), line["trace_id"].(string))
+	be.Equal(t, sc.SpanID().String(), line["span_id"].(string))
+
+	records := recorder.Result()[0].Records
+	be.Equal(t, 1, len(records))
+	rec := records[0]
+	be.Equal(t, otellog.SeverityWarn, rec.Severity())
+	be.Equal(t, "WARN", rec.SeverityText())
+	be.Equal(t, "stored", rec.Body().AsString())
+	be.True(t, sc.Equal(trace.SpanContextFromContext(rec.Context())))
+
+	attrs := map[string]otellog.Value{}
+	rec.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	be.Equal(t, "abc", attrs["request_id"].AsString())
+	be.Equal(t, int64(3), attrs["count"].AsInt64())
+	be.Equal(t, "events", attrs["db.table"].AsString())
+	be.Equal(t, "boom", attrs["error"].AsString())
+}
+
+func TestContextLoggerPrefersRecordContext(t *testing.T) {
+	recorder := logtest.NewRecorder()
+	logger := slog.New(newLogHandler(newOTelHandler(recorder.Logger("test"), slog.LevelInfo)))
+
+	bound := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	child := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+
+	logger = ContextLogger(trace.ContextWithSpanContext(context.Background(), bound), logger)
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), child), "child")
+
+	rec := recorder.Result()[0].Records[0]
+	be.Equal(t, child.SpanID(), trace.SpanContextFromContext(rec.Context()).SpanID())
+}
@@ -0,0 +1,214 @@
+// Package queue provides a bounded write-behind buffer between the HTTP
+// handlers and the event store.
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrFull is returned when accepting the events would exceed the queue capacity.
+	ErrFull = errors.New("event queue is full")
+	// ErrClosed is returned once the queue has started shutting down.
+	ErrClosed = errors.New("event queue is closed")
+)
+
+// Writer is the downstream the queue flushes batches into.
+type Writer interface {
+	StoreEvents(ctx context.Context, events []storage.Event) ([]int, error)
+}
+
+// Config controls queue sizing and flush behaviour.
+type Config struct {
+	Capacity      int           // Maximum number of buffered events
+	Workers       int           // Number of concurrent flush workers
+	FlushSize     int           // Events per batch insert
+	FlushInterval time.Duration // Maximum time an event waits before being flushed
+}
+
+// Queue buffers events in memory and writes them to a Writer in batches
+// from a pool of workers. It implements storage.Writer, so
+// handlers enqueue instead of waiting on the database.
+type Queue struct {
+	writer  Writer
+	cfg     Config
+	metrics *metrics.Registry
+	obs     observability.Provider
+
+	events chan storage.Event
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending int
+	closed  bool
+}
+
+// New creates a Queue and starts its workers.
+func New(writer Writer, cfg Config, m *metrics.Registry, obs observability.Provider) *Queue {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = 10000
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.FlushSize <= 0 {
+		cfg.FlushSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	q := &Queue{
+		writer:  writer,
+		cfg:     cfg,
+		metrics: m,
+		obs:     obs,
+		events:  make(chan storage.Event, cfg.Capacity),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// StoreEvent enqueues a single event without blocking.
+func (q *Queue) StoreEvent(ctx context.Context, event storage.Event) error {
+	_, err := q.StoreEvents(ctx, []storage.Event{event})
+	return err
+}
+
+// StoreEvents enqueues all events or none of them. It never blocks: if the
+// queue lacks room for the whole slice, ErrFull is returned. Duplicates are
+// only skipped once the events are flushed, so none is ever reported.
+func (q *Queue) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if q.pending+len(events) > q.cfg.Capacity {
+		return nil, ErrFull
+	}
+	// pending never exceeds the channel capacity, so these sends cannot block.
+	q.pending += len(events)
+	for _, event := range events {
+		q.events <- event
+	}
+	q.metrics.QueueDepth.Add(ctx, int64(len(events)))
+	return nil, nil
+}
+
+// Saturation returns the fraction of the queue capacity in use, from 0 to 1.
+func (q *Queue) Saturation() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return float64(q.pending) / float64(q.cfg.Capacity)
+}
+
+// Shutdown stops accepting events and waits for the workers to flush
+// everything already queued, or for ctx to expire.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close drains the queue without a deadline.
+func (q *Queue) Close() {
+	_ = q.Shutdown(context.Background())
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	batch := make([]storage.Event, 0, q.cfg.FlushSize)
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-q.events:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= q.cfg.FlushSize {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (q *Queue) release(n int) {
+	q.mu.Lock()
+	q.pending -= n
+	q.mu.Unlock()
+	q.metrics.QueueDepth.Add(context.Background(), -int64(n))
+}
+
+// flush writes a batch downstream and then releases its share of the
+// capacity, so that events count against it until they are stored. Failed
+// batches are logged and counted as dropped with reason "flush_failed";
+// the events are not retried.
+func (q *Queue) flush(batch []storage.Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, span := q.obs.Tracer().Start(context.Background(), "QueueFlush",
+		trace.WithAttributes(attribute.Int("batch_size", len(batch))),
+	)
+	defer span.End()
+
+	_, err := q.writer.StoreEvents(ctx, batch)
+	q.release(len(batch))
+	if err != nil {
+		reason := metric.WithAttributes(attribute.String("reason", "flush_failed"))
+		q.metrics.DBErrorsTotal.Add(ctx, 1)
+		q.metrics.EventsDroppedTotal.Add(ctx, int64(len(batch)), reason)
+		q.metrics.EventsRejectedTotal.Add(ctx, int64(len(batch)), reason)
+		q.obs.Logger().Error("Failed to flush queued events",
+			slog.Int("batch_size", len(batch)),
+			slog.Any("error", err),
+		)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to flush queued events")
+	}
+}
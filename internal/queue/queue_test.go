@@ -0,0 +1,131 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/queue"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]storage.Event
+	block   chan struct{}
+	entered chan struct{} // Signalled as each flush starts, if set
+	err     error
+}
+
+func (w *recordingWriter) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	if w.entered != nil {
+		w.entered <- struct{}{}
+	}
+	if w.block != nil {
+		<-w.block
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, append([]storage.Event(nil), events...))
+	return nil, nil
+}
+
+func (w *recordingWriter) stored() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, b := range w.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func newQueue(t *testing.T, w queue.Writer, cfg queue.Config) *queue.Queue {
+	t.Helper()
+	obs, _ := observability.InitObservability("noop")
+	reg, err := metrics.NewRegistry(obs.Meter())
+	be.NilErr(t, err)
+	return queue.New(w, cfg, reg, obs)
+}
+
+func TestQueue_FlushesBySize(t *testing.T) {
+	w := &recordingWriter{}
+	q := newQueue(t, w, queue.Config{Capacity: 100, Workers: 1, FlushSize: 3, FlushInterval: time.Hour})
+
+	for i := 0; i < 6; i++ {
+		be.NilErr(t, q.StoreEvent(context.Background(), storage.Event{EventType: "click"}))
+	}
+	be.NilErr(t, q.Shutdown(context.Background()))
+
+	be.Equal(t, 6, w.stored())
+	be.Equal(t, 2, len(w.batches))
+}
+
+func TestQueue_FlushesByInterval(t *testing.T) {
+	w := &recordingWriter{}
+	q := newQueue(t, w, queue.Config{Capacity: 100, Workers: 1, FlushSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer q.Close()
+
+	be.NilErr(t, q.StoreEvent(context.Background(), storage.Event{EventType: "click"}))
+
+	deadline := time.Now().Add(time.Second)
+	for w.stored() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	be.Equal(t, 1, w.stored())
+}
+
+func TestQueue_RejectsWhenFull(t *testing.T) {
+	w := &recordingWriter{block: make(chan struct{})}
+	q := newQueue(t, w, queue.Config{Capacity: 2, Workers: 1, FlushSize: 1, FlushInterval: time.Hour})
+
+	events := []storage.Event{{EventType: "a"}, {EventType: "b"}, {EventType: "c"}}
+	_, err := q.StoreEvents(context.Background(), events)
+	be.True(t, errors.Is(err, queue.ErrFull))
+
+	_, err = q.StoreEvents(context.Background(), events[:2])
+	be.NilErr(t, err)
+	close(w.block)
+	be.NilErr(t, q.Shutdown(context.Background()))
+	be.Equal(t, 2, w.stored())
+
+	err = q.StoreEvent(context.Background(), storage.Event{EventType: "late"})
+	be.True(t, errors.Is(err, queue.ErrClosed))
+}
+
+func TestQueue_HoldsCapacityUntilFlushed(t *testing.T) {
+	w := &recordingWriter{block: make(chan struct{}), entered: make(chan struct{}, 2)}
+	q := newQueue(t, w, queue.Config{Capacity: 2, Workers: 1, FlushSize: 2, FlushInterval: time.Hour})
+
+	_, err := q.StoreEvents(context.Background(), []storage.Event{{EventType: "a"}, {EventType: "b"}})
+	be.NilErr(t, err)
+	<-w.entered
+	// The batch has left the channel but is not stored yet.
+	be.Equal(t, 1.0, q.Saturation())
+	err = q.StoreEvent(context.Background(), storage.Event{EventType: "c"})
+	be.True(t, errors.Is(err, queue.ErrFull))
+
+	close(w.block)
+	be.NilErr(t, q.Shutdown(context.Background()))
+	be.Equal(t, 2, w.stored())
+	be.Equal(t, 0.0, q.Saturation())
+}
+
+func TestQueue_ReleasesCapacityOnFailedFlush(t *testing.T) {
+	w := &recordingWriter{err: errors.New("db failure")}
+	q := newQueue(t, w, queue.Config{Capacity: 2, Workers: 1, FlushSize: 2, FlushInterval: time.Hour})
+
+	_, err := q.StoreEvents(context.Background(), []storage.Event{{EventType: "a"}, {EventType: "b"}})
+	be.NilErr(t, err)
+	be.NilErr(t, q.Shutdown(context.Background()))
+	be.Equal(t, 0, w.stored())
+	be.Equal(t, 0.0, q.Saturation())
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+// day is the period quotas are counted over; days start at midnight UTC.
+const day = 24 * time.Hour
+
+// Quota enforces daily event quotas. Each instance counts the events it
+// accepts and adds them to the shared totals in the store every flush
+// interval, reading back what other instances added. A tenant can
+// therefore overshoot by what every instance accepts in one interval.
+type Quota struct {
+	store    storage.UsageStore
+	tiers    Tiers
+	interval time.Duration
+	obs      observability.Provider
+	now      func() time.Time
+
+	mu      sync.Mutex
+	today   time.Time
+	totals  map[string]int64   // Usage today per tenant, including pending counts
+	pending map[usageKey]int64 // Counts not yet added to the store
+}
+
+type usageKey struct {
+	tenantID string
+	day      time.Time
+}
+
+// NewQuota creates a Quota that flushes usage to store every interval.
+func NewQuota(store storage.UsageStore, tiers Tiers, interval time.Duration, obs observability.Provider) *Quota {
+	return &Quota{
+		store:    store,
+		tiers:    tiers,
+		interval: interval,
+		obs:      obs,
+		now:      time.Now,
+		totals:   make(map[string]int64),
+		pending:  make(map[usageKey]int64),
+	}
+}
+
+// Check reports whether tenantID may submit more events today, and the
+// time until its usage resets.
+func (q *Quota) Check(tenantID string) (bool, time.Duration) {
+	limit := q.tiers.Quota(tenantID)
+	if limit == 0 {
+		return true, 0
+	}
+	now := q.now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(now)
+	// Tenants seen today have their totals read back on every flush.
+	used := q.totals[tenantID]
+	q.totals[tenantID] = used
+	return used < limit, q.today.Add(day).Sub(now)
+}
+
+// Add counts n events accepted for tenantID.
+func (q *Quota) Add(tenantID string, n int) {
+	if n <= 0 || q.tiers.Quota(tenantID) == 0 {
+		return
+	}
+	now := q.now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover(now)
+	q.totals[tenantID] += int64(n)
+	q.pending[usageKey{tenantID, q.today}] += int64(n)
+}
+
+// rollover must be called with q.mu held.
+func (q *Quota) rollover(now time.Time) {
+	if today := now.UTC().Truncate(day); !today.Equal(q.today) {
+		q.today = today
+		clear(q.totals)
+	}
+}
+
+// Run flushes usage every interval until ctx is cancelled.
+func (q *Quota) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := q.Flush(ctx); err != nil && ctx.Err() == nil {
+				q.obs.Logger().Error("Failed to flush quota usage", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Flush adds pending counts to the store and refreshes today's totals.
+// Counts that fail to flush are kept for the next attempt.
+func (q *Quota) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	today := q.today
+	batches := make(map[time.Time]map[string]int64)
+	add := func(d time.Time, tenantID string, n int64) {
+		if batches[d] == nil {
+			batches[d] = make(map[string]int64)
+		}
+		batches[d][tenantID] += n
+	}
+	for k, n := range q.pending {
+		add(k.day, k.tenantID, n)
+	}
+	// A zero count reads back what other instances have added.
+	for tenantID := range q.totals {
+		add(today, tenantID, 0)
+	}
+	clear(q.pending)
+	q.mu.Unlock()
+
+	var firstErr error
+	for d, counts := range batches {
+		totals, err := q.store.AddUsage(ctx, d, counts)
+		q.mu.Lock()
+		if err != nil {
+			for tenantID, n := range counts {
+				if n > 0 {
+					q.pending[usageKey{tenantID, d}] += n
+				}
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if d.Equal(q.today) {
+			// Counts accepted while the flush was in flight are not in the
+			// stored totals yet.
+			for tenantID, total := range totals {
+				q.totals[tenantID] = total + q.pending[usageKey{tenantID, d}]
+			}
+		}
+		q.mu.Unlock()
+	}
+	return firstErr
+}
@@ -0,0 +1,240 @@
+// Package ratelimit throttles requests with per-key token buckets and tracks
+// daily event quotas, both sized by the tier a tenant belongs to.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTier is the tier of tenants that are not assigned one.
+const DefaultTier = "default"
+
+// pruneInterval is how often idle buckets are dropped.
+const pruneInterval = time.Minute
+
+// KeyBy selects what a rate limit bucket is shared by.
+type KeyBy string
+
+const (
+	ByIP     KeyBy = "ip"      // Each client IP within a tenant
+	ByTenant KeyBy = "tenant"  // All of a tenant's requests
+	ByAPIKey KeyBy = "api_key" // Each API key, or the tenant without authentication
+)
+
+// ParseKeyBy parses a rate limit key: ip, tenant or api_key.
+func ParseKeyBy(s string) (KeyBy, error) {
+	switch k := KeyBy(s); k {
+	case ByIP, ByTenant, ByAPIKey:
+		return k, nil
+	}
+	return "", fmt.Errorf("invalid rate limit key %q: must be ip, tenant or api_key", s)
+}
+
+// Rate is a token bucket refilled at PerSecond tokens a second up to Burst.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ParseRate parses "<count>/<unit>[:<burst>]" with unit s, m or h, e.g.
+// "100/s:200" or "600/m". Without a burst, one second's worth of tokens
+// (at least one) may be spent at once.
+func ParseRate(s string) (Rate, error) {
+	spec, burstSpec, hasBurst := strings.Cut(s, ":")
+	count, unit, ok := strings.Cut(spec, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if !ok || err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: want count/unit, e.g. 100/s", s)
+	}
+	per := map[string]float64{"s": 1, "m": 60, "h": 3600}[unit]
+	if per == 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: unit must be s, m or h", s)
+	}
+	r := Rate{PerSecond: n / per, Burst: max(1, int(math.Ceil(n/per)))}
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(burstSpec); err != nil || r.Burst <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate %q: burst must be a positive integer", s)
+		}
+	}
+	return r, nil
+}
+
+// Tiers sizes limits by tier.
+type Tiers struct {
+	Rates   map[string]Rate   // Request rate per tier; tiers without one are not rate limited
+	Quotas  map[string]int64  // Events per UTC day per tier; tiers without one have no quota
+	Tenants map[string]string // Tier per tenant; other tenants are in DefaultTier
+}
+
+// ParseTiers builds Tiers from comma-separated lists of tier=rate rules
+// (e.g. "default=100/s:200,pro=1000/s"), tier=daily event quotas (e.g.
+// "default=1000000") and tenant=tier assignments (e.g. "acme=pro").
+func ParseTiers(rates, quotas, tenants string) (Tiers, error) {
+	t := Tiers{Rates: make(map[string]Rate), Quotas: make(map[string]int64), Tenants: make(map[string]string)}
+	err := parseRules(rates, "tier=rate", func(tier, value string) error {
+		r, err := ParseRate(value)
+		t.Rates[tier] = r
+		return err
+	})
+	if err != nil {
+		return Tiers{}, err
+	}
+	err = parseRules(quotas, "tier=events", func(tier, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid quota %q: must be a positive integer", value)
+		}
+		t.Quotas[tier] = n
+		return nil
+	})
+	if err != nil {
+		return Tiers{}, err
+	}
+	err = parseRules(tenants, "tenant=tier", func(tenantID, tier string) error {
+		t.Tenants[tenantID] = tier
+		return nil
+	})
+	if err != nil {
+		return Tiers{}, err
+	}
+	return t, nil
+}
+
+// parseRules calls fn for each key=value rule in a comma-separated list.
+func parseRules(list, want string, fn func(key, value string) error) error {
+	for _, rule := range strings.Split(list, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(rule, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return fmt.Errorf("invalid rule %q: want %s", rule, want)
+		}
+		if err := fn(key, value); err != nil {
+			return fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+	}
+	return nil
+}
+
+// Tier returns the tier tenantID belongs to.
+func (t Tiers) Tier(tenantID string) string {
+	if tier, ok := t.Tenants[tenantID]; ok {
+		return tier
+	}
+	return DefaultTier
+}
+
+// Rate returns the request rate of tenantID's tier and whether it has one.
+func (t Tiers) Rate(tenantID string) (Rate, bool) {
+	r, ok := t.Rates[t.Tier(tenantID)]
+	return r, ok
+}
+
+// Quota returns the daily event quota of tenantID's tier, or zero when it
+// has none.
+func (t Tiers) Quota(tenantID string) int64 {
+	return t.Quotas[t.Tier(tenantID)]
+}
+
+// Decision is the outcome of taking a token from a bucket.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Bucket size
+	Remaining  int           // Whole tokens left
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until a token is available, when not allowed
+}
+
+// Limiter holds one token bucket per key. Buckets live in process memory,
+// so each instance enforces its limits independently.
+type Limiter struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a Limiter with no buckets.
+func NewLimiter() *Limiter {
+	return &Limiter{now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from key's bucket, which starts full and refills at
+// rate.
+func (l *Limiter) Allow(key string, rate Rate) Decision {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate {
+		b = &bucket{rate: rate, tokens: float64(rate.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	d := Decision{Limit: rate.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / rate.PerSecond)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((float64(rate.Burst) - b.tokens) / rate.PerSecond)
+	return d
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(float64(b.rate.Burst), b.tokens+elapsed*b.rate.PerSecond)
+		b.last = now
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Run drops buckets that have refilled completely, and so would be
+// recreated identically, every pruneInterval until ctx is cancelled. It
+// bounds memory when keys are client IPs.
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.prune()
+		}
+	}
+}
+
+func (l *Limiter) prune() {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Rate
+	}{
+		{"100/s", Rate{PerSecond: 100, Burst: 100}},
+		{"100/s:250", Rate{PerSecond: 100, Burst: 250}},
+		{"60/m", Rate{PerSecond: 1, Burst: 1}},
+		{"1/h", Rate{PerSecond: 1.0 / 3600, Burst: 1}},
+	}
+	for _, tc := range tests {
+		got, err := ParseRate(tc.in)
+		be.NilErr(t, err)
+		be.Equal(t, tc.expected, got)
+	}
+	for _, bad := range []string{"", "100", "0/s", "-1/s", "100/d", "100/s:0", "100/s:x"} {
+		_, err := ParseRate(bad)
+		be.Nonzero(t, err)
+	}
+}
+
+func TestParseTiers(t *testing.T) {
+	tiers, err := ParseTiers("default=10/s, pro=100/s:500", "default=1000", "acme=pro")
+	be.NilErr(t, err)
+	be.Equal(t, "pro", tiers.Tier("acme"))
+	be.Equal(t, DefaultTier, tiers.Tier("globex"))
+	r, ok := tiers.Rate("acme")
+	be.True(t, ok)
+	be.Equal(t, 500, r.Burst)
+	be.Equal(t, int64(1000), tiers.Quota("globex"))
+	be.Equal(t, int64(0), tiers.Quota("acme"))
+
+	_, err = ParseTiers("default", "", "")
+	be.Nonzero(t, err)
+	_, err = ParseTiers("", "default=lots", "")
+	be.Nonzero(t, err)
+	_, err = ParseTiers("", "", "acme=")
+	be.Nonzero(t, err)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+	rate := Rate{PerSecond: 2, Burst: 3}
+
+	for i := range 3 {
+		d := l.Allow("a", rate)
+		be.True(t, d.Allowed)
+		be.Equal(t, 2-i, d.Remaining)
+	}
+	d := l.Allow("a", rate)
+	be.False(t, d.Allowed)
+	be.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	be.Equal(t, 1500*time.Millisecond, d.Reset)
+	// Buckets are independent per key.
+	be.True(t, l.Allow("b", rate).Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	be.True(t, l.Allow("a", rate).Allowed)
+	be.False(t, l.Allow("a", rate).Allowed)
+
+	// Idle buckets refill and are dropped; busy ones are kept.
+	now = now.Add(time.Second)
+	l.prune()
+	be.Equal(t, 1, len(l.buckets))
+	now = now.Add(time.Second)
+	l.prune()
+	be.Equal(t, 0, len(l.buckets))
+}
+
+// failingUsage fails every AddUsage call while down is set.
+type failingUsage struct {
+	storage.UsageStore
+	down bool
+}
+
+func (f *failingUsage) AddUsage(ctx context.Context, day time.Time, counts map[string]int64) (map[string]int64, error) {
+	if f.down {
+		return nil, errors.New("database unavailable")
+	}
+	return f.UsageStore.AddUsage(ctx, day, counts)
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 28, 22, 0, 0, 0, time.UTC)
+	tiers, err := ParseTiers("", "default=10", "acme=unlimited")
+	be.NilErr(t, err)
+	store := &failingUsage{UsageStore: storage.NewMemoryStore()}
+	obs, _ := observability.InitObservability("noop")
+	q := NewQuota(store, tiers, time.Second, obs)
+	q.now = func() time.Time { return now }
+
+	ok, reset := q.Check("globex")
+	be.True(t, ok)
+	be.Equal(t, 2*time.Hour, reset)
+	q.Add("globex", 9)
+	ok, _ = q.Check("globex")
+	be.True(t, ok)
+	q.Add("globex", 1)
+	ok, _ = q.Check("globex")
+	be.False(t, ok)
+
+	// Tenants in a tier without a quota are never limited.
+	q.Add("acme", 100)
+	ok, _ = q.Check("acme")
+	be.True(t, ok)
+
+	// Counts survive a failed flush, and usage from other instances is
+	// read back on the next one.
+	store.down = true
+	be.Nonzero(t, q.Flush(ctx))
+	store.down = false
+	_, err = store.AddUsage(ctx, now, map[string]int64{"initech": 10})
+	be.NilErr(t, err)
+	q.Check("initech")
+	be.NilErr(t, q.Flush(ctx))
+	totals, err := store.AddUsage(ctx, now, map[string]int64{"globex": 0})
+	be.NilErr(t, err)
+	be.Equal(t, int64(10), totals["globex"])
+	ok, _ = q.Check("initech")
+	be.False(t, ok)
+
+	// Usage resets at midnight UTC.
+	now = now.Add(2 * time.Hour)
+	ok, reset = q.Check("globex")
+	be.True(t, ok)
+	be.Equal(t, 24*time.Hour, reset)
+}
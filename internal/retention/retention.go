@@ -0,0 +1,249 @@
+// Package retention deletes events once they outlive the TTL configured for
+// their event type.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Policy maps event types to how long their events are kept. A zero TTL
+// keeps events forever.
+type Policy struct {
+	Default time.Duration
+	PerType map[string]time.Duration
+}
+
+// ParsePolicy builds a Policy from a default TTL and a comma-separated list
+// of event_type=TTL rules, e.g. "heartbeat=7d,purchase=2y". Either may be
+// empty.
+func ParsePolicy(defaultTTL, rules string) (Policy, error) {
+	p := Policy{PerType: make(map[string]time.Duration)}
+	if defaultTTL != "" {
+		ttl, err := ParseTTL(defaultTTL)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid default retention: %w", err)
+		}
+		p.Default = ttl
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		eventType, value, ok := strings.Cut(rule, "=")
+		eventType = strings.TrimSpace(eventType)
+		if !ok || eventType == "" {
+			return Policy{}, fmt.Errorf("invalid retention rule %q: want event_type=ttl", rule)
+		}
+		ttl, err := ParseTTL(strings.TrimSpace(value))
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid retention rule %q: %w", rule, err)
+		}
+		p.PerType[eventType] = ttl
+	}
+	return p, nil
+}
+
+// ParseTTL parses a Go duration ("36h") or a whole number of days, weeks or
+// years ("7d", "2w", "1y"; a year is 365 days).
+func ParseTTL(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if n := len(s); n > 1 {
+		if unit, ok := units[s[n-1]]; ok {
+			count, err := strconv.Atoi(s[:n-1])
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid ttl: %s", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid ttl: %s", s)
+	}
+	return d, nil
+}
+
+// Enabled reports whether the policy expires anything.
+func (p Policy) Enabled() bool {
+	return p.Default > 0 || len(p.PerType) > 0
+}
+
+// maxTTL is the longest TTL of any event type, or zero if some events are
+// kept forever. Partitions older than this hold only expired events.
+func (p Policy) maxTTL() time.Duration {
+	if p.Default == 0 {
+		return 0
+	}
+	ttl := p.Default
+	for _, t := range p.PerType {
+		ttl = max(ttl, t)
+	}
+	return ttl
+}
+
+// Config controls how often and how aggressively the worker purges.
+type Config struct {
+	Interval  time.Duration // Time between retention runs
+	BatchSize int           // Maximum rows deleted per statement
+}
+
+// Worker periodically enforces a Policy against a store.
+type Worker struct {
+	purger  storage.Purger
+	policy  Policy
+	cfg     Config
+	metrics *metrics.Registry
+	obs     observability.Provider
+}
+
+// New creates a retention worker. Call Run to start it.
+func New(purger storage.Purger, policy Policy, cfg Config, m *metrics.Registry, obs observability.Provider) *Worker {
+	return &Worker{purger: purger, policy: policy, cfg: cfg, metrics: m, obs: obs}
+}
+
+// Run enforces the policy immediately and then every Interval until ctx is
+// cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.RunOnce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			w.obs.Logger().Error("Retention run failed", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce enforces the policy as of now and returns the number of rows
+// purged. Whole partitions are dropped first when every event in them has
+// expired; the rest is deleted per event type in batches of BatchSize.
+// Event ID deduplication records older than the longest TTL are pruned
+// last.
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := w.obs.Tracer().Start(ctx, "RetentionRun")
+	defer span.End()
+
+	start := time.Now()
+	var total int64
+	defer func() {
+		w.metrics.RetentionDuration.Record(ctx, time.Since(start).Seconds())
+		span.SetAttributes(attribute.Int64("purged", total))
+	}()
+
+	if ttl := w.policy.maxTTL(); ttl > 0 {
+		n, err := w.purger.DropPartitionsBefore(ctx, now.Add(-ttl))
+		total += n
+		w.record(ctx, n, "", "drop_partition")
+		if err != nil {
+			return total, err
+		}
+	}
+
+	// Deterministic order keeps logs and metrics comparable between runs.
+	types := make([]string, 0, len(w.policy.PerType))
+	for eventType := range w.policy.PerType {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+
+	for _, eventType := range types {
+		n, err := w.purge(ctx, storage.PurgeFilter{
+			EventTypes: []string{eventType},
+			Before:     now.Add(-w.policy.PerType[eventType]),
+		})
+		total += n
+		w.record(ctx, n, eventType, "delete")
+		if err != nil {
+			return total, err
+		}
+	}
+	if w.policy.Default > 0 {
+		n, err := w.purge(ctx, storage.PurgeFilter{
+			EventTypes: types,
+			Exclude:    true,
+			Before:     now.Add(-w.policy.Default),
+		})
+		total += n
+		w.record(ctx, n, "", "delete")
+		if err != nil {
+			return total, err
+		}
+	}
+
+	// Event IDs are deduplicated until the longest TTL has passed, after
+	// which no event they could repeat is left. With no default TTL some
+	// events are kept forever, and so are their IDs.
+	if ttl := w.policy.maxTTL(); ttl > 0 {
+		n, err := w.batches(ctx, func() (int64, error) {
+			return w.purger.PurgeEventIDs(ctx, now.Add(-ttl), w.cfg.BatchSize)
+		})
+		w.record(ctx, n, "", "prune_event_ids")
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if total > 0 {
+		w.obs.Logger().Info("Retention run completed",
+			slog.Int64("purged", total),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+	return total, nil
+}
+
+// purge deletes events matching f in batches.
+func (w *Worker) purge(ctx context.Context, f storage.PurgeFilter) (int64, error) {
+	f.Limit = w.cfg.BatchSize
+	return w.batches(ctx, func() (int64, error) {
+		return w.purger.PurgeEvents(ctx, f)
+	})
+}
+
+// batches calls del until a batch comes back short of BatchSize.
+func (w *Worker) batches(ctx context.Context, del func() (int64, error)) (int64, error) {
+	var total int64
+	for {
+		n, err := del()
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(w.cfg.BatchSize) {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+func (w *Worker) record(ctx context.Context, n int64, eventType, method string) {
+	if n == 0 {
+		return
+	}
+	if eventType == "" {
+		eventType = "_default"
+	}
+	w.metrics.RetentionPurged.Add(ctx, n, metric.WithAttributes(
+		attribute.String("event_type", eventType),
+		attribute.String("method", method),
+	))
+}
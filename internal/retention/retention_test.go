@@ -0,0 +1,97 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("90d", "heartbeat=7d, purchase = 2y,debug=36h")
+	be.NilErr(t, err)
+	be.Equal(t, 90*24*time.Hour, p.Default)
+	be.Equal(t, 7*24*time.Hour, p.PerType["heartbeat"])
+	be.Equal(t, 2*365*24*time.Hour, p.PerType["purchase"])
+	be.Equal(t, 36*time.Hour, p.PerType["debug"])
+	be.Equal(t, 2*365*24*time.Hour, p.maxTTL())
+
+	p, err = ParsePolicy("", "heartbeat=7d")
+	be.NilErr(t, err)
+	be.True(t, p.Enabled())
+	be.Equal(t, time.Duration(0), p.maxTTL())
+
+	for _, bad := range [][2]string{{"forever", ""}, {"", "heartbeat"}, {"", "=7d"}, {"", "heartbeat=-1d"}, {"0s", ""}} {
+		_, err := ParsePolicy(bad[0], bad[1])
+		be.Nonzero(t, err)
+	}
+}
+
+func TestWorker_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := storage.NewMemoryStore()
+
+	var events []storage.Event
+	add := func(eventType string, age time.Duration, n int) {
+		for range n {
+			events = append(events, storage.Event{EventType: eventType, Timestamp: now.Add(-age), Data: json.RawMessage(`{}`)})
+		}
+	}
+	add("heartbeat", 8*24*time.Hour, 5) // expired
+	add("heartbeat", 6*24*time.Hour, 1)
+	add("purchase", 400*24*time.Hour, 2) // kept for 2y
+	add("click", 31*24*time.Hour, 3)     // expired under the default
+	add("click", 29*24*time.Hour, 1)
+	_, err := store.StoreEvents(ctx, events)
+	be.NilErr(t, err)
+
+	policy, err := ParsePolicy("30d", "heartbeat=7d,purchase=2y")
+	be.NilErr(t, err)
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	w := New(store, policy, Config{Interval: time.Hour, BatchSize: 2}, reg, obs)
+
+	purged, err := w.RunOnce(ctx, now)
+	be.NilErr(t, err)
+	be.Equal(t, int64(8), purged)
+
+	page, err := store.QueryEvents(ctx, storage.EventQuery{Limit: 100})
+	be.NilErr(t, err)
+	counts := make(map[string]int)
+	for _, e := range page.Events {
+		counts[e.EventType]++
+	}
+	be.Equal(t, 1, counts["heartbeat"])
+	be.Equal(t, 2, counts["purchase"])
+	be.Equal(t, 1, counts["click"])
+}
+
+func TestWorker_RunOnce_PrunesEventIDs(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	event := storage.Event{EventID: "0f8fad5b-d9cb-469f-a165-70867728950e", EventType: "click", Data: json.RawMessage(`{}`)}
+	be.NilErr(t, store.StoreEvent(ctx, event))
+
+	policy, err := ParsePolicy("30d", "")
+	be.NilErr(t, err)
+	obs, _ := observability.InitObservability("noop")
+	reg, _ := metrics.NewRegistry(obs.Meter())
+	w := New(store, policy, Config{Interval: time.Hour, BatchSize: 100}, reg, obs)
+
+	// Within the TTL the ID is still deduplicated.
+	_, err = w.RunOnce(ctx, time.Now())
+	be.NilErr(t, err)
+	be.Equal(t, storage.ErrDuplicateEvent, store.StoreEvent(ctx, event))
+
+	// Once the TTL has passed, the event and its ID are both gone.
+	purged, err := w.RunOnce(ctx, time.Now().Add(31*24*time.Hour))
+	be.NilErr(t, err)
+	be.Equal(t, int64(1), purged)
+	be.NilErr(t, store.StoreEvent(ctx, event))
+}
@@ -0,0 +1,340 @@
+// Package schema validates event payloads against per-event-type JSON Schemas.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Policy decides what happens to events whose type has no registered schema.
+type Policy string
+
+const (
+	PolicyAllow      Policy = "allow"      // store the event unvalidated
+	PolicyReject     Policy = "reject"     // refuse the event
+	PolicyQuarantine Policy = "quarantine" // store the event flagged as quarantined
+)
+
+// ParsePolicy validates an unknown-event-type policy name.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyAllow, PolicyReject, PolicyQuarantine:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unsupported unknown event type policy: %s", s)
+	}
+}
+
+var (
+	// ErrUnknownEventType is returned by Validate under PolicyReject when no
+	// schema is registered for the event type.
+	ErrUnknownEventType = errors.New("no schema registered for event type")
+	// ErrUnknownVersion is returned when the event type is known but the
+	// requested schema version is not.
+	ErrUnknownVersion = errors.New("no schema registered for event type version")
+	// ErrInvalidName is returned for event types that cannot be used as schema names.
+	ErrInvalidName = errors.New("event type may only contain letters, digits, '.', '_' and '-'")
+	// ErrPersist is returned by Put when a valid schema could not be written
+	// to the schema directory. The schema is not registered.
+	ErrPersist = errors.New("unable to persist schema")
+)
+
+// namePattern restricts event types with schemas to names that are safe to
+// use as file names in the schema directory.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// fileNamePattern matches "<event_type>.json" and "<event_type>.v<N>.json".
+var fileNamePattern = regexp.MustCompile(`^(.+?)(?:\.v([0-9]+))?\.json$`)
+
+// Violation is a single schema failure, located by a JSON pointer into the event.
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports why an event's data does not match its schema.
+type ValidationError struct {
+	EventType  string      `json:"event_type"`
+	Version    int         `json:"version"`
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("data does not match schema for %s v%d", e.EventType, e.Version)
+	if len(e.Violations) > 0 {
+		msg += fmt.Sprintf(": %s: %s", e.Violations[0].Pointer, e.Violations[0].Message)
+	}
+	return msg
+}
+
+// Info describes a registered schema.
+type Info struct {
+	EventType string `json:"event_type"`
+	Version   int    `json:"version"`
+}
+
+type entry struct {
+	raw      json.RawMessage
+	compiled *jsonschema.Schema
+}
+
+// Registry maps event types and versions to compiled JSON Schemas. It is
+// safe for concurrent use. When dir is set, schemas added or removed at
+// runtime are also written to or deleted from that directory.
+type Registry struct {
+	policy Policy
+	dir    string
+
+	mu      sync.RWMutex
+	schemas map[string]map[int]entry
+}
+
+// NewRegistry creates an empty registry with the given unknown-type policy.
+func NewRegistry(policy Policy) *Registry {
+	return &Registry{
+		policy:  policy,
+		schemas: make(map[string]map[int]entry),
+	}
+}
+
+// LoadDir loads every schema file in dir and remembers dir so that later
+// Put and Delete calls are persisted there.
+func (r *Registry) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read schema directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileNamePattern.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version := 1
+		if m[2] != "" {
+			if version, err = strconv.Atoi(m[2]); err != nil {
+				return fmt.Errorf("invalid schema version in %s: %w", e.Name(), err)
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("unable to read schema %s: %w", e.Name(), err)
+		}
+		compiled, err := compile(m[1], version, data)
+		if err != nil {
+			return fmt.Errorf("invalid schema %s: %w", e.Name(), err)
+		}
+		r.set(m[1], version, compiled)
+	}
+	r.dir = dir
+	return nil
+}
+
+// Put compiles and registers a schema, replacing any existing one. With a
+// schema directory, the schema is written there before it takes effect, so
+// that it is not enforced unless it survives a restart.
+func (r *Registry) Put(eventType string, version int, schema []byte) error {
+	compiled, err := compile(eventType, version, schema)
+	if err != nil {
+		return err
+	}
+	if r.dir != "" {
+		path := schemaPath(r.dir, eventType, version)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, schema, 0o644); err != nil {
+			return fmt.Errorf("%w: %w", ErrPersist, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("%w: %w", ErrPersist, err)
+		}
+	}
+	r.set(eventType, version, compiled)
+	return nil
+}
+
+// compile checks the name and version of a schema and compiles it.
+func compile(eventType string, version int, schema []byte) (entry, error) {
+	if !namePattern.MatchString(eventType) {
+		return entry{}, ErrInvalidName
+	}
+	if version < 1 {
+		return entry{}, fmt.Errorf("schema version must be at least 1, got %d", version)
+	}
+
+	url := fmt.Sprintf("mem://schemas/%s/v%d.json", eventType, version)
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("remote schema references are not supported: %s", s)
+	}
+	if err := c.AddResource(url, bytes.NewReader(schema)); err != nil {
+		return entry{}, err
+	}
+	compiled, err := c.Compile(url)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{raw: append(json.RawMessage(nil), schema...), compiled: compiled}, nil
+}
+
+// set registers a compiled schema.
+func (r *Registry) set(eventType string, version int, e entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.schemas[eventType] == nil {
+		r.schemas[eventType] = make(map[int]entry)
+	}
+	r.schemas[eventType][version] = e
+}
+
+// Delete removes a schema. It reports whether the schema existed.
+func (r *Registry) Delete(eventType string, version int) (bool, error) {
+	r.mu.Lock()
+	versions := r.schemas[eventType]
+	_, ok := versions[version]
+	if ok {
+		delete(versions, version)
+		if len(versions) == 0 {
+			delete(r.schemas, eventType)
+		}
+	}
+	r.mu.Unlock()
+
+	if !ok || r.dir == "" {
+		return ok, nil
+	}
+	paths := []string{schemaPath(r.dir, eventType, version)}
+	if version == 1 {
+		paths = append(paths, filepath.Join(r.dir, eventType+".json"))
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return true, fmt.Errorf("unable to remove schema file: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// Get returns the raw schema document. A version of 0 selects the latest.
+func (r *Registry) Get(eventType string, version int) (json.RawMessage, int, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, v, ok := r.lookup(eventType, version)
+	return e.raw, v, ok
+}
+
+// List returns all registered schemas ordered by event type and version.
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]Info, 0, len(r.schemas))
+	for eventType, versions := range r.schemas {
+		for version := range versions {
+			infos = append(infos, Info{EventType: eventType, Version: version})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].EventType != infos[j].EventType {
+			return infos[i].EventType < infos[j].EventType
+		}
+		return infos[i].Version < infos[j].Version
+	})
+	return infos
+}
+
+// Validate checks data against the schema for eventType. A version of 0
+// selects the latest registered version. It returns quarantine=true when the
+// event type is unknown and the policy is PolicyQuarantine; a
+// *ValidationError when the data does not match; and ErrUnknownEventType or
+// ErrUnknownVersion when the event cannot be checked and must be refused.
+func (r *Registry) Validate(eventType string, version int, data json.RawMessage) (quarantine bool, err error) {
+	r.mu.RLock()
+	_, known := r.schemas[eventType]
+	e, resolved, ok := r.lookup(eventType, version)
+	r.mu.RUnlock()
+
+	if !known {
+		switch r.policy {
+		case PolicyReject:
+			return false, ErrUnknownEventType
+		case PolicyQuarantine:
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+	if !ok {
+		return false, ErrUnknownVersion
+	}
+
+	var doc any
+	if len(data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return false, &ValidationError{
+				EventType:  eventType,
+				Version:    resolved,
+				Violations: []Violation{{Pointer: "/data", Message: err.Error()}},
+			}
+		}
+	}
+
+	if err := e.compiled.Validate(doc); err != nil {
+		var ve *jsonschema.ValidationError
+		if !errors.As(err, &ve) {
+			return false, err
+		}
+		return false, &ValidationError{EventType: eventType, Version: resolved, Violations: violations(ve)}
+	}
+	return false, nil
+}
+
+// lookup must be called with r.mu held.
+func (r *Registry) lookup(eventType string, version int) (entry, int, bool) {
+	versions := r.schemas[eventType]
+	if version == 0 {
+		for v := range versions {
+			if v > version {
+				version = v
+			}
+		}
+	}
+	e, ok := versions[version]
+	return e, version, ok
+}
+
+// violations flattens a validation error tree into its leaf failures, with
+// pointers rebased from the data document onto the event ("/data/...").
+func violations(ve *jsonschema.ValidationError) []Violation {
+	var out []Violation
+	var walk func(*jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			out = append(out, Violation{Pointer: "/data" + ve.InstanceLocation, Message: ve.Message})
+			return
+		}
+		for _, cause := range ve.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	return out
+}
+
+func schemaPath(dir, eventType string, version int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.v%d.json", eventType, version))
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carlmjohnson/be"
+)
+
+const pageViewSchema = `{
+	"type": "object",
+	"required": ["url"],
+	"properties": {
+		"url": {"type": "string"},
+		"duration_ms": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func TestRegistry_Validate(t *testing.T) {
+	r := NewRegistry(PolicyReject)
+	be.NilErr(t, r.Put("page_view", 1, []byte(pageViewSchema)))
+	be.NilErr(t, r.Put("page_view", 2, []byte(`{"type": "object", "required": ["path"]}`)))
+
+	// Version 0 selects the latest schema.
+	_, err := r.Validate("page_view", 0, []byte(`{"url": "/"}`))
+	var ve *ValidationError
+	be.True(t, errors.As(err, &ve))
+	be.Equal(t, 2, ve.Version)
+
+	_, err = r.Validate("page_view", 1, []byte(`{"url": "/", "duration_ms": 12}`))
+	be.NilErr(t, err)
+
+	_, err = r.Validate("page_view", 1, []byte(`{"url": "/", "duration_ms": -1}`))
+	be.True(t, errors.As(err, &ve))
+	be.Equal(t, 1, len(ve.Violations))
+	be.Equal(t, "/data/duration_ms", ve.Violations[0].Pointer)
+
+	_, err = r.Validate("page_view", 3, []byte(`{}`))
+	be.True(t, errors.Is(err, ErrUnknownVersion))
+
+	_, err = r.Validate("click", 0, []byte(`{}`))
+	be.True(t, errors.Is(err, ErrUnknownEventType))
+}
+
+func TestRegistry_Policy(t *testing.T) {
+	quarantine, err := NewRegistry(PolicyAllow).Validate("click", 0, nil)
+	be.NilErr(t, err)
+	be.False(t, quarantine)
+
+	quarantine, err = NewRegistry(PolicyQuarantine).Validate("click", 0, nil)
+	be.NilErr(t, err)
+	be.True(t, quarantine)
+
+	_, err = ParsePolicy("drop")
+	be.Nonzero(t, err)
+}
+
+func TestRegistry_Put(t *testing.T) {
+	r := NewRegistry(PolicyAllow)
+	be.True(t, errors.Is(r.Put("../etc", 1, []byte(`{}`)), ErrInvalidName))
+	be.Nonzero(t, r.Put("click", 0, []byte(`{}`)))
+	be.Nonzero(t, r.Put("click", 1, []byte(`{"type": 12}`)))
+	be.Nonzero(t, r.Put("click", 1, []byte(`{"$ref": "https://example.com/schema.json"}`)))
+}
+
+func TestRegistry_LoadDirPersists(t *testing.T) {
+	dir := t.TempDir()
+	be.NilErr(t, os.WriteFile(filepath.Join(dir, "page_view.json"), []byte(pageViewSchema), 0o644))
+	be.NilErr(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o644))
+
+	r := NewRegistry(PolicyAllow)
+	be.NilErr(t, r.LoadDir(dir))
+	be.AllEqual(t, []Info{{EventType: "page_view", Version: 1}}, r.List())
+
+	be.NilErr(t, r.Put("click", 2, []byte(`{"type": "object"}`)))
+	_, err := os.Stat(filepath.Join(dir, "click.v2.json"))
+	be.NilErr(t, err)
+
+	found, err := r.Delete("page_view", 1)
+	be.NilErr(t, err)
+	be.True(t, found)
+	_, err = os.Stat(filepath.Join(dir, "page_view.json"))
+	be.True(t, errors.Is(err, os.ErrNotExist))
+
+	reloaded := NewRegistry(PolicyAllow)
+	be.NilErr(t, reloaded.LoadDir(dir))
+	be.AllEqual(t, []Info{{EventType: "click", Version: 2}}, reloaded.List())
+}
+
+func TestRegistry_PutPersistFailure(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry(PolicyReject)
+	be.NilErr(t, r.LoadDir(dir))
+	be.NilErr(t, os.RemoveAll(dir))
+
+	err := r.Put("click", 1, []byte(`{"type": "object"}`))
+	be.True(t, errors.Is(err, ErrPersist))
+	// A schema that was not written is not enforced either.
+	_, _, ok := r.Get("click", 1)
+	be.False(t, ok)
+	_, err = r.Validate("click", 1, []byte(`{}`))
+	be.True(t, errors.Is(err, ErrUnknownEventType))
+}
@@ -0,0 +1,249 @@
+// Package signing manages the per-tenant secrets that server-side producers
+// sign requests with, and verifies HMAC-SHA256 request signatures.
+//
+// A request is signed by sending the Unix time in seconds as
+// X-Signature-Timestamp and "v1=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>" under a tenant secret as X-Signature.
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+const (
+	// TimestampHeader carries the Unix time, in seconds, a request was signed.
+	TimestampHeader = "X-Signature-Timestamp"
+	// SignatureHeader carries one or more comma-separated "v1=<hex>" signatures.
+	SignatureHeader = "X-Signature"
+)
+
+// secretPrefix marks telemetry-tracker signing secrets for secret scanners.
+const secretPrefix = "tts_"
+
+const signatureVersion = "v1="
+
+var (
+	// ErrMissingSignature is returned when a tenant that signs its requests
+	// sends one without a signature.
+	ErrMissingSignature = errors.New("request signature required")
+	// ErrInvalidSignature is returned when no active secret produces the
+	// signature.
+	ErrInvalidSignature = errors.New("request signature does not match")
+	// ErrStaleTimestamp is returned when the signed timestamp is missing,
+	// malformed or outside the tolerance window, e.g. for a replayed request.
+	ErrStaleTimestamp = errors.New("request timestamp missing or outside tolerance")
+	// ErrInactive is returned when rotating a secret that is expired or revoked.
+	ErrInactive = errors.New("signing secret is expired or revoked")
+)
+
+// Sign returns the X-Signature value for body signed at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	return signatureVersion + hex.EncodeToString(mac(secret, timestamp, body))
+}
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(strconv.AppendInt(nil, timestamp, 10))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate signing secret: %w", err)
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Manager manages the secrets in a SecretStore and verifies signatures
+// against them. A tenant's secrets are cached like API keys, so a secret
+// revoked through another instance keeps verifying until its cache entry
+// expires.
+type Manager struct {
+	store     storage.SecretStore
+	cacheTTL  time.Duration
+	tolerance time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedSecrets // by tenant
+}
+
+type cachedSecrets struct {
+	secrets []storage.SigningSecret
+	fetched time.Time
+}
+
+// NewManager creates a Manager accepting signatures made up to tolerance
+// before or after the current time. A cacheTTL of zero disables caching.
+func NewManager(store storage.SecretStore, cacheTTL, tolerance time.Duration) *Manager {
+	return &Manager{
+		store:     store,
+		cacheTTL:  cacheTTL,
+		tolerance: tolerance,
+		now:       time.Now,
+		cache:     make(map[string]cachedSecrets),
+	}
+}
+
+// Create issues a secret for tenantID. The record carries the secret, which
+// callers should only show once.
+func (m *Manager) Create(ctx context.Context, tenantID, name string) (storage.SigningSecret, error) {
+	if !apikey.ValidTenantID(tenantID) {
+		return storage.SigningSecret{}, apikey.ErrInvalidTenant
+	}
+	secret, err := generate()
+	if err != nil {
+		return storage.SigningSecret{}, err
+	}
+	record := storage.SigningSecret{
+		ID:        uuid.NewString(),
+		TenantID:  tenantID,
+		Name:      name,
+		Secret:    secret,
+		CreatedAt: m.now().UTC().Truncate(time.Microsecond),
+	}
+	if err := m.store.CreateSigningSecret(ctx, record); err != nil {
+		return storage.SigningSecret{}, err
+	}
+	m.forget(tenantID)
+	return record, nil
+}
+
+// Rotate issues a replacement for secret id and makes the old secret
+// expire after grace, during which signatures made with either verify.
+func (m *Manager) Rotate(ctx context.Context, id string, grace time.Duration) (storage.SigningSecret, error) {
+	old, err := m.store.GetSigningSecret(ctx, id)
+	if err != nil {
+		return storage.SigningSecret{}, err
+	}
+	now := m.now().UTC().Truncate(time.Microsecond)
+	if !old.Active(now) {
+		return storage.SigningSecret{}, ErrInactive
+	}
+	record, err := m.Create(ctx, old.TenantID, old.Name)
+	if err != nil {
+		return storage.SigningSecret{}, err
+	}
+	if err := m.store.ExpireSigningSecret(ctx, id, now.Add(grace)); err != nil {
+		return storage.SigningSecret{}, err
+	}
+	m.forget(old.TenantID)
+	return record, nil
+}
+
+// Revoke stops secret id from verifying signatures and returns its updated
+// record.
+func (m *Manager) Revoke(ctx context.Context, id string) (storage.SigningSecret, error) {
+	if err := m.store.RevokeSigningSecret(ctx, id, m.now().UTC().Truncate(time.Microsecond)); err != nil {
+		return storage.SigningSecret{}, err
+	}
+	record, err := m.store.GetSigningSecret(ctx, id)
+	if err != nil {
+		return storage.SigningSecret{}, err
+	}
+	m.forget(record.TenantID)
+	return record, nil
+}
+
+// Get returns the record of secret id.
+func (m *Manager) Get(ctx context.Context, id string) (storage.SigningSecret, error) {
+	return m.store.GetSigningSecret(ctx, id)
+}
+
+// List returns the secrets of tenantID, or of every tenant when it is empty.
+func (m *Manager) List(ctx context.Context, tenantID string) ([]storage.SigningSecret, error) {
+	return m.store.ListSigningSecrets(ctx, tenantID)
+}
+
+// Verify checks a request body from tenantID against the values of its
+// TimestampHeader and SignatureHeader. Tenants without an active secret do
+// not sign their requests, so anything passes for them. It returns
+// ErrMissingSignature, ErrStaleTimestamp or ErrInvalidSignature for a
+// rejected request, and any other error when the store could not be
+// consulted.
+func (m *Manager) Verify(ctx context.Context, tenantID, timestamp, signature string, body []byte) error {
+	now := m.now()
+	secrets, err := m.secrets(ctx, tenantID, now)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > m.tolerance || skew < -m.tolerance {
+		return ErrStaleTimestamp
+	}
+	for _, sig := range strings.Split(signature, ",") {
+		got, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(sig), signatureVersion))
+		if err != nil {
+			continue
+		}
+		for _, secret := range secrets {
+			if hmac.Equal(got, mac(secret.Secret, ts, body)) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// secrets returns the secrets of tenantID active at now.
+func (m *Manager) secrets(ctx context.Context, tenantID string, now time.Time) ([]storage.SigningSecret, error) {
+	if tenantID == "" {
+		// ListSigningSecrets would return every tenant's secrets.
+		return nil, nil
+	}
+	m.mu.Lock()
+	cached, ok := m.cache[tenantID]
+	m.mu.Unlock()
+	if !ok || now.Sub(cached.fetched) >= m.cacheTTL {
+		secrets, err := m.store.ListSigningSecrets(ctx, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up signing secrets: %w", err)
+		}
+		cached = cachedSecrets{secrets: secrets, fetched: now}
+		if m.cacheTTL > 0 {
+			m.mu.Lock()
+			m.cache[tenantID] = cached
+			m.mu.Unlock()
+		}
+	}
+	var active []storage.SigningSecret
+	for _, s := range cached.secrets {
+		if s.Active(now) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+func (m *Manager) forget(tenantID string) {
+	m.mu.Lock()
+	delete(m.cache, tenantID)
+	m.mu.Unlock()
+}
@@ -0,0 +1,92 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/apikey"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	m := NewManager(storage.NewMemoryStore(), time.Minute, 5*time.Minute)
+	m.now = func() time.Time { return now }
+	body := []byte(`{"event_type":"purchase"}`)
+	ts := now.Unix()
+	stamp := strconv.FormatInt(ts, 10)
+
+	// Tenants without secrets do not sign.
+	be.NilErr(t, m.Verify(ctx, "acme", "", "", body))
+
+	_, err := m.Create(ctx, "not a tenant", "")
+	be.True(t, errors.Is(err, apikey.ErrInvalidTenant))
+	record, err := m.Create(ctx, "acme", "billing")
+	be.NilErr(t, err)
+
+	be.NilErr(t, m.Verify(ctx, "acme", stamp, Sign(record.Secret, ts, body), body))
+	be.NilErr(t, m.Verify(ctx, "globex", "", "", body))
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      []byte
+		expected  error
+	}{
+		{"Unsigned", "", "", body, ErrMissingSignature},
+		{"Tampered body", stamp, Sign(record.Secret, ts, body), []byte(`{"event_type":"refund"}`), ErrInvalidSignature},
+		{"Other timestamp", strconv.FormatInt(ts+1, 10), Sign(record.Secret, ts, body), body, ErrInvalidSignature},
+		{"Wrong secret", stamp, Sign("tts_other", ts, body), body, ErrInvalidSignature},
+		{"Malformed signature", stamp, "v1=zz", body, ErrInvalidSignature},
+		{"Missing timestamp", "", Sign(record.Secret, ts, body), body, ErrStaleTimestamp},
+		{"Replayed", strconv.FormatInt(ts-301, 10), Sign(record.Secret, ts-301, body), body, ErrStaleTimestamp},
+		{"Future", strconv.FormatInt(ts+301, 10), Sign(record.Secret, ts+301, body), body, ErrStaleTimestamp},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := m.Verify(ctx, "acme", tc.timestamp, tc.signature, tc.body)
+			be.True(t, errors.Is(err, tc.expected))
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	m := NewManager(storage.NewMemoryStore(), time.Minute, 5*time.Minute)
+	m.now = func() time.Time { return now }
+	body := []byte(`{}`)
+	verify := func(secret string) error {
+		return m.Verify(ctx, "acme", strconv.FormatInt(now.Unix(), 10), Sign(secret, now.Unix(), body), body)
+	}
+
+	old, err := m.Create(ctx, "acme", "")
+	be.NilErr(t, err)
+	replacement, err := m.Rotate(ctx, old.ID, time.Hour)
+	be.NilErr(t, err)
+
+	// Both secrets verify during the grace period, then only the new one.
+	be.NilErr(t, verify(old.Secret))
+	be.NilErr(t, verify(replacement.Secret))
+	now = now.Add(time.Hour)
+	be.True(t, errors.Is(verify(old.Secret), ErrInvalidSignature))
+	be.NilErr(t, verify(replacement.Secret))
+	_, err = m.Rotate(ctx, old.ID, time.Hour)
+	be.True(t, errors.Is(err, ErrInactive))
+
+	// A signature may list one per secret; any match is enough.
+	stamp := strconv.FormatInt(now.Unix(), 10)
+	both := Sign(old.Secret, now.Unix(), body) + "," + Sign(replacement.Secret, now.Unix(), body)
+	be.NilErr(t, m.Verify(ctx, "acme", stamp, both, body))
+
+	// Revoking the last secret means the tenant no longer signs.
+	_, err = m.Revoke(ctx, replacement.ID)
+	be.NilErr(t, err)
+	be.NilErr(t, m.Verify(ctx, "acme", "", "", body))
+}
@@ -0,0 +1,260 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+const (
+	segmentExt     = ".seg"
+	checkpointFile = "replay.ckpt"
+	// recordHeaderSize is the length prefix plus the CRC32 of the payload.
+	recordHeaderSize = 8
+	// maxRecordSize guards against reading a corrupt length prefix.
+	maxRecordSize = 16 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt spool record")
+
+// record is the on-disk form of a spooled event. It lists the event fields
+// explicitly so the spool format does not depend on storage.Event's JSON tags.
+type record struct {
+	SpooledAt time.Time       `json:"spooled_at"`
+	EventID   string          `json:"event_id,omitempty"`
+	EventType string          `json:"event_type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data,omitempty"`
+
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	Quarantined   bool   `json:"quarantined,omitempty"`
+	TenantID      string `json:"tenant_id,omitempty"`
+
+	Source        string `json:"source,omitempty"`
+	SourceEventID string `json:"source_event_id,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+
+	UserID      string `json:"user_id,omitempty"`
+	AnonymousID string `json:"anonymous_id,omitempty"`
+}
+
+func newRecord(event storage.Event, now time.Time) record {
+	return record{
+		SpooledAt: now,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Timestamp: event.Timestamp,
+		Data:      event.Data,
+
+		SchemaVersion: event.SchemaVersion,
+		Quarantined:   event.Quarantined,
+		TenantID:      event.TenantID,
+
+		Source:        event.Source,
+		SourceEventID: event.SourceEventID,
+		Subject:       event.Subject,
+
+		UserID:      event.UserID,
+		AnonymousID: event.AnonymousID,
+	}
+}
+
+func (r record) event() storage.Event {
+	// Records spooled before tenants existed belong to the default tenant.
+	tenantID := r.TenantID
+	if tenantID == "" {
+		tenantID = storage.DefaultTenant
+	}
+	return storage.Event{
+		EventID:   r.EventID,
+		EventType: r.EventType,
+		Timestamp: r.Timestamp,
+		Data:      r.Data,
+
+		SchemaVersion: r.SchemaVersion,
+		Quarantined:   r.Quarantined,
+		TenantID:      tenantID,
+
+		Source:        r.Source,
+		SourceEventID: r.SourceEventID,
+		Subject:       r.Subject,
+
+		UserID:      r.UserID,
+		AnonymousID: r.AnonymousID,
+	}
+}
+
+// appendRecord encodes rec as [len][crc32][json] onto buf.
+func appendRecord(buf []byte, rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return buf, fmt.Errorf("unable to encode spool record: %w", err)
+	}
+	var header [recordHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	buf = append(buf, header[:]...)
+	return append(buf, payload...), nil
+}
+
+// segmentReader iterates over the records of a segment file.
+type segmentReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func newSegmentReader(f *os.File, offset int64) (*segmentReader, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &segmentReader{r: bufio.NewReader(f), offset: offset}, nil
+}
+
+// next returns the next record and the offset just past it. It returns
+// io.EOF at a clean end of segment and errCorruptRecord for a torn or
+// damaged record, which is treated as the end of the readable data.
+func (sr *segmentReader) next() (record, int64, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(sr.r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return record{}, sr.offset, io.EOF
+		}
+		return record{}, sr.offset, errCorruptRecord
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return record{}, sr.offset, errCorruptRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, payload); err != nil {
+		return record{}, sr.offset, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return record{}, sr.offset, errCorruptRecord
+	}
+	var rec record
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return record{}, sr.offset, errCorruptRecord
+	}
+	sr.offset += recordHeaderSize + int64(size)
+	return rec, sr.offset, nil
+}
+
+// segment describes a sealed segment waiting to be replayed.
+type segment struct {
+	seq     uint64
+	path    string
+	records int64
+	bytes   int64
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", seq, segmentExt))
+}
+
+// listSegments returns the sequence numbers of the segments in dir, oldest first.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// scanSegment counts the readable records after offset and truncates any
+// torn tail left behind by a crash mid-write.
+func scanSegment(path string, offset int64) (records int64, size int64, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	sr, err := newSegmentReader(f, offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := offset
+	for {
+		_, next, err := sr.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if terr := f.Truncate(end); terr != nil {
+				return 0, 0, terr
+			}
+			break
+		}
+		records++
+		end = next
+	}
+	return records, end - offset, nil
+}
+
+// checkpoint records how far into a segment replay has progressed, so a
+// restart does not resend events that already reached the database.
+type checkpoint struct {
+	Seq    uint64 `json:"seq"`
+	Offset int64  `json:"offset"`
+}
+
+func readCheckpoint(dir string) (checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{}, nil
+	}
+	if err != nil {
+		return checkpoint{}, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("invalid spool checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func writeCheckpoint(dir string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, checkpointFile))
+}
+
+func removeCheckpoint(dir string) error {
+	err := os.Remove(filepath.Join(dir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,522 @@
+// Package spool implements a durable, append-only on-disk buffer for events
+// that could not be written to the database. Spooled events are replayed
+// into the database in the order they were received once it recovers.
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// replayBatchSize is the number of spooled events written per replay insert.
+const replayBatchSize = 500
+
+// SyncPolicy controls when appended records are fsynced to disk.
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync after every append
+	SyncInterval SyncPolicy = "interval" // fsync periodically in the background
+	SyncNever    SyncPolicy = "never"    // leave flushing to the operating system
+)
+
+// ParseSyncPolicy validates a sync policy name.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case SyncAlways, SyncInterval, SyncNever:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unsupported spool sync policy: %s", s)
+	}
+}
+
+// Writer is the downstream store the spool protects.
+type Writer interface {
+	StoreEvent(ctx context.Context, event storage.Event) error
+	StoreEvents(ctx context.Context, events []storage.Event) ([]int, error)
+}
+
+// Config controls spool placement, durability and replay.
+type Config struct {
+	Dir            string        // Directory holding segment files
+	SegmentBytes   int64         // Size at which the active segment is sealed
+	Sync           SyncPolicy    // When appends are fsynced
+	SyncInterval   time.Duration // Period for SyncInterval
+	ReplayInterval time.Duration // How often replay is attempted
+	WriteTimeout   time.Duration // Budget for a direct database write before spooling
+}
+
+// Spool writes events straight to the database while it is healthy and
+// appends them to disk when it is not. While anything is spooled, new events
+// are appended behind it so replay preserves arrival order.
+type Spool struct {
+	writer  Writer
+	cfg     Config
+	metrics *metrics.Registry
+	obs     observability.Provider
+
+	gate sync.RWMutex // Shared by direct writes, held exclusively to spool
+
+	mu           sync.Mutex
+	sealed       []segment
+	active       *os.File
+	activeSeq    uint64
+	activeSize   int64
+	activeCount  int64
+	depth        int64
+	dirty        bool
+	replayOffset checkpoint
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open recovers any segments left in cfg.Dir and starts the replay loop.
+func Open(writer Writer, cfg Config, m *metrics.Registry, obs observability.Provider) (*Spool, error) {
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 64 << 20
+	}
+	if cfg.Sync == "" {
+		cfg.Sync = SyncInterval
+	}
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+	if cfg.ReplayInterval <= 0 {
+		cfg.ReplayInterval = 5 * time.Second
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = 2 * time.Second
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		writer:  writer,
+		cfg:     cfg,
+		metrics: m,
+		obs:     obs,
+		stop:    make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.replayLoop()
+	if cfg.Sync == SyncInterval {
+		s.wg.Add(1)
+		go s.syncLoop()
+	}
+
+	obs.Logger().Info("Spool opened",
+		slog.String("dir", cfg.Dir),
+		slog.Int64("depth", s.depth),
+		slog.Int("segments", len(s.sealed)),
+	)
+	return s, nil
+}
+
+// recover scans existing segments, seals them all and resumes from the
+// replay checkpoint.
+func (s *Spool) recover() error {
+	cp, err := readCheckpoint(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	seqs, err := listSegments(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("unable to list spool segments: %w", err)
+	}
+
+	var totalBytes int64
+	for _, seq := range seqs {
+		path := segmentPath(s.cfg.Dir, seq)
+		var offset int64
+		if seq == cp.Seq {
+			offset = cp.Offset
+		}
+		records, size, err := scanSegment(path, offset)
+		if err != nil {
+			return fmt.Errorf("unable to scan spool segment %s: %w", path, err)
+		}
+		s.sealed = append(s.sealed, segment{seq: seq, path: path, records: records, bytes: size})
+		s.depth += records
+		totalBytes += size
+		s.activeSeq = seq
+	}
+	if len(seqs) > 0 && cp.Seq >= seqs[0] {
+		s.replayOffset = cp
+	}
+	s.activeSeq++
+
+	ctx := context.Background()
+	s.metrics.SpoolDepth.Add(ctx, s.depth)
+	s.metrics.SpoolBytes.Add(ctx, totalBytes)
+	return nil
+}
+
+// StoreEvent stores a single event; see StoreEvents. It returns
+// storage.ErrDuplicateEvent when the event is written directly and its
+// EventID is already stored; a spooled duplicate is skipped on replay.
+func (s *Spool) StoreEvent(ctx context.Context, event storage.Event) error {
+	return s.store(ctx, []storage.Event{event}, func(ctx context.Context) error {
+		return s.writer.StoreEvent(ctx, event)
+	})
+}
+
+// StoreEvents writes events to the database if nothing is spooled and the
+// write succeeds within WriteTimeout; otherwise it appends them to disk.
+// Direct writes run concurrently, but events are only spooled once none is
+// in flight, so no event reaches the database ahead of one spooled before
+// it. The indexes of duplicates are only returned for a direct write.
+func (s *Spool) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	var duplicates []int
+	err := s.store(ctx, events, func(ctx context.Context) error {
+		var err error
+		duplicates, err = s.writer.StoreEvents(ctx, events)
+		return err
+	})
+	return duplicates, err
+}
+
+// store tries write and spools events if it fails.
+func (s *Spool) store(ctx context.Context, events []storage.Event, write func(context.Context) error) error {
+	if done, err := s.storeDirect(ctx, len(events), write); done {
+		return err
+	}
+
+	s.gate.Lock()
+	defer s.gate.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.appendLocked(ctx, events)
+}
+
+// storeDirect writes events straight to the database unless something is
+// spooled, and reports whether it did, returning ErrDuplicateEvent from
+// write. Spooling takes the gate exclusively, so nothing is spooled while
+// the write is in flight.
+func (s *Spool) storeDirect(ctx context.Context, count int, write func(context.Context) error) (bool, error) {
+	s.gate.RLock()
+	defer s.gate.RUnlock()
+	if s.Depth() != 0 {
+		return false, nil
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, s.cfg.WriteTimeout)
+	err := write(writeCtx)
+	cancel()
+	if err == nil || errors.Is(err, storage.ErrDuplicateEvent) {
+		return true, err
+	}
+	s.metrics.DBErrorsTotal.Add(ctx, 1)
+	s.obs.Logger().Warn("Database write failed, spooling events",
+		slog.Int("count", count),
+		slog.Any("error", err),
+	)
+	return false, nil
+}
+
+// Depth returns the number of events waiting to be replayed.
+func (s *Spool) Depth() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
+
+// appendLocked must be called with s.mu held.
+func (s *Spool) appendLocked(ctx context.Context, events []storage.Event) error {
+	_, span := s.obs.Tracer().Start(ctx, "SpoolAppend",
+		trace.WithAttributes(attribute.Int("count", len(events))),
+	)
+	defer span.End()
+
+	now := time.Now().UTC()
+	var buf []byte
+	for _, event := range events {
+		var err error
+		if buf, err = appendRecord(buf, newRecord(event, now)); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	if s.active != nil && s.activeSize >= s.cfg.SegmentBytes {
+		if err := s.sealLocked(); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	if s.active == nil {
+		f, err := os.OpenFile(segmentPath(s.cfg.Dir, s.activeSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Failed to open spool segment")
+			return fmt.Errorf("unable to open spool segment: %w", err)
+		}
+		s.active = f
+	}
+
+	if _, err := s.active.Write(buf); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Failed to write spool segment")
+		return fmt.Errorf("unable to write spool segment: %w", err)
+	}
+	if s.cfg.Sync == SyncAlways {
+		if err := s.active.Sync(); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("unable to sync spool segment: %w", err)
+		}
+	} else {
+		s.dirty = true
+	}
+
+	s.activeSize += int64(len(buf))
+	s.activeCount += int64(len(events))
+	s.depth += int64(len(events))
+	s.metrics.SpoolDepth.Add(ctx, int64(len(events)))
+	s.metrics.SpoolBytes.Add(ctx, int64(len(buf)))
+	return nil
+}
+
+// sealLocked closes the active segment and queues it for replay.
+func (s *Spool) sealLocked() error {
+	if s.active == nil {
+		return nil
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("unable to sync spool segment: %w", err)
+	}
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("unable to close spool segment: %w", err)
+	}
+	s.sealed = append(s.sealed, segment{
+		seq:     s.activeSeq,
+		path:    segmentPath(s.cfg.Dir, s.activeSeq),
+		records: s.activeCount,
+		bytes:   s.activeSize,
+	})
+	s.active = nil
+	s.activeSeq++
+	s.activeSize = 0
+	s.activeCount = 0
+	s.dirty = false
+	return nil
+}
+
+func (s *Spool) syncLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty && s.active != nil {
+				if err := s.active.Sync(); err != nil {
+					s.obs.Logger().Error("Failed to sync spool segment", slog.Any("error", err))
+				} else {
+					s.dirty = false
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Spool) replayLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.ReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.replay(context.Background()); err != nil {
+				s.obs.Logger().Warn("Spool replay incomplete, will retry",
+					slog.Int64("depth", s.Depth()),
+					slog.Any("error", err),
+				)
+			}
+		}
+	}
+}
+
+// replay drains sealed segments, oldest first, into the database. The
+// active segment is sealed first so that everything spooled so far is
+// eligible. Replay stops at the first failed write and resumes from the
+// checkpoint on the next attempt.
+func (s *Spool) replay(ctx context.Context) error {
+	s.mu.Lock()
+	if len(s.sealed) == 0 && s.activeCount > 0 {
+		if err := s.sealLocked(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	if len(s.sealed) == 0 {
+		s.mu.Unlock()
+		s.metrics.SpoolReplayLag.Record(ctx, 0)
+		return nil
+	}
+	pending := append([]segment(nil), s.sealed...)
+	s.mu.Unlock()
+
+	for _, seg := range pending {
+		if err := s.replaySegment(ctx, seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Spool) replaySegment(ctx context.Context, seg segment) error {
+	ctx, span := s.obs.Tracer().Start(ctx, "SpoolReplay",
+		trace.WithAttributes(attribute.Int64("segment", int64(seg.seq))),
+	)
+	defer span.End()
+
+	f, err := os.Open(seg.path)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("unable to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	var offset int64
+	if s.replayOffset.Seq == seg.seq {
+		offset = s.replayOffset.Offset
+	}
+	s.mu.Unlock()
+
+	sr, err := newSegmentReader(f, offset)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	for {
+		batch := make([]storage.Event, 0, replayBatchSize)
+		var oldest time.Time
+		var batchBytes int64
+		next := offset
+		var readErr error
+		for len(batch) < replayBatchSize {
+			var rec record
+			rec, next, readErr = sr.next()
+			if readErr != nil {
+				break
+			}
+			if oldest.IsZero() {
+				oldest = rec.SpooledAt
+			}
+			batch = append(batch, rec.event())
+			batchBytes = next - offset
+		}
+
+		if len(batch) > 0 {
+			s.metrics.SpoolReplayLag.Record(ctx, time.Since(oldest).Seconds())
+			duplicates, err := s.writer.StoreEvents(ctx, batch)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "Spool replay failed")
+				return fmt.Errorf("unable to replay spooled events: %w", err)
+			}
+			offset = next
+			if err := s.advance(ctx, seg.seq, offset, int64(len(batch)), batchBytes); err != nil {
+				span.RecordError(err)
+				return err
+			}
+			s.metrics.EventsStoredTotal.Add(ctx, int64(len(batch)-len(duplicates)))
+		}
+
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				s.obs.Logger().Error("Discarding unreadable tail of spool segment",
+					slog.String("segment", seg.path),
+					slog.Int64("offset", offset),
+				)
+			}
+			return s.finishSegment(ctx, seg)
+		}
+	}
+}
+
+// advance persists replay progress within a segment.
+func (s *Spool) advance(ctx context.Context, seq uint64, offset, records, bytes int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replayOffset = checkpoint{Seq: seq, Offset: offset}
+	s.depth -= records
+	s.metrics.SpoolDepth.Add(ctx, -records)
+	s.metrics.SpoolBytes.Add(ctx, -bytes)
+	if err := writeCheckpoint(s.cfg.Dir, s.replayOffset); err != nil {
+		return fmt.Errorf("unable to write spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// finishSegment removes a fully replayed segment.
+func (s *Spool) finishSegment(ctx context.Context, seg segment) error {
+	if err := os.Remove(seg.path); err != nil {
+		return fmt.Errorf("unable to remove spool segment: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.sealed {
+		if s.sealed[i].seq == seg.seq {
+			s.sealed = append(s.sealed[:i], s.sealed[i+1:]...)
+			break
+		}
+	}
+	s.replayOffset = checkpoint{}
+	if len(s.sealed) == 0 && s.activeCount == 0 {
+		s.metrics.SpoolReplayLag.Record(ctx, 0)
+	}
+	s.obs.Logger().Info("Spool segment replayed", slog.String("segment", seg.path))
+	return removeCheckpoint(s.cfg.Dir)
+}
+
+// Close stops replay and syncs the active segment. Events still spooled
+// are replayed the next time the spool is opened.
+func (s *Spool) Close() {
+	close(s.stop)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		if err := s.active.Sync(); err != nil {
+			s.obs.Logger().Error("Failed to sync spool segment", slog.Any("error", err))
+		}
+		if err := s.active.Close(); err != nil {
+			s.obs.Logger().Error("Failed to close spool segment", slog.Any("error", err))
+		}
+		s.active = nil
+	}
+	s.obs.Logger().Info("Spool closed", slog.Int64("depth", s.depth))
+}
@@ -0,0 +1,198 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/metrics"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+type flakyWriter struct {
+	mu     sync.Mutex
+	down   bool
+	stored []storage.Event
+}
+
+func (w *flakyWriter) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return nil, errors.New("database unavailable")
+	}
+	w.stored = append(w.stored, events...)
+	return nil, nil
+}
+
+func (w *flakyWriter) StoreEvent(ctx context.Context, event storage.Event) error {
+	_, err := w.StoreEvents(ctx, []storage.Event{event})
+	return err
+}
+
+func (w *flakyWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+func openTestSpool(t *testing.T, dir string, w Writer) *Spool {
+	t.Helper()
+	obs, _ := observability.InitObservability("noop")
+	reg, err := metrics.NewRegistry(obs.Meter())
+	be.NilErr(t, err)
+	s, err := Open(w, Config{
+		Dir:            dir,
+		SegmentBytes:   256,
+		Sync:           SyncAlways,
+		ReplayInterval: time.Hour,
+	}, reg, obs)
+	be.NilErr(t, err)
+	return s
+}
+
+func TestSpool_ReplaysInOrderAfterRecovery(t *testing.T) {
+	w := &flakyWriter{down: true}
+	s := openTestSpool(t, t.TempDir(), w)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, eventType := range []string{"a", "b", "c", "d", "e"} {
+		be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: eventType}))
+	}
+	be.Equal(t, int64(5), s.Depth())
+	be.Equal(t, 0, len(w.stored))
+
+	be.True(t, s.replay(ctx) != nil)
+
+	w.setDown(false)
+	// Spooled events must drain before new events go straight to the database.
+	be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: "f"}))
+	be.Equal(t, 0, len(w.stored))
+
+	be.NilErr(t, s.replay(ctx))
+	be.NilErr(t, s.replay(ctx))
+	be.Equal(t, int64(0), s.Depth())
+
+	var got string
+	for _, event := range w.stored {
+		got += event.EventType
+	}
+	be.Equal(t, "abcdef", got)
+
+	be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: "g"}))
+	be.Equal(t, 7, len(w.stored))
+}
+
+// gatedWriter holds the first write of each event type in release until
+// its channel is closed, and fails the first write of the types in fail.
+type gatedWriter struct {
+	flakyWriter
+	entered chan string
+	release map[string]chan struct{}
+	fail    map[string]bool
+}
+
+func (w *gatedWriter) StoreEvents(ctx context.Context, events []storage.Event) ([]int, error) {
+	eventType := events[0].EventType
+	w.entered <- eventType
+	w.mu.Lock()
+	release, fail := w.release[eventType], w.fail[eventType]
+	delete(w.release, eventType)
+	delete(w.fail, eventType)
+	w.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+	if fail {
+		return nil, errors.New("database timed out")
+	}
+	return w.flakyWriter.StoreEvents(ctx, events)
+}
+
+func (w *gatedWriter) StoreEvent(ctx context.Context, event storage.Event) error {
+	_, err := w.StoreEvents(ctx, []storage.Event{event})
+	return err
+}
+
+func TestSpool_SpoolsBehindWritesInFlight(t *testing.T) {
+	releaseA, releaseB := make(chan struct{}), make(chan struct{})
+	w := &gatedWriter{
+		entered: make(chan string, 4),
+		release: map[string]chan struct{}{"a": releaseA, "b": releaseB},
+		fail:    map[string]bool{"a": true},
+	}
+	s := openTestSpool(t, t.TempDir(), w)
+	defer s.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, eventType := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: eventType}))
+		}()
+	}
+	// Slow writes run side by side rather than one at a time.
+	for range 2 {
+		select {
+		case <-w.entered:
+		case <-time.After(time.Second):
+			t.Fatal("direct writes did not run concurrently")
+		}
+	}
+
+	// a fails, but is not spooled while b is still being written.
+	close(releaseA)
+	time.Sleep(20 * time.Millisecond)
+	be.Equal(t, int64(0), s.Depth())
+	close(releaseB)
+	wg.Wait()
+	be.Equal(t, int64(1), s.Depth())
+
+	// Once a is spooled, c goes behind it.
+	be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: "c"}))
+	be.Equal(t, int64(2), s.Depth())
+	be.NilErr(t, s.replay(ctx))
+
+	var got string
+	for _, event := range w.stored {
+		got += event.EventType
+	}
+	be.Equal(t, "bac", got)
+}
+
+func TestSpool_RecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	w := &flakyWriter{down: true}
+	ctx := context.Background()
+
+	s := openTestSpool(t, dir, w)
+	_, err := s.StoreEvents(ctx, []storage.Event{{EventType: "a"}, {EventType: "b"}})
+	be.NilErr(t, err)
+	s.Close()
+
+	// Simulate a torn write at the tail of the last segment.
+	seqs, err := listSegments(dir)
+	be.NilErr(t, err)
+	f, err := os.OpenFile(segmentPath(dir, seqs[len(seqs)-1]), os.O_WRONLY|os.O_APPEND, 0)
+	be.NilErr(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 42, 1, 2})
+	be.NilErr(t, err)
+	be.NilErr(t, f.Close())
+
+	s = openTestSpool(t, dir, w)
+	defer s.Close()
+	be.Equal(t, int64(2), s.Depth())
+
+	w.setDown(false)
+	be.NilErr(t, s.replay(ctx))
+	be.Equal(t, int64(0), s.Depth())
+	be.Equal(t, 2, len(w.stored))
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrKeyNotFound is returned when no API key matches an ID or hash.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// APIKey is a stored API key. Only a hash of the key is kept; Prefix holds
+// its first characters so that operators can tell keys apart.
+type APIKey struct {
+	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	Name      string     `json:"name,omitempty"`
+	Prefix    string     `json:"prefix"`
+	Hash      []byte     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Set when created with a lifetime or rotated
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key authenticates at now.
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// KeyStore persists API keys.
+type KeyStore interface {
+	CreateAPIKey(ctx context.Context, key APIKey) error
+	// GetAPIKey and LookupAPIKey return ErrKeyNotFound when no key matches.
+	GetAPIKey(ctx context.Context, id string) (APIKey, error)
+	LookupAPIKey(ctx context.Context, hash []byte) (APIKey, error)
+	// ListAPIKeys returns the keys of tenantID, or of every tenant when it
+	// is empty, oldest first.
+	ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error)
+	// ExpireAPIKey sets the key to expire at at, unless it already expires
+	// earlier. RevokeAPIKey marks it revoked at at, unless it already is.
+	// Both return ErrKeyNotFound for an unknown ID.
+	ExpireAPIKey(ctx context.Context, id string, at time.Time) error
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// The helpers in this file evaluate queries in process, for backends that
+// cannot push JSONB containment or timezone-aware bucketing down to the
+// database. They follow PostgreSQL's semantics for @>, #>> and date_trunc.
+
+// matchesQuery reports whether e passes every filter in q, including the
+// keyset position. contains is the decoded q.DataContains, or nil.
+func matchesQuery(q EventQuery, contains any, e StoredEvent) bool {
+	if e.TenantID != q.TenantID {
+		return false
+	}
+	if len(q.EventTypes) > 0 && !slices.Contains(q.EventTypes, e.EventType) {
+		return false
+	}
+	if !inRange(e.Timestamp, q.Since, q.Until) || !inRange(e.ReceivedAt, q.ReceivedSince, q.ReceivedUntil) {
+		return false
+	}
+	if q.Quarantined != nil && *q.Quarantined != e.Quarantined {
+		return false
+	}
+	if q.After != nil && !beforeCursor(e, *q.After) {
+		return false
+	}
+	return contains == nil || jsonContains(decodeJSON(e.Data), contains)
+}
+
+// inRange reports whether t is in [since, until); zero bounds are open.
+func inRange(t, since, until time.Time) bool {
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
+
+// beforeCursor reports whether e sorts after c in newest-first order.
+func beforeCursor(e StoredEvent, c Cursor) bool {
+	return e.Timestamp.Before(c.Timestamp) || (e.Timestamp.Equal(c.Timestamp) && e.ID < c.ID)
+}
+
+// sortNewestFirst orders events by (timestamp, id) descending.
+func sortNewestFirst(events []StoredEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.After(events[j].Timestamp)
+		}
+		return events[i].ID > events[j].ID
+	})
+}
+
+// decodeContains decodes a DataContains document, returning nil when unset.
+func decodeContains(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("invalid data filter: %w", err)
+	}
+	return v, nil
+}
+
+func decodeJSON(raw json.RawMessage) any {
+	var v any
+	if len(raw) == 0 || json.Unmarshal(raw, &v) != nil {
+		return nil
+	}
+	return v
+}
+
+// jsonContains implements JSONB containment (doc @> sub): objects match when
+// every key of sub is contained in doc, arrays when every element of sub is
+// contained in some element of doc, and scalars when they are equal. As in
+// PostgreSQL, an array also contains a bare scalar that is one of its elements.
+func jsonContains(doc, sub any) bool {
+	switch s := sub.(type) {
+	case map[string]any:
+		d, ok := doc.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, sv := range s {
+			dv, ok := d[k]
+			if !ok || !jsonContains(dv, sv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		d, ok := doc.([]any)
+		if !ok {
+			return false
+		}
+		for _, sv := range s {
+			if !slices.ContainsFunc(d, func(dv any) bool { return jsonContains(dv, sv) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		if d, ok := doc.([]any); ok {
+			return slices.ContainsFunc(d, func(dv any) bool { return reflect.DeepEqual(dv, sub) })
+		}
+		return reflect.DeepEqual(doc, sub)
+	}
+}
+
+// jsonPathText implements data #>> path: the value at path as text, with
+// strings unquoted, or nil when the path does not exist or holds null.
+func jsonPathText(data json.RawMessage, path []string) *string {
+	v := decodeJSON(data)
+	for _, key := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if v, ok = obj[key]; !ok {
+			return nil
+		}
+	}
+	var text string
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		text = t
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil
+		}
+		text = string(bytes.TrimSpace(b))
+	}
+	return &text
+}
+
+// truncateTime implements date_trunc(unit, t, zone) for minute, hour and day.
+func truncateTime(t time.Time, unit string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+	switch unit {
+	case "minute":
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, loc)
+	case "hour":
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, loc)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+}
+
+// aggregation accumulates AggregateRows in process.
+type aggregation struct {
+	q        AggregateQuery
+	loc      *time.Location
+	contains any
+	counts   map[aggregateKey]int64
+}
+
+type aggregateKey struct {
+	bucket    int64
+	eventType string
+	group     string
+	hasGroup  bool
+}
+
+func newAggregation(q AggregateQuery) (*aggregation, error) {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %w", err)
+	}
+	contains, err := decodeContains(q.DataContains)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregation{q: q, loc: loc, contains: contains, counts: make(map[aggregateKey]int64)}, nil
+}
+
+// add counts e if it falls inside the query's range and filters.
+func (a *aggregation) add(e StoredEvent) {
+	if e.TenantID != a.q.TenantID {
+		return
+	}
+	if !inRange(e.Timestamp, a.q.Since, a.q.Until) {
+		return
+	}
+	if len(a.q.EventTypes) > 0 && !slices.Contains(a.q.EventTypes, e.EventType) {
+		return
+	}
+	if a.contains != nil && !jsonContains(decodeJSON(e.Data), a.contains) {
+		return
+	}
+	k := aggregateKey{
+		bucket:    truncateTime(e.Timestamp, a.q.Bucket, a.loc).UnixMicro(),
+		eventType: e.EventType,
+	}
+	if len(a.q.GroupByPath) > 0 {
+		if g := jsonPathText(e.Data, a.q.GroupByPath); g != nil {
+			k.group, k.hasGroup = *g, true
+		}
+	}
+	a.counts[k]++
+}
+
+// rows returns the counts ordered by bucket, event type and group, with
+// null groups last as in PostgreSQL's default ascending order.
+func (a *aggregation) rows() []AggregateRow {
+	keys := make([]aggregateKey, 0, len(a.counts))
+	for k := range a.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.bucket != kj.bucket {
+			return ki.bucket < kj.bucket
+		}
+		if ki.eventType != kj.eventType {
+			return ki.eventType < kj.eventType
+		}
+		if ki.hasGroup != kj.hasGroup {
+			return ki.hasGroup
+		}
+		return ki.group < kj.group
+	})
+	rows := make([]AggregateRow, len(keys))
+	for i, k := range keys {
+		rows[i] = AggregateRow{Bucket: time.UnixMicro(k.bucket).UTC(), EventType: k.eventType, Count: a.counts[k]}
+		if k.hasGroup {
+			group := k.group
+			rows[i].Group = &group
+		}
+	}
+	return rows
+}
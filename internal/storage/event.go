@@ -2,12 +2,36 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrDuplicateEvent is returned when an event with the same EventID has
+// already been stored.
+var ErrDuplicateEvent = errors.New("duplicate event")
+
+// DefaultTenant owns events ingested while API key authentication is
+// disabled, including those stored before it existed.
+const DefaultTenant = "default"
+
 // Event represents the structure of the telemetry data we expect and store.
 type Event struct {
+	EventID   string          `json:"event_id,omitempty"` // Optional client-supplied UUID or ULID used for deduplication
 	EventType string          `json:"event_type"`
 	Timestamp time.Time       `json:"timestamp"` // Expect ISO 8601 format
 	Data      json.RawMessage `json:"data"`      // Store arbitrary JSON
+
+	SchemaVersion int  `json:"schema_version,omitempty"` // Schema version data conforms to; latest when omitted
+	Quarantined   bool `json:"-"`                        // Set by ingestion when the event type has no schema
+
+	TenantID string `json:"-"` // Set by ingestion from the authenticated API key
+
+	// CloudEvents context attributes, set by ingestion for CloudEvents.
+	Source        string `json:"-"` // source: the context the event happened in
+	SourceEventID string `json:"-"` // id: unique within Source
+	Subject       string `json:"-"` // subject: what the event is about within Source
+
+	// Segment identities, set by ingestion for Segment messages.
+	UserID      string `json:"-"` // userId: the known user
+	AnonymousID string `json:"-"` // anonymousId: the device or session before the user is known
 }
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+)
+
+func init() {
+	Register("memory", func(ctx context.Context, dsn string, obs observability.Provider) (Store, error) {
+		return NewMemoryStore(), nil
+	})
+}
+
+// MemoryStore implements Store in process memory. Events are lost on
+// restart; it is intended for development and tests.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	events   []StoredEvent
+	eventIDs map[tenantEventID]time.Time // When each ID was first stored
+	nextID   int64
+	keys     []APIKey
+	secrets  []SigningSecret
+	usage    map[tenantDay]int64
+}
+
+// tenantEventID is the scope of event ID deduplication.
+type tenantEventID struct {
+	tenantID, eventID string
+}
+
+// tenantDay keys daily usage counts.
+type tenantDay struct {
+	tenantID, day string
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{eventIDs: make(map[tenantEventID]time.Time), nextID: 1, usage: make(map[tenantDay]int64)}
+}
+
+// StoreEvent stores a single event.
+func (s *MemoryStore) StoreEvent(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.insert(event, time.Now().UTC()) {
+		return ErrDuplicateEvent
+	}
+	return nil
+}
+
+// StoreEvents stores a batch, skipping duplicate EventIDs.
+func (s *MemoryStore) StoreEvents(ctx context.Context, events []Event) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	var duplicates []int
+	for i, event := range events {
+		if !s.insert(event, now) {
+			duplicates = append(duplicates, i)
+		}
+	}
+	return duplicates, nil
+}
+
+// insert must be called with s.mu held. It reports false for a duplicate.
+func (s *MemoryStore) insert(event Event, now time.Time) bool {
+	if event.EventID != "" {
+		id := tenantEventID{event.TenantID, event.EventID}
+		if _, dup := s.eventIDs[id]; dup {
+			return false
+		}
+		s.eventIDs[id] = now
+	}
+	ts := now
+	if !event.Timestamp.IsZero() {
+		ts = event.Timestamp.UTC()
+	}
+	// Match PostgreSQL's microsecond timestamp precision so cursors behave alike.
+	s.events = append(s.events, StoredEvent{
+		ID:            s.nextID,
+		EventID:       event.EventID,
+		EventType:     event.EventType,
+		Timestamp:     ts.Truncate(time.Microsecond),
+		Data:          slices.Clone(event.Data),
+		SchemaVersion: event.SchemaVersion,
+		Quarantined:   event.Quarantined,
+		ReceivedAt:    now.Truncate(time.Microsecond),
+		TenantID:      event.TenantID,
+		Source:        event.Source,
+		SourceEventID: event.SourceEventID,
+		Subject:       event.Subject,
+		UserID:        event.UserID,
+		AnonymousID:   event.AnonymousID,
+	})
+	s.nextID++
+	return true
+}
+
+// QueryEvents returns one page of events matching q, newest first.
+func (s *MemoryStore) QueryEvents(ctx context.Context, q EventQuery) (EventPage, error) {
+	contains, err := decodeContains(q.DataContains)
+	if err != nil {
+		return EventPage{}, err
+	}
+
+	s.mu.RLock()
+	var matched []StoredEvent
+	for _, e := range s.events {
+		if matchesQuery(q, contains, e) {
+			matched = append(matched, e)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortNewestFirst(matched)
+	page := EventPage{Events: make([]StoredEvent, 0, min(len(matched), q.Limit))}
+	if len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+		last := matched[q.Limit-1]
+		page.NextCursor = &Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	page.Events = append(page.Events, matched...)
+	return page, nil
+}
+
+// AggregateEvents counts events grouped by event type and time bucket.
+func (s *MemoryStore) AggregateEvents(ctx context.Context, q AggregateQuery) ([]AggregateRow, error) {
+	agg, err := newAggregation(q)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	for _, e := range s.events {
+		agg.add(e)
+	}
+	s.mu.RUnlock()
+	return agg.rows(), nil
+}
+
+// PurgeEvents deletes up to f.Limit events matching f.
+func (s *MemoryStore) PurgeEvents(ctx context.Context, f PurgeFilter) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged int64
+	s.events = slices.DeleteFunc(s.events, func(e StoredEvent) bool {
+		if purged >= int64(f.Limit) || !f.matches(e) {
+			return false
+		}
+		purged++
+		return true
+	})
+	return purged, nil
+}
+
+// DropPartitionsBefore is a no-op; MemoryStore is not partitioned.
+func (s *MemoryStore) DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// PurgeEventIDs forgets up to limit event IDs first stored before cutoff.
+func (s *MemoryStore) PurgeEventIDs(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged int64
+	for id, created := range s.eventIDs {
+		if purged >= int64(limit) {
+			break
+		}
+		if created.Before(cutoff) {
+			delete(s.eventIDs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// CreateAPIKey stores a new API key.
+func (s *MemoryStore) CreateAPIKey(ctx context.Context, key APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.Hash = slices.Clone(key.Hash)
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// GetAPIKey returns the key with the given ID.
+func (s *MemoryStore) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return s.findKey(func(k APIKey) bool { return k.ID == id })
+}
+
+// LookupAPIKey returns the key with the given hash.
+func (s *MemoryStore) LookupAPIKey(ctx context.Context, hash []byte) (APIKey, error) {
+	return s.findKey(func(k APIKey) bool { return bytes.Equal(k.Hash, hash) })
+}
+
+func (s *MemoryStore) findKey(match func(APIKey) bool) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := slices.IndexFunc(s.keys, match); i >= 0 {
+		return s.keys[i], nil
+	}
+	return APIKey{}, ErrKeyNotFound
+}
+
+// ListAPIKeys returns the keys of tenantID, or every key when it is empty.
+func (s *MemoryStore) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []APIKey
+	for _, k := range s.keys {
+		if tenantID == "" || k.TenantID == tenantID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// ExpireAPIKey sets the key to expire at at, unless it already expires earlier.
+func (s *MemoryStore) ExpireAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateKey(id, func(k *APIKey) {
+		if k.ExpiresAt == nil || at.Before(*k.ExpiresAt) {
+			k.ExpiresAt = &at
+		}
+	})
+}
+
+// RevokeAPIKey marks the key revoked at at, unless it already is.
+func (s *MemoryStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateKey(id, func(k *APIKey) {
+		if k.RevokedAt == nil {
+			k.RevokedAt = &at
+		}
+	})
+}
+
+func (s *MemoryStore) updateKey(id string, update func(*APIKey)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.keys, func(k APIKey) bool { return k.ID == id })
+	if i < 0 {
+		return ErrKeyNotFound
+	}
+	update(&s.keys[i])
+	return nil
+}
+
+// CreateSigningSecret stores a new signing secret.
+func (s *MemoryStore) CreateSigningSecret(ctx context.Context, secret SigningSecret) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets = append(s.secrets, secret)
+	return nil
+}
+
+// GetSigningSecret returns the secret with the given ID.
+func (s *MemoryStore) GetSigningSecret(ctx context.Context, id string) (SigningSecret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := slices.IndexFunc(s.secrets, func(sec SigningSecret) bool { return sec.ID == id }); i >= 0 {
+		return s.secrets[i], nil
+	}
+	return SigningSecret{}, ErrSecretNotFound
+}
+
+// ListSigningSecrets returns the secrets of tenantID, or every secret when
+// it is empty.
+func (s *MemoryStore) ListSigningSecrets(ctx context.Context, tenantID string) ([]SigningSecret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var secrets []SigningSecret
+	for _, sec := range s.secrets {
+		if tenantID == "" || sec.TenantID == tenantID {
+			secrets = append(secrets, sec)
+		}
+	}
+	return secrets, nil
+}
+
+// ExpireSigningSecret sets the secret to expire at at, unless it already
+// expires earlier.
+func (s *MemoryStore) ExpireSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSecret(id, func(sec *SigningSecret) {
+		if sec.ExpiresAt == nil || at.Before(*sec.ExpiresAt) {
+			sec.ExpiresAt = &at
+		}
+	})
+}
+
+// RevokeSigningSecret marks the secret revoked at at, unless it already is.
+func (s *MemoryStore) RevokeSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSecret(id, func(sec *SigningSecret) {
+		if sec.RevokedAt == nil {
+			sec.RevokedAt = &at
+		}
+	})
+}
+
+func (s *MemoryStore) updateSecret(id string, update func(*SigningSecret)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.secrets, func(sec SigningSecret) bool { return sec.ID == id })
+	if i < 0 {
+		return ErrSecretNotFound
+	}
+	update(&s.secrets[i])
+	return nil
+}
+
+// AddUsage adds counts to the tenants' totals for day.
+func (s *MemoryStore) AddUsage(ctx context.Context, day time.Time, counts map[string]int64) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals := make(map[string]int64, len(counts))
+	for tenantID, n := range counts {
+		key := tenantDay{tenantID, day.UTC().Format(time.DateOnly)}
+		s.usage[key] += n
+		totals[tenantID] = s.usage[key]
+	}
+	return totals, nil
+}
+
+// Ping always succeeds.
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op.
+func (s *MemoryStore) Close() {}
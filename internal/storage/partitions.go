@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// partitionLockKey serializes partition maintenance across replicas.
+const partitionLockKey int64 = 0x74656c656d7074 // "telempt"
+
+// PartitionInterval is the time range covered by one events partition.
+type PartitionInterval string
+
+const (
+	PartitionDaily   PartitionInterval = "day"
+	PartitionMonthly PartitionInterval = "month"
+)
+
+// ParsePartitionInterval validates a partition interval name.
+func ParsePartitionInterval(s string) (PartitionInterval, error) {
+	switch i := PartitionInterval(s); i {
+	case PartitionDaily, PartitionMonthly:
+		return i, nil
+	default:
+		return "", fmt.Errorf("unsupported partition interval: %s", s)
+	}
+}
+
+// PartitionConfig controls partition maintenance.
+type PartitionConfig struct {
+	Interval      PartitionInterval
+	Premake       int           // Number of future partitions kept ready
+	CheckInterval time.Duration // How often partitions are checked
+}
+
+// partitionRange is the half-open range [From, To) of one partition.
+type partitionRange struct {
+	From, To time.Time
+}
+
+// Name returns the partition table name, e.g. events_p20240328 or events_p202403.
+func (r partitionRange) Name(interval PartitionInterval) string {
+	if interval == PartitionDaily {
+		return "events_p" + r.From.Format("20060102")
+	}
+	return "events_p" + r.From.Format("200601")
+}
+
+// partitionStart returns the start of the partition containing t, in UTC.
+func partitionStart(t time.Time, interval PartitionInterval) time.Time {
+	t = t.UTC()
+	if interval == PartitionDaily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextPartitionStart(start time.Time, interval PartitionInterval) time.Time {
+	if interval == PartitionDaily {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// wantedPartitions returns the partitions that should exist: the current
+// one, the next premake, and any older ones needed to hold rows that are
+// sitting in the default partition.
+func wantedPartitions(now time.Time, cfg PartitionConfig, backlog []time.Time) []partitionRange {
+	current := partitionStart(now, cfg.Interval)
+	horizon := current
+	for i := 0; i <= cfg.Premake; i++ {
+		horizon = nextPartitionStart(horizon, cfg.Interval)
+	}
+
+	seen := make(map[time.Time]bool)
+	var ranges []partitionRange
+	add := func(start time.Time) {
+		if seen[start] || !start.Before(horizon) {
+			return
+		}
+		seen[start] = true
+		ranges = append(ranges, partitionRange{From: start, To: nextPartitionStart(start, cfg.Interval)})
+	}
+	for _, t := range backlog {
+		add(partitionStart(t, cfg.Interval))
+	}
+	for start := current; start.Before(horizon); start = nextPartitionStart(start, cfg.Interval) {
+		add(start)
+	}
+	return ranges
+}
+
+// PartitionManager keeps the partitions of the events table ahead of the
+// clock. Rows that arrived in the default partition, because no covering
+// partition existed yet, are moved into the partition created for them.
+type PartitionManager struct {
+	pool *pgxpool.Pool
+	cfg  PartitionConfig
+	obs  observability.Provider
+}
+
+// PartitionManager returns a manager for the store's events table.
+func (s *PostgresStore) PartitionManager(cfg PartitionConfig) *PartitionManager {
+	return &PartitionManager{pool: s.pool, cfg: cfg, obs: s.obs}
+}
+
+// Run ensures partitions immediately and then every CheckInterval until ctx
+// is cancelled. Failures are logged and retried on the next tick.
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Ensure(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			m.obs.Logger().Error("Partition maintenance failed", slog.Any("error", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ensure creates any missing partitions and returns the names it created.
+// It does nothing if another replica holds the maintenance lock or if the
+// events table is not partitioned (migrations not yet applied).
+func (m *PartitionManager) Ensure(ctx context.Context, now time.Time) ([]string, error) {
+	ctx, span := m.obs.Tracer().Start(ctx, "EnsurePartitions")
+	defer span.End()
+
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, partitionLockKey).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("unable to acquire partition lock: %w", err)
+	}
+	if !locked {
+		return nil, nil
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, partitionLockKey)
+	}()
+
+	var partitioned bool
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('events'))`).Scan(&partitioned)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect events table: %w", err)
+	}
+	if !partitioned {
+		m.obs.Logger().Warn("Events table is not partitioned; run migrations to enable partition management")
+		return nil, nil
+	}
+
+	backlog, err := m.defaultBacklog(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, r := range wantedPartitions(now, m.cfg, backlog) {
+		ok, err := m.createPartition(ctx, conn, r)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Partition creation failed")
+			return created, err
+		}
+		if ok {
+			name := r.Name(m.cfg.Interval)
+			m.obs.Logger().Info("Created events partition", slog.String("partition", name),
+				slog.Time("from", r.From), slog.Time("to", r.To))
+			created = append(created, name)
+		}
+	}
+	span.SetAttributes(attribute.Int("partitions_created", len(created)))
+	return created, nil
+}
+
+// defaultBacklog returns the partition starts of rows in the default partition.
+func (m *PartitionManager) defaultBacklog(ctx context.Context, conn *pgxpool.Conn) ([]time.Time, error) {
+	rows, err := conn.Query(ctx,
+		`SELECT DISTINCT date_trunc($1, timestamp AT TIME ZONE 'UTC') FROM events_default`,
+		string(m.cfg.Interval))
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect default partition: %w", err)
+	}
+	// timestamp without time zone scans as a UTC time.Time.
+	starts, err := pgx.CollectRows(rows, pgx.RowTo[time.Time])
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect default partition: %w", err)
+	}
+	return starts, nil
+}
+
+// createPartition creates the partition for r unless it already exists. Rows
+// for r that are in the default partition are moved into it in the same
+// transaction. A range that overlaps a partition of a different interval is
+// skipped, so changing PARTITION_INTERVAL only affects new ranges.
+func (m *PartitionManager) createPartition(ctx context.Context, conn *pgxpool.Conn, r partitionRange) (bool, error) {
+	name := r.Name(m.cfg.Interval)
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+		return false, fmt.Errorf("unable to check partition %s: %w", name, err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	from := r.From.Format("2006-01-02 15:04:05Z07:00")
+	to := r.To.Format("2006-01-02 15:04:05Z07:00")
+	ident := pgx.Identifier{name}.Sanitize()
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// Build the partition standalone, fill it from the default partition,
+		// then attach it; creating it directly as a partition would fail
+		// while the default partition holds rows in its range.
+		stmts := []string{
+			`CREATE TABLE ` + ident + ` (LIKE events INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`,
+			`WITH moved AS (
+				DELETE FROM events_default WHERE timestamp >= '` + from + `' AND timestamp < '` + to + `'
+				RETURNING *
+			)
+			INSERT INTO ` + ident + ` SELECT * FROM moved`,
+			`ALTER TABLE events ATTACH PARTITION ` + ident + ` FOR VALUES FROM ('` + from + `') TO ('` + to + `')`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P17" { // invalid_object_definition: overlapping partition
+		m.obs.Logger().Warn("Skipping partition that overlaps an existing one", slog.String("partition", name))
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to create partition %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// parsePartitionName recovers the range of a partition named by partitionRange.Name.
+func parsePartitionName(name string) (partitionRange, bool) {
+	suffix, ok := strings.CutPrefix(name, "events_p")
+	if !ok {
+		return partitionRange{}, false
+	}
+	var interval PartitionInterval
+	var layout string
+	switch len(suffix) {
+	case len("20060102"):
+		interval, layout = PartitionDaily, "20060102"
+	case len("200601"):
+		interval, layout = PartitionMonthly, "200601"
+	default:
+		return partitionRange{}, false
+	}
+	from, err := time.Parse(layout, suffix)
+	if err != nil {
+		return partitionRange{}, false
+	}
+	return partitionRange{From: from, To: nextPartitionStart(from, interval)}, true
+}
+
+// DropPartitionsBefore drops events partitions whose whole range ends at or
+// before cutoff and returns the number of rows they held. It skips the run
+// if partition maintenance is in progress on another replica.
+func (s *PostgresStore) DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "DropPartitionsBefore")
+	defer span.End()
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, partitionLockKey).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("unable to acquire partition lock: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, partitionLockKey)
+	}()
+
+	rows, err := conn.Query(ctx, `SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass('events')`)
+	if err != nil {
+		return 0, fmt.Errorf("unable to list partitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("unable to list partitions: %w", err)
+	}
+
+	var purged int64
+	dropped := 0
+	for _, name := range names {
+		r, ok := parsePartitionName(name)
+		if !ok || r.To.After(cutoff) {
+			continue
+		}
+		ident := pgx.Identifier{name}.Sanitize()
+		var count int64
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if err := tx.QueryRow(ctx, `SELECT count(*) FROM `+ident).Scan(&count); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DROP TABLE `+ident)
+			return err
+		})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "Partition drop failed")
+			return purged, fmt.Errorf("unable to drop partition %s: %w", name, err)
+		}
+		s.obs.Logger().Info("Dropped expired events partition", slog.String("partition", name), slog.Int64("rows", count))
+		purged += count
+		dropped++
+	}
+
+	span.SetAttributes(attribute.Int("partitions_dropped", dropped), attribute.Int64("purged", purged))
+	return purged, nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+)
+
+func TestWantedPartitions(t *testing.T) {
+	now := time.Date(2024, 12, 30, 15, 0, 0, 0, time.FixedZone("UTC+9", 9*3600)) // 06:00 UTC
+
+	daily := wantedPartitions(now, PartitionConfig{Interval: PartitionDaily, Premake: 2}, nil)
+	var names []string
+	for _, r := range daily {
+		names = append(names, r.Name(PartitionDaily))
+	}
+	be.AllEqual(t, []string{"events_p20241230", "events_p20241231", "events_p20250101"}, names)
+	be.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), daily[2].To)
+
+	backlog := []time.Time{
+		time.Date(2024, 10, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC), // same month as above
+		time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),   // beyond the premake horizon
+	}
+	monthly := wantedPartitions(now, PartitionConfig{Interval: PartitionMonthly, Premake: 1}, backlog)
+	names = nil
+	for _, r := range monthly {
+		names = append(names, r.Name(PartitionMonthly))
+	}
+	be.AllEqual(t, []string{"events_p202410", "events_p202412", "events_p202501"}, names)
+}
+
+func TestParsePartitionInterval(t *testing.T) {
+	_, err := ParsePartitionInterval("week")
+	be.Nonzero(t, err)
+	interval, err := ParsePartitionInterval("day")
+	be.NilErr(t, err)
+	be.Equal(t, PartitionDaily, interval)
+}
+
+func TestParsePartitionName(t *testing.T) {
+	r, ok := parsePartitionName("events_p202402")
+	be.True(t, ok)
+	be.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), r.From)
+	be.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), r.To)
+
+	r, ok = parsePartitionName("events_p20240229")
+	be.True(t, ok)
+	be.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), r.To)
+
+	for _, name := range []string{"events_default", "events_p2024", "events_pabcdef", "other_p202402"} {
+		_, ok := parsePartitionName(name)
+		be.False(t, ok)
+	}
+}
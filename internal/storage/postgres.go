@@ -2,16 +2,26 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kakhavain/telemetry-tracker/internal/observability"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 )
 
-// PostgresStore implements the storer interface using PostgreSQL.
+func init() {
+	Register("postgres", func(ctx context.Context, dsn string, obs observability.Provider) (Store, error) {
+		return NewPostgresStore(ctx, dsn, obs)
+	})
+}
+
+// PostgresStore implements Store using PostgreSQL.
 type PostgresStore struct {
 	pool *pgxpool.Pool
 	obs  observability.Provider
@@ -45,7 +55,19 @@ func (s *PostgresStore) StoreEvent(ctx context.Context, event Event) error {
 	ctx, span := s.obs.Tracer().Start(ctx, "StoreEvent")
 	defer span.End()
 
-	query := `INSERT INTO events (event_type, timestamp, data) VALUES ($1, $2, $3)`
+	// events is partitioned, so event_id cannot carry a unique index there;
+	// claiming the ID in the event_ids ledger decides whether the row is new.
+	// IDs are claimed per tenant, so one tenant cannot shadow another's.
+	query := `WITH claimed AS (
+			INSERT INTO event_ids (tenant_id, event_id) SELECT $7::varchar, $1::varchar WHERE $1::varchar IS NOT NULL
+			ON CONFLICT DO NOTHING
+			RETURNING event_id
+		)
+		INSERT INTO events (event_id, event_type, timestamp, data, schema_version, quarantined, tenant_id, source, source_event_id, subject,
+			user_id, anonymous_id)
+		SELECT $1::varchar, $2::varchar, $3::timestamptz, $4::jsonb, $5::integer, $6::boolean, $7::varchar, $8::text, $9::text, $10::text,
+			$11::text, $12::text
+		WHERE $1::varchar IS NULL OR EXISTS (SELECT 1 FROM claimed)`
 
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now().UTC()
@@ -56,12 +78,21 @@ func (s *PostgresStore) StoreEvent(ctx context.Context, event Event) error {
 	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	cmdTag, err := s.pool.Exec(queryCtx, query, event.EventType, event.Timestamp, event.Data)
+	cmdTag, err := s.pool.Exec(queryCtx, query,
+		nullIfEmpty(event.EventID), event.EventType, event.Timestamp, event.Data,
+		nullIfZero(event.SchemaVersion), event.Quarantined, event.TenantID,
+		nullIfEmpty(event.Source), nullIfEmpty(event.SourceEventID), nullIfEmpty(event.Subject),
+		nullIfEmpty(event.UserID), nullIfEmpty(event.AnonymousID),
+	)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "DB insert failed")
 		return fmt.Errorf("unable to insert event: %w", err)
 	}
+	if cmdTag.RowsAffected() == 0 && event.EventID != "" {
+		span.SetAttributes(attribute.Bool("duplicate", true))
+		return ErrDuplicateEvent
+	}
 	if cmdTag.RowsAffected() != 1 {
 		err := fmt.Errorf("expected 1 row affected, got %d", cmdTag.RowsAffected())
 		span.RecordError(err)
@@ -77,9 +108,507 @@ func (s *PostgresStore) StoreEvent(ctx context.Context, event Event) error {
 	return nil
 }
 
+// StoreEvents inserts a batch of events with a single multi-row INSERT.
+// Either every event is stored or none is. Events whose EventID is already
+// stored are skipped rather than reported as errors, and their indexes are
+// returned.
+func (s *PostgresStore) StoreEvents(ctx context.Context, events []Event) ([]int, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "StoreEvents")
+	defer span.End()
+
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	// As in StoreEvent, event_ids decides which IDs are new. Only the first
+	// occurrence of an ID within the batch is inserted. A batch flushed by
+	// the write-behind queue may mix tenants. The skipped ordinals are
+	// selected back as zero-based indexes.
+	query := `WITH input AS (
+			SELECT * FROM unnest($1::varchar[], $2::varchar[], $3::timestamptz[], $4::jsonb[], $5::integer[], $6::boolean[], $7::varchar[],
+					$8::text[], $9::text[], $10::text[], $11::text[], $12::text[])
+				WITH ORDINALITY AS t(event_id, event_type, timestamp, data, schema_version, quarantined, tenant_id,
+					source, source_event_id, subject, user_id, anonymous_id, ord)
+		), claimed AS (
+			INSERT INTO event_ids (tenant_id, event_id) SELECT tenant_id, event_id FROM input WHERE event_id IS NOT NULL
+			ON CONFLICT DO NOTHING
+			RETURNING tenant_id, event_id
+		), first_seen AS (
+			SELECT DISTINCT ON (tenant_id, event_id) ord FROM input WHERE event_id IS NOT NULL ORDER BY tenant_id, event_id, ord
+		), stored AS (
+			INSERT INTO events (event_id, event_type, timestamp, data, schema_version, quarantined, tenant_id, source, source_event_id,
+				subject, user_id, anonymous_id)
+			SELECT event_id, event_type, timestamp, data, schema_version, quarantined, tenant_id, source, source_event_id, subject,
+				user_id, anonymous_id FROM input
+			WHERE event_id IS NULL
+				OR ((tenant_id, event_id) IN (SELECT tenant_id, event_id FROM claimed) AND ord IN (SELECT ord FROM first_seen))
+		)
+		SELECT ord - 1 FROM input
+		WHERE event_id IS NOT NULL
+			AND NOT ((tenant_id, event_id) IN (SELECT tenant_id, event_id FROM claimed) AND ord IN (SELECT ord FROM first_seen))
+		ORDER BY ord`
+
+	eventIDs := make([]*string, len(events))
+	eventTypes := make([]string, len(events))
+	timestamps := make([]time.Time, len(events))
+	data := make([]json.RawMessage, len(events))
+	schemaVersions := make([]*int, len(events))
+	quarantined := make([]bool, len(events))
+	tenantIDs := make([]string, len(events))
+	sources := make([]*string, len(events))
+	sourceEventIDs := make([]*string, len(events))
+	subjects := make([]*string, len(events))
+	userIDs := make([]*string, len(events))
+	anonymousIDs := make([]*string, len(events))
+	now := time.Now().UTC()
+	for i, event := range events {
+		eventIDs[i] = nullIfEmpty(event.EventID)
+		eventTypes[i] = event.EventType
+		if event.Timestamp.IsZero() {
+			timestamps[i] = now
+		} else {
+			timestamps[i] = event.Timestamp.UTC()
+		}
+		data[i] = event.Data
+		schemaVersions[i] = nullIfZero(event.SchemaVersion)
+		quarantined[i] = event.Quarantined
+		tenantIDs[i] = event.TenantID
+		sources[i] = nullIfEmpty(event.Source)
+		sourceEventIDs[i] = nullIfEmpty(event.SourceEventID)
+		subjects[i] = nullIfEmpty(event.Subject)
+		userIDs[i] = nullIfEmpty(event.UserID)
+		anonymousIDs[i] = nullIfEmpty(event.AnonymousID)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, _ := s.pool.Query(queryCtx, query, eventIDs, eventTypes, timestamps, data, schemaVersions, quarantined, tenantIDs,
+		sources, sourceEventIDs, subjects, userIDs, anonymousIDs)
+	duplicates, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB batch insert failed")
+		return nil, fmt.Errorf("unable to insert events: %w", err)
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch_size", len(events)),
+		attribute.Int("duplicates", len(duplicates)),
+	)
+
+	return duplicates, nil
+}
+
+// nullIfEmpty maps an empty string to SQL NULL.
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// deref maps a NULL string column to "".
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// nullIfZero maps a zero integer to SQL NULL.
+func nullIfZero(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+// Ping acquires a pooled connection and round-trips to the server.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
 // Close closes the database connection pool.
 func (s *PostgresStore) Close() {
 	s.obs.Logger().Info("Closing PostgreSQL connection pool")
 	s.pool.Close()
 	s.obs.Logger().Info("PostgreSQL connection pool closed")
 }
+
+// QueryEvents returns one page of events matching q, newest first. Paging
+// is keyset-based on (timestamp, id), so pages stay stable while new events
+// are being inserted.
+func (s *PostgresStore) QueryEvents(ctx context.Context, q EventQuery) (EventPage, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "QueryEvents")
+	defer span.End()
+
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where = append(where, "tenant_id = "+arg(q.TenantID))
+	if len(q.EventTypes) > 0 {
+		where = append(where, "event_type = ANY("+arg(q.EventTypes)+")")
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp >= "+arg(q.Since))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp < "+arg(q.Until))
+	}
+	if !q.ReceivedSince.IsZero() {
+		where = append(where, "received_at >= "+arg(q.ReceivedSince))
+	}
+	if !q.ReceivedUntil.IsZero() {
+		where = append(where, "received_at < "+arg(q.ReceivedUntil))
+	}
+	if len(q.DataContains) > 0 {
+		where = append(where, "data @> "+arg(q.DataContains)+"::jsonb")
+	}
+	if q.Quarantined != nil {
+		where = append(where, "quarantined = "+arg(*q.Quarantined))
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(timestamp, id) < (%s, %s)", arg(q.After.Timestamp), arg(q.After.ID)))
+	}
+
+	query := `SELECT id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at,
+			source, source_event_id, subject, user_id, anonymous_id FROM events
+		WHERE ` + strings.Join(where, " AND ")
+	// Fetch one extra row to learn whether another page follows.
+	query += " ORDER BY timestamp DESC, id DESC LIMIT " + arg(q.Limit+1)
+
+	queryCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(queryCtx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB query failed")
+		return EventPage{}, fmt.Errorf("unable to query events: %w", err)
+	}
+	defer rows.Close()
+
+	page := EventPage{Events: make([]StoredEvent, 0, q.Limit)}
+	for rows.Next() {
+		var (
+			e             StoredEvent
+			eventID       *string
+			schemaVersion *int
+			receivedAt    *time.Time
+			source        *string
+			sourceEventID *string
+			subject       *string
+			userID        *string
+			anonymousID   *string
+		)
+		if err := rows.Scan(&e.ID, &eventID, &e.EventType, &e.Timestamp, &e.Data, &schemaVersion, &e.Quarantined, &receivedAt,
+			&source, &sourceEventID, &subject, &userID, &anonymousID); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "DB scan failed")
+			return EventPage{}, fmt.Errorf("unable to scan event: %w", err)
+		}
+		if eventID != nil {
+			e.EventID = *eventID
+		}
+		if schemaVersion != nil {
+			e.SchemaVersion = *schemaVersion
+		}
+		if receivedAt != nil {
+			e.ReceivedAt = *receivedAt
+		}
+		e.Source = deref(source)
+		e.SourceEventID = deref(sourceEventID)
+		e.Subject = deref(subject)
+		e.UserID = deref(userID)
+		e.AnonymousID = deref(anonymousID)
+		e.TenantID = q.TenantID
+		page.Events = append(page.Events, e)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB query failed")
+		return EventPage{}, fmt.Errorf("unable to query events: %w", err)
+	}
+
+	if len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		last := page.Events[q.Limit-1]
+		page.NextCursor = &Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+
+	span.SetAttributes(attribute.Int("result_count", len(page.Events)))
+	return page, nil
+}
+
+// AggregateEvents counts events grouped by event type and time bucket,
+// and optionally by the text value at a path in data. Rows are ordered by
+// bucket, event type and group.
+func (s *PostgresStore) AggregateEvents(ctx context.Context, q AggregateQuery) ([]AggregateRow, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "AggregateEvents")
+	defer span.End()
+
+	args := []any{q.Bucket, q.Timezone, q.Since, q.Until, q.TenantID}
+	where := []string{"tenant_id = $5", "timestamp >= $3", "timestamp < $4"}
+	if len(q.EventTypes) > 0 {
+		args = append(args, q.EventTypes)
+		where = append(where, fmt.Sprintf("event_type = ANY($%d)", len(args)))
+	}
+	if len(q.DataContains) > 0 {
+		args = append(args, q.DataContains)
+		where = append(where, fmt.Sprintf("data @> $%d::jsonb", len(args)))
+	}
+	group := "NULL::text"
+	if len(q.GroupByPath) > 0 {
+		args = append(args, q.GroupByPath)
+		group = fmt.Sprintf("data #>> $%d::text[]", len(args))
+	}
+
+	query := `SELECT date_trunc($1, timestamp, $2) AS bucket, event_type, ` + group + ` AS grp, count(*)
+		FROM events
+		WHERE ` + strings.Join(where, " AND ") + `
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3`
+
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(queryCtx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB aggregate failed")
+		return nil, fmt.Errorf("unable to aggregate events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AggregateRow
+	for rows.Next() {
+		var row AggregateRow
+		if err := rows.Scan(&row.Bucket, &row.EventType, &row.Group, &row.Count); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "DB scan failed")
+			return nil, fmt.Errorf("unable to scan aggregate: %w", err)
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB aggregate failed")
+		return nil, fmt.Errorf("unable to aggregate events: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("bucket", q.Bucket), attribute.Int("result_count", len(result)))
+	return result, nil
+}
+
+// PurgeEvents deletes up to f.Limit events matching f, addressing rows by
+// their (timestamp, id) key so the delete works across partitions.
+func (s *PostgresStore) PurgeEvents(ctx context.Context, f PurgeFilter) (int64, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "PurgeEvents")
+	defer span.End()
+
+	typeFilter := "event_type = ANY($2)"
+	if f.Exclude {
+		typeFilter = "NOT (event_type = ANY($2))"
+	}
+	query := `DELETE FROM events WHERE (timestamp, id) IN (
+			SELECT timestamp, id FROM events WHERE timestamp < $1 AND ` + typeFilter + ` LIMIT $3
+		)`
+
+	queryCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	eventTypes := f.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	cmdTag, err := s.pool.Exec(queryCtx, query, f.Before, eventTypes, f.Limit)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB purge failed")
+		return 0, fmt.Errorf("unable to purge events: %w", err)
+	}
+	span.SetAttributes(attribute.Int64("purged", cmdTag.RowsAffected()))
+	return cmdTag.RowsAffected(), nil
+}
+
+// PurgeEventIDs deletes up to limit event_ids rows created before cutoff,
+// so the deduplication ledger does not outgrow the events it guards.
+func (s *PostgresStore) PurgeEventIDs(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "PurgeEventIDs")
+	defer span.End()
+
+	queryCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	cmdTag, err := s.pool.Exec(queryCtx, `DELETE FROM event_ids WHERE (tenant_id, event_id) IN (
+			SELECT tenant_id, event_id FROM event_ids WHERE created_at < $1 LIMIT $2
+		)`, cutoff, limit)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB purge failed")
+		return 0, fmt.Errorf("unable to purge event ids: %w", err)
+	}
+	span.SetAttributes(attribute.Int64("purged", cmdTag.RowsAffected()))
+	return cmdTag.RowsAffected(), nil
+}
+
+const pgAPIKeyColumns = `id, tenant_id, name, prefix, key_hash, created_at, expires_at, revoked_at`
+
+// CreateAPIKey stores a new API key.
+func (s *PostgresStore) CreateAPIKey(ctx context.Context, key APIKey) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO api_keys (`+pgAPIKeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.TenantID, key.Name, key.Prefix, key.Hash, key.CreatedAt, key.ExpiresAt, key.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("unable to insert api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns the key with the given ID.
+func (s *PostgresStore) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return s.queryAPIKey(ctx, `SELECT `+pgAPIKeyColumns+` FROM api_keys WHERE id = $1`, id)
+}
+
+// LookupAPIKey returns the key with the given hash.
+func (s *PostgresStore) LookupAPIKey(ctx context.Context, hash []byte) (APIKey, error) {
+	return s.queryAPIKey(ctx, `SELECT `+pgAPIKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash)
+}
+
+func (s *PostgresStore) queryAPIKey(ctx context.Context, query string, arg any) (APIKey, error) {
+	rows, _ := s.pool.Query(ctx, query, arg)
+	key, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[APIKey])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("unable to query api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns the keys of tenantID, or every key when it is empty.
+func (s *PostgresStore) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	rows, _ := s.pool.Query(ctx, `SELECT `+pgAPIKeyColumns+` FROM api_keys
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY created_at, id`, tenantID)
+	keys, err := pgx.CollectRows(rows, pgx.RowToStructByPos[APIKey])
+	if err != nil {
+		return nil, fmt.Errorf("unable to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// ExpireAPIKey sets the key to expire at at, unless it already expires earlier.
+func (s *PostgresStore) ExpireAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateAPIKey(ctx, `UPDATE api_keys SET expires_at = LEAST(expires_at, $2) WHERE id = $1`, id, at)
+}
+
+// RevokeAPIKey marks the key revoked at at, unless it already is.
+func (s *PostgresStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateAPIKey(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, at)
+}
+
+func (s *PostgresStore) updateAPIKey(ctx context.Context, query, id string, at time.Time) error {
+	cmdTag, err := s.pool.Exec(ctx, query, id, at)
+	if err != nil {
+		return fmt.Errorf("unable to update api key: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+const pgSigningSecretColumns = `id, tenant_id, name, secret, created_at, expires_at, revoked_at`
+
+// CreateSigningSecret stores a new signing secret.
+func (s *PostgresStore) CreateSigningSecret(ctx context.Context, secret SigningSecret) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO signing_secrets (`+pgSigningSecretColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		secret.ID, secret.TenantID, secret.Name, secret.Secret, secret.CreatedAt, secret.ExpiresAt, secret.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("unable to insert signing secret: %w", err)
+	}
+	return nil
+}
+
+// GetSigningSecret returns the secret with the given ID.
+func (s *PostgresStore) GetSigningSecret(ctx context.Context, id string) (SigningSecret, error) {
+	rows, _ := s.pool.Query(ctx, `SELECT `+pgSigningSecretColumns+` FROM signing_secrets WHERE id = $1`, id)
+	secret, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[SigningSecret])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SigningSecret{}, ErrSecretNotFound
+	}
+	if err != nil {
+		return SigningSecret{}, fmt.Errorf("unable to query signing secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ListSigningSecrets returns the secrets of tenantID, or every secret when
+// it is empty.
+func (s *PostgresStore) ListSigningSecrets(ctx context.Context, tenantID string) ([]SigningSecret, error) {
+	rows, _ := s.pool.Query(ctx, `SELECT `+pgSigningSecretColumns+` FROM signing_secrets
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY created_at, id`, tenantID)
+	secrets, err := pgx.CollectRows(rows, pgx.RowToStructByPos[SigningSecret])
+	if err != nil {
+		return nil, fmt.Errorf("unable to list signing secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// ExpireSigningSecret sets the secret to expire at at, unless it already
+// expires earlier.
+func (s *PostgresStore) ExpireSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSigningSecret(ctx, `UPDATE signing_secrets SET expires_at = LEAST(expires_at, $2) WHERE id = $1`, id, at)
+}
+
+// RevokeSigningSecret marks the secret revoked at at, unless it already is.
+func (s *PostgresStore) RevokeSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSigningSecret(ctx, `UPDATE signing_secrets SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, at)
+}
+
+func (s *PostgresStore) updateSigningSecret(ctx context.Context, query, id string, at time.Time) error {
+	cmdTag, err := s.pool.Exec(ctx, query, id, at)
+	if err != nil {
+		return fmt.Errorf("unable to update signing secret: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrSecretNotFound
+	}
+	return nil
+}
+
+// AddUsage adds counts to the tenants' totals for day in one statement.
+// Concurrent instances add to the same rows rather than overwriting them.
+func (s *PostgresStore) AddUsage(ctx context.Context, day time.Time, counts map[string]int64) (map[string]int64, error) {
+	tenantIDs := make([]string, 0, len(counts))
+	events := make([]int64, 0, len(counts))
+	for tenantID, n := range counts {
+		tenantIDs = append(tenantIDs, tenantID)
+		events = append(events, n)
+	}
+	rows, _ := s.pool.Query(ctx, `INSERT INTO event_usage (tenant_id, day, events)
+		SELECT tenant_id, $3::date, events FROM unnest($1::varchar[], $2::bigint[]) AS t(tenant_id, events)
+		ON CONFLICT (tenant_id, day) DO UPDATE SET events = event_usage.events + EXCLUDED.events
+		RETURNING tenant_id, events`, tenantIDs, events, day.UTC().Format(time.DateOnly))
+	totals := make(map[string]int64, len(counts))
+	var tenantID string
+	var total int64
+	_, err := pgx.ForEachRow(rows, []any{&tenantID, &total}, func() error {
+		totals[tenantID] = total
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to add usage: %w", err)
+	}
+	return totals, nil
+}
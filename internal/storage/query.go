@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// StoredEvent is an event as read back from storage, including the columns
+// assigned on insert.
+type StoredEvent struct {
+	ID            int64           `json:"id"`
+	EventID       string          `json:"event_id,omitempty"`
+	EventType     string          `json:"event_type"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+	SchemaVersion int             `json:"schema_version,omitempty"`
+	Quarantined   bool            `json:"quarantined"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	TenantID      string          `json:"-"`
+	Source        string          `json:"source,omitempty"`
+	SourceEventID string          `json:"source_event_id,omitempty"`
+	Subject       string          `json:"subject,omitempty"`
+	UserID        string          `json:"user_id,omitempty"`
+	AnonymousID   string          `json:"anonymous_id,omitempty"`
+}
+
+// EventQuery filters and pages events. Zero values leave a filter unset.
+// Results are ordered newest first by (timestamp, id). Only events of
+// TenantID are ever returned.
+type EventQuery struct {
+	TenantID      string
+	EventTypes    []string
+	Since, Until  time.Time // Event timestamp range, [Since, Until)
+	ReceivedSince time.Time // Receive time range, [ReceivedSince, ReceivedUntil)
+	ReceivedUntil time.Time
+	DataContains  json.RawMessage // JSON document that data must contain (JSONB @>)
+	Quarantined   *bool
+	Limit         int
+	After         *Cursor // Resume after this position
+}
+
+// EventPage is one page of query results. NextCursor is nil on the last page.
+type EventPage struct {
+	Events     []StoredEvent
+	NextCursor *Cursor
+}
+
+// Cursor is a keyset position over (timestamp, id).
+type Cursor struct {
+	Timestamp time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode.
+func DecodeCursor(token string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID <= 0 || c.Timestamp.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// AggregateQuery counts events of TenantID per event type and time bucket
+// over [Since, Until).
+type AggregateQuery struct {
+	TenantID     string
+	Bucket       string // date_trunc unit: minute, hour or day
+	Timezone     string // IANA zone that bucket boundaries are aligned to
+	Since, Until time.Time
+	EventTypes   []string
+	DataContains json.RawMessage
+	GroupByPath  []string // Optional path into data whose value is an extra grouping key
+}
+
+// AggregateRow is the event count for one bucket, event type and group.
+type AggregateRow struct {
+	Bucket    time.Time
+	EventType string
+	Group     *string // Value at GroupByPath; nil when not grouping or the path is absent
+	Count     int64
+}
+
+// PurgeFilter selects expired events for deletion.
+type PurgeFilter struct {
+	EventTypes []string  // Types the filter applies to
+	Exclude    bool      // Apply to every type except EventTypes instead
+	Before     time.Time // Delete events with a timestamp before this
+	Limit      int       // Maximum rows deleted per call
+}
+
+// matches reports whether e is selected by f.
+func (f PurgeFilter) matches(e StoredEvent) bool {
+	if !e.Timestamp.Before(f.Before) {
+		return false
+	}
+	return slices.Contains(f.EventTypes, e.EventType) != f.Exclude
+}
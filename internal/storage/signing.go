@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSecretNotFound is returned when no signing secret has an ID.
+var ErrSecretNotFound = errors.New("signing secret not found")
+
+// SigningSecret is a tenant's shared secret for HMAC request signatures.
+// Unlike an API key it is stored as is, since verifying a signature needs
+// the secret itself.
+type SigningSecret struct {
+	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	Name      string     `json:"name,omitempty"`
+	Secret    string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Set when rotated
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the secret verifies signatures at now.
+func (s SigningSecret) Active(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
+
+// SecretStore persists signing secrets.
+type SecretStore interface {
+	CreateSigningSecret(ctx context.Context, secret SigningSecret) error
+	// GetSigningSecret returns ErrSecretNotFound when no secret has id.
+	GetSigningSecret(ctx context.Context, id string) (SigningSecret, error)
+	// ListSigningSecrets returns the secrets of tenantID, or of every
+	// tenant when it is empty, oldest first.
+	ListSigningSecrets(ctx context.Context, tenantID string) ([]SigningSecret, error)
+	// ExpireSigningSecret and RevokeSigningSecret behave like their
+	// KeyStore counterparts and return ErrSecretNotFound for an unknown ID.
+	ExpireSigningSecret(ctx context.Context, id string, at time.Time) error
+	RevokeSigningSecret(ctx context.Context, id string, at time.Time) error
+}
@@ -0,0 +1,695 @@
+//go:build cgo
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func init() {
+	Register("sqlite", func(ctx context.Context, dsn string, obs observability.Provider) (Store, error) {
+		return NewSQLiteStore(ctx, dsn, obs)
+	})
+}
+
+// sqliteSchema mirrors the PostgreSQL migrations in internal/migrate.
+// Timestamps are stored as Unix microseconds so they sort and compare like
+// PostgreSQL's timestamptz.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    event_id TEXT,
+    event_type TEXT NOT NULL,
+    timestamp INTEGER NOT NULL,
+    data TEXT,
+    schema_version INTEGER,
+    quarantined INTEGER NOT NULL DEFAULT 0,
+    received_at INTEGER NOT NULL,
+    source TEXT,
+    source_event_id TEXT,
+    subject TEXT,
+    user_id TEXT,
+    anonymous_id TEXT,
+    UNIQUE (tenant_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_events_timestamp_id ON events(timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_tenant_timestamp_id ON events(tenant_id, timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_tenant_event_type_timestamp_id ON events(tenant_id, event_type, timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_events_received_at ON events(received_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prefix TEXT NOT NULL,
+    key_hash BLOB NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER,
+    revoked_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys(tenant_id);
+
+CREATE TABLE IF NOT EXISTS signing_secrets (
+    id TEXT PRIMARY KEY,
+    tenant_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER,
+    revoked_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_signing_secrets_tenant_id ON signing_secrets(tenant_id);
+
+CREATE TABLE IF NOT EXISTS event_usage (
+    tenant_id TEXT NOT NULL,
+    day TEXT NOT NULL,
+    events INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (tenant_id, day)
+);
+`
+
+// sqliteUpgradeTenants moves an events table created before tenant_id
+// existed aside, to be copied into the current schema. SQLite cannot
+// change the event_id uniqueness constraint in place.
+const sqliteUpgradeTenants = `
+ALTER TABLE events RENAME TO events_pre_tenant;
+DROP INDEX IF EXISTS idx_events_timestamp_id;
+DROP INDEX IF EXISTS idx_events_event_type_timestamp_id;
+DROP INDEX IF EXISTS idx_events_received_at;
+`
+
+// sqliteAddCloudEvents adds the CloudEvents columns to an events table
+// created before they existed.
+const sqliteAddCloudEvents = `
+ALTER TABLE events ADD COLUMN source TEXT;
+ALTER TABLE events ADD COLUMN source_event_id TEXT;
+ALTER TABLE events ADD COLUMN subject TEXT;
+`
+
+// sqliteAddSegmentIDs adds the Segment identity columns to an events table
+// created before they existed.
+const sqliteAddSegmentIDs = `
+ALTER TABLE events ADD COLUMN user_id TEXT;
+ALTER TABLE events ADD COLUMN anonymous_id TEXT;
+`
+
+const sqliteCopyPreTenant = `
+INSERT INTO events (id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at)
+SELECT id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at FROM events_pre_tenant;
+DROP TABLE events_pre_tenant;
+`
+
+// SQLiteStore implements Store on a SQLite database file. JSONB containment
+// and time-zone bucketing are evaluated in process.
+type SQLiteStore struct {
+	db  *sql.DB
+	obs observability.Provider
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite database at dsn, a
+// file path or "file:" URI, and ensures the events table exists.
+func NewSQLiteStore(ctx context.Context, dsn string, obs observability.Provider) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids
+	// "database is locked" errors and keeps ":memory:" databases shared.
+	db.SetMaxOpenConns(1)
+
+	if err := createSQLiteSchema(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create sqlite schema: %w", err)
+	}
+
+	obs.Logger().Info("Opened SQLite database successfully")
+	return &SQLiteStore{db: db, obs: obs}, nil
+}
+
+// createSQLiteSchema creates the schema, first upgrading an events table
+// from before tenants existed; its rows belong to DefaultTenant. Columns
+// added since are added to an existing table.
+func createSQLiteSchema(ctx context.Context, db *sql.DB) error {
+	var upgrade, addCloudEvents, addSegmentIDs bool
+	err := db.QueryRowContext(ctx, `SELECT
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'events')
+		AND NOT EXISTS (SELECT 1 FROM pragma_table_info('events') WHERE name = 'tenant_id'),
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'events')
+		AND NOT EXISTS (SELECT 1 FROM pragma_table_info('events') WHERE name = 'source'),
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'events')
+		AND NOT EXISTS (SELECT 1 FROM pragma_table_info('events') WHERE name = 'user_id')`).Scan(&upgrade, &addCloudEvents, &addSegmentIDs)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	steps := []string{sqliteSchema}
+	switch {
+	case upgrade:
+		// The table is recreated with the current columns.
+		steps = []string{sqliteUpgradeTenants, sqliteSchema, sqliteCopyPreTenant}
+	case addCloudEvents:
+		steps = []string{sqliteAddCloudEvents, sqliteAddSegmentIDs, sqliteSchema}
+	case addSegmentIDs:
+		steps = []string{sqliteAddSegmentIDs, sqliteSchema}
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const sqliteInsert = `INSERT INTO events (event_id, event_type, timestamp, data, schema_version, quarantined, received_at, tenant_id,
+		source, source_event_id, subject, user_id, anonymous_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (tenant_id, event_id) DO NOTHING`
+
+func sqliteInsertArgs(event Event, now time.Time) []any {
+	ts := now
+	if !event.Timestamp.IsZero() {
+		ts = event.Timestamp
+	}
+	var data any
+	if len(event.Data) > 0 {
+		data = string(event.Data)
+	}
+	return []any{
+		nullIfEmpty(event.EventID), event.EventType, ts.UnixMicro(), data,
+		nullIfZero(event.SchemaVersion), event.Quarantined, now.UnixMicro(), event.TenantID,
+		nullIfEmpty(event.Source), nullIfEmpty(event.SourceEventID), nullIfEmpty(event.Subject),
+		nullIfEmpty(event.UserID), nullIfEmpty(event.AnonymousID),
+	}
+}
+
+// StoreEvent inserts an event into the database.
+func (s *SQLiteStore) StoreEvent(ctx context.Context, event Event) error {
+	ctx, span := s.obs.Tracer().Start(ctx, "StoreEvent")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, sqliteInsert, sqliteInsertArgs(event, time.Now())...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB insert failed")
+		return fmt.Errorf("unable to insert event: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 && event.EventID != "" {
+		span.SetAttributes(attribute.Bool("duplicate", true))
+		return ErrDuplicateEvent
+	}
+	return nil
+}
+
+// StoreEvents inserts a batch in one transaction, skipping duplicate EventIDs.
+func (s *SQLiteStore) StoreEvents(ctx context.Context, events []Event) ([]int, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "StoreEvents")
+	defer span.End()
+
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	var duplicates []int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, sqliteInsert)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		now := time.Now()
+		for i, event := range events {
+			res, err := stmt.ExecContext(ctx, sqliteInsertArgs(event, now)...)
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				duplicates = append(duplicates, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB batch insert failed")
+		return nil, fmt.Errorf("unable to insert events: %w", err)
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch_size", len(events)),
+		attribute.Int("duplicates", len(duplicates)),
+	)
+	return duplicates, nil
+}
+
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// QueryEvents returns one page of events matching q, newest first.
+func (s *SQLiteStore) QueryEvents(ctx context.Context, q EventQuery) (EventPage, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "QueryEvents")
+	defer span.End()
+
+	contains, err := decodeContains(q.DataContains)
+	if err != nil {
+		return EventPage{}, err
+	}
+
+	where, args := sqliteFilters(q.TenantID, q.EventTypes, q.Since, q.Until)
+	if !q.ReceivedSince.IsZero() {
+		where = append(where, "received_at >= ?")
+		args = append(args, q.ReceivedSince.UnixMicro())
+	}
+	if !q.ReceivedUntil.IsZero() {
+		where = append(where, "received_at < ?")
+		args = append(args, q.ReceivedUntil.UnixMicro())
+	}
+	if q.Quarantined != nil {
+		where = append(where, "quarantined = ?")
+		args = append(args, *q.Quarantined)
+	}
+	if q.After != nil {
+		where = append(where, "(timestamp, id) < (?, ?)")
+		args = append(args, q.After.Timestamp.UnixMicro(), q.After.ID)
+	}
+	query := sqliteSelect(where) + " ORDER BY timestamp DESC, id DESC"
+	// Without a data filter every row matches, so the database can apply the
+	// limit; one extra row tells whether another page follows.
+	if contains == nil {
+		query += fmt.Sprintf(" LIMIT %d", q.Limit+1)
+	}
+
+	page := EventPage{Events: make([]StoredEvent, 0, q.Limit)}
+	err = s.scanEvents(ctx, query, args, func(e StoredEvent) bool {
+		if contains != nil && !jsonContains(decodeJSON(e.Data), contains) {
+			return true
+		}
+		page.Events = append(page.Events, e)
+		return len(page.Events) <= q.Limit
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB query failed")
+		return EventPage{}, fmt.Errorf("unable to query events: %w", err)
+	}
+
+	if len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		last := page.Events[q.Limit-1]
+		page.NextCursor = &Cursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	span.SetAttributes(attribute.Int("result_count", len(page.Events)))
+	return page, nil
+}
+
+// AggregateEvents counts events grouped by event type and time bucket.
+func (s *SQLiteStore) AggregateEvents(ctx context.Context, q AggregateQuery) ([]AggregateRow, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "AggregateEvents")
+	defer span.End()
+
+	agg, err := newAggregation(q)
+	if err != nil {
+		return nil, err
+	}
+	where, args := sqliteFilters(q.TenantID, q.EventTypes, q.Since, q.Until)
+	err = s.scanEvents(ctx, sqliteSelect(where), args, func(e StoredEvent) bool {
+		agg.add(e)
+		return true
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB aggregate failed")
+		return nil, fmt.Errorf("unable to aggregate events: %w", err)
+	}
+	rows := agg.rows()
+	span.SetAttributes(attribute.String("bucket", q.Bucket), attribute.Int("result_count", len(rows)))
+	return rows, nil
+}
+
+// PurgeEvents deletes up to f.Limit events matching f.
+func (s *SQLiteStore) PurgeEvents(ctx context.Context, f PurgeFilter) (int64, error) {
+	ctx, span := s.obs.Tracer().Start(ctx, "PurgeEvents")
+	defer span.End()
+
+	where := "timestamp < ?"
+	args := []any{f.Before.UnixMicro()}
+	if len(f.EventTypes) > 0 {
+		op := "IN"
+		if f.Exclude {
+			op = "NOT IN"
+		}
+		where += " AND event_type " + op + " (?" + strings.Repeat(", ?", len(f.EventTypes)-1) + ")"
+		for _, t := range f.EventTypes {
+			args = append(args, t)
+		}
+	} else if !f.Exclude {
+		return 0, nil
+	}
+	args = append(args, f.Limit)
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM events WHERE id IN (SELECT id FROM events WHERE `+where+` LIMIT ?)`, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "DB purge failed")
+		return 0, fmt.Errorf("unable to purge events: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	span.SetAttributes(attribute.Int64("purged", n))
+	return n, nil
+}
+
+// DropPartitionsBefore is a no-op; SQLite tables are not partitioned.
+func (s *SQLiteStore) DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// PurgeEventIDs is a no-op; SQLite deduplicates on the events table, so
+// purging an event frees its ID.
+func (s *SQLiteStore) PurgeEventIDs(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+// sqliteFilters builds the tenant, event type and timestamp range
+// conditions shared by queries and aggregations.
+func sqliteFilters(tenantID string, eventTypes []string, since, until time.Time) ([]string, []any) {
+	where := []string{"tenant_id = ?"}
+	args := []any{tenantID}
+	if len(eventTypes) > 0 {
+		where = append(where, "event_type IN (?"+strings.Repeat(", ?", len(eventTypes)-1)+")")
+		for _, t := range eventTypes {
+			args = append(args, t)
+		}
+	}
+	if !since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, since.UnixMicro())
+	}
+	if !until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, until.UnixMicro())
+	}
+	return where, args
+}
+
+func sqliteSelect(where []string) string {
+	return `SELECT id, event_id, event_type, timestamp, data, schema_version, quarantined, received_at, tenant_id,
+		source, source_event_id, subject, user_id, anonymous_id FROM events
+		WHERE ` + strings.Join(where, " AND ")
+}
+
+// scanEvents runs query and passes each row to fn until fn returns false.
+func (s *SQLiteStore) scanEvents(ctx context.Context, query string, args []any, fn func(StoredEvent) bool) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(queryCtx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e                              StoredEvent
+			eventID, data                  sql.NullString
+			source, sourceEventID, subject sql.NullString
+			userID, anonymousID            sql.NullString
+			schemaVersion                  sql.NullInt64
+			ts, receivedAt                 int64
+		)
+		if err := rows.Scan(&e.ID, &eventID, &e.EventType, &ts, &data, &schemaVersion, &e.Quarantined, &receivedAt, &e.TenantID,
+			&source, &sourceEventID, &subject, &userID, &anonymousID); err != nil {
+			return err
+		}
+		e.EventID = eventID.String
+		e.Source = source.String
+		e.SourceEventID = sourceEventID.String
+		e.Subject = subject.String
+		e.UserID = userID.String
+		e.AnonymousID = anonymousID.String
+		e.Timestamp = time.UnixMicro(ts).UTC()
+		if data.Valid {
+			e.Data = []byte(data.String)
+		}
+		e.SchemaVersion = int(schemaVersion.Int64)
+		e.ReceivedAt = time.UnixMicro(receivedAt).UTC()
+		if !fn(e) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+const sqliteAPIKeyColumns = `id, tenant_id, name, prefix, key_hash, created_at, expires_at, revoked_at`
+
+// CreateAPIKey stores a new API key.
+func (s *SQLiteStore) CreateAPIKey(ctx context.Context, key APIKey) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO api_keys (`+sqliteAPIKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.TenantID, key.Name, key.Prefix, key.Hash, key.CreatedAt.UnixMicro(),
+		unixMicroOrNil(key.ExpiresAt), unixMicroOrNil(key.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("unable to insert api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns the key with the given ID.
+func (s *SQLiteStore) GetAPIKey(ctx context.Context, id string) (APIKey, error) {
+	return s.queryAPIKey(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys WHERE id = ?`, id)
+}
+
+// LookupAPIKey returns the key with the given hash.
+func (s *SQLiteStore) LookupAPIKey(ctx context.Context, hash []byte) (APIKey, error) {
+	return s.queryAPIKey(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash)
+}
+
+func (s *SQLiteStore) queryAPIKey(ctx context.Context, query string, arg any) (APIKey, error) {
+	keys, err := s.scanAPIKeys(ctx, query, arg)
+	if err != nil {
+		return APIKey{}, fmt.Errorf("unable to query api key: %w", err)
+	}
+	if len(keys) == 0 {
+		return APIKey{}, ErrKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// ListAPIKeys returns the keys of tenantID, or every key when it is empty.
+func (s *SQLiteStore) ListAPIKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	keys, err := s.scanAPIKeys(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys
+		WHERE ?1 = '' OR tenant_id = ?1
+		ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *SQLiteStore) scanAPIKeys(ctx context.Context, query string, args ...any) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var (
+			k                    APIKey
+			createdAt            int64
+			expiresAt, revokedAt sql.NullInt64
+		)
+		if err := rows.Scan(&k.ID, &k.TenantID, &k.Name, &k.Prefix, &k.Hash, &createdAt, &expiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		k.CreatedAt = time.UnixMicro(createdAt).UTC()
+		k.ExpiresAt = timeOrNil(expiresAt)
+		k.RevokedAt = timeOrNil(revokedAt)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// ExpireAPIKey sets the key to expire at at, unless it already expires earlier.
+func (s *SQLiteStore) ExpireAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateAPIKey(ctx, `UPDATE api_keys SET expires_at = min(coalesce(expires_at, ?2), ?2) WHERE id = ?1`, id, at)
+}
+
+// RevokeAPIKey marks the key revoked at at, unless it already is.
+func (s *SQLiteStore) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.updateAPIKey(ctx, `UPDATE api_keys SET revoked_at = coalesce(revoked_at, ?2) WHERE id = ?1`, id, at)
+}
+
+func (s *SQLiteStore) updateAPIKey(ctx context.Context, query, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, query, id, at.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("unable to update api key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func unixMicroOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixMicro()
+}
+
+func timeOrNil(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.UnixMicro(n.Int64).UTC()
+	return &t
+}
+
+const sqliteSigningSecretColumns = `id, tenant_id, name, secret, created_at, expires_at, revoked_at`
+
+// CreateSigningSecret stores a new signing secret.
+func (s *SQLiteStore) CreateSigningSecret(ctx context.Context, secret SigningSecret) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO signing_secrets (`+sqliteSigningSecretColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		secret.ID, secret.TenantID, secret.Name, secret.Secret, secret.CreatedAt.UnixMicro(),
+		unixMicroOrNil(secret.ExpiresAt), unixMicroOrNil(secret.RevokedAt))
+	if err != nil {
+		return fmt.Errorf("unable to insert signing secret: %w", err)
+	}
+	return nil
+}
+
+// GetSigningSecret returns the secret with the given ID.
+func (s *SQLiteStore) GetSigningSecret(ctx context.Context, id string) (SigningSecret, error) {
+	secrets, err := s.scanSigningSecrets(ctx, `SELECT `+sqliteSigningSecretColumns+` FROM signing_secrets WHERE id = ?`, id)
+	if err != nil {
+		return SigningSecret{}, fmt.Errorf("unable to query signing secret: %w", err)
+	}
+	if len(secrets) == 0 {
+		return SigningSecret{}, ErrSecretNotFound
+	}
+	return secrets[0], nil
+}
+
+// ListSigningSecrets returns the secrets of tenantID, or every secret when
+// it is empty.
+func (s *SQLiteStore) ListSigningSecrets(ctx context.Context, tenantID string) ([]SigningSecret, error) {
+	secrets, err := s.scanSigningSecrets(ctx, `SELECT `+sqliteSigningSecretColumns+` FROM signing_secrets
+		WHERE ?1 = '' OR tenant_id = ?1
+		ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list signing secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+func (s *SQLiteStore) scanSigningSecrets(ctx context.Context, query string, args ...any) ([]SigningSecret, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []SigningSecret
+	for rows.Next() {
+		var (
+			sec                  SigningSecret
+			createdAt            int64
+			expiresAt, revokedAt sql.NullInt64
+		)
+		if err := rows.Scan(&sec.ID, &sec.TenantID, &sec.Name, &sec.Secret, &createdAt, &expiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		sec.CreatedAt = time.UnixMicro(createdAt).UTC()
+		sec.ExpiresAt = timeOrNil(expiresAt)
+		sec.RevokedAt = timeOrNil(revokedAt)
+		secrets = append(secrets, sec)
+	}
+	return secrets, rows.Err()
+}
+
+// ExpireSigningSecret sets the secret to expire at at, unless it already
+// expires earlier.
+func (s *SQLiteStore) ExpireSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSigningSecret(ctx, `UPDATE signing_secrets SET expires_at = min(coalesce(expires_at, ?2), ?2) WHERE id = ?1`, id, at)
+}
+
+// RevokeSigningSecret marks the secret revoked at at, unless it already is.
+func (s *SQLiteStore) RevokeSigningSecret(ctx context.Context, id string, at time.Time) error {
+	return s.updateSigningSecret(ctx, `UPDATE signing_secrets SET revoked_at = coalesce(revoked_at, ?2) WHERE id = ?1`, id, at)
+}
+
+func (s *SQLiteStore) updateSigningSecret(ctx context.Context, query, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, query, id, at.UnixMicro())
+	if err != nil {
+		return fmt.Errorf("unable to update signing secret: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSecretNotFound
+	}
+	return nil
+}
+
+// AddUsage adds counts to the tenants' totals for day in one transaction.
+func (s *SQLiteStore) AddUsage(ctx context.Context, day time.Time, counts map[string]int64) (map[string]int64, error) {
+	totals := make(map[string]int64, len(counts))
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO event_usage (tenant_id, day, events) VALUES (?, ?, ?)
+			ON CONFLICT (tenant_id, day) DO UPDATE SET events = events + excluded.events
+			RETURNING events`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for tenantID, n := range counts {
+			var total int64
+			if err := stmt.QueryRowContext(ctx, tenantID, day.UTC().Format(time.DateOnly), n).Scan(&total); err != nil {
+				return err
+			}
+			totals[tenantID] = total
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to add usage: %w", err)
+	}
+	return totals, nil
+}
+
+// Ping verifies the database file is still accessible.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() {
+	s.obs.Logger().Info("Closing SQLite database")
+	if err := s.db.Close(); err != nil {
+		s.obs.Logger().Error("Failed to close SQLite database", "error", err)
+	}
+}
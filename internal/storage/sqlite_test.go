@@ -0,0 +1,107 @@
+//go:build cgo
+
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	obs, _ := observability.InitObservability("noop")
+	testStore(t, func(t *testing.T) storage.Store {
+		s, err := storage.Open(context.Background(), "sqlite", filepath.Join(t.TempDir(), "events.db"), obs)
+		be.NilErr(t, err)
+		t.Cleanup(s.Close)
+		return s
+	})
+}
+
+func TestSQLiteStore_UpgradeTenants(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	// The schema as it was before tenants existed.
+	db, err := sql.Open("sqlite3", path)
+	be.NilErr(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id TEXT UNIQUE,
+			event_type TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			data TEXT,
+			schema_version INTEGER,
+			quarantined INTEGER NOT NULL DEFAULT 0,
+			received_at INTEGER NOT NULL
+		);
+		CREATE INDEX idx_events_timestamp_id ON events(timestamp DESC, id DESC);
+		INSERT INTO events (event_id, event_type, timestamp, received_at)
+		VALUES ('01ARZ3NDEKTSV4RRFFQ69G5FAV', 'login', 1711620000000000, 1711620000000000);`)
+	be.NilErr(t, err)
+	be.NilErr(t, db.Close())
+
+	obs, _ := observability.InitObservability("noop")
+	s, err := storage.Open(ctx, "sqlite", path, obs)
+	be.NilErr(t, err)
+	t.Cleanup(s.Close)
+
+	page, err := s.QueryEvents(ctx, storage.EventQuery{TenantID: storage.DefaultTenant, Limit: 10})
+	be.NilErr(t, err)
+	be.Equal(t, 1, len(page.Events))
+	be.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", page.Events[0].EventID)
+
+	// Event IDs are now unique per tenant only.
+	be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", EventType: "login", TenantID: "acme"}))
+	err = s.StoreEvent(ctx, storage.Event{EventID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", EventType: "login", TenantID: storage.DefaultTenant})
+	be.True(t, errors.Is(err, storage.ErrDuplicateEvent))
+}
+
+func TestSQLiteStore_AddCloudEventsColumns(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	// The events table as it was before CloudEvents support.
+	db, err := sql.Open("sqlite3", path)
+	be.NilErr(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tenant_id TEXT NOT NULL DEFAULT 'default',
+			event_id TEXT,
+			event_type TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			data TEXT,
+			schema_version INTEGER,
+			quarantined INTEGER NOT NULL DEFAULT 0,
+			received_at INTEGER NOT NULL,
+			UNIQUE (tenant_id, event_id)
+		);
+		INSERT INTO events (event_type, timestamp, received_at) VALUES ('login', 1711620000000000, 1711620000000000);`)
+	be.NilErr(t, err)
+	be.NilErr(t, db.Close())
+
+	obs, _ := observability.InitObservability("noop")
+	s, err := storage.Open(ctx, "sqlite", path, obs)
+	be.NilErr(t, err)
+	t.Cleanup(s.Close)
+
+	be.NilErr(t, s.StoreEvent(ctx, storage.Event{
+		EventType: "order.created", Timestamp: time.UnixMicro(1711620060000000), TenantID: storage.DefaultTenant, Source: "/billing",
+		UserID: "u-42",
+	}))
+	page, err := s.QueryEvents(ctx, storage.EventQuery{TenantID: storage.DefaultTenant, Limit: 10})
+	be.NilErr(t, err)
+	be.Equal(t, 2, len(page.Events))
+	be.Equal(t, "/billing", page.Events[0].Source)
+	be.Equal(t, "u-42", page.Events[0].UserID)
+	be.Equal(t, "", page.Events[1].Source)
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kakhavain/telemetry-tracker/internal/observability"
+)
+
+// Writer stores events.
+type Writer interface {
+	// StoreEvent stores a single event. It returns ErrDuplicateEvent when an
+	// event with the same EventID is already stored.
+	StoreEvent(ctx context.Context, event Event) error
+	// StoreEvents stores a batch atomically, skipping duplicate EventIDs,
+	// and returns the indexes of the events it skipped.
+	StoreEvents(ctx context.Context, events []Event) (duplicates []int, err error)
+}
+
+// Reader reads stored events back.
+type Reader interface {
+	QueryEvents(ctx context.Context, q EventQuery) (EventPage, error)
+	AggregateEvents(ctx context.Context, q AggregateQuery) ([]AggregateRow, error)
+}
+
+// Purger deletes expired events.
+type Purger interface {
+	// PurgeEvents deletes up to f.Limit events matching f and returns how
+	// many were deleted.
+	PurgeEvents(ctx context.Context, f PurgeFilter) (int64, error)
+	// DropPartitionsBefore drops whole partitions that end at or before
+	// cutoff, returning the rows they held. Backends without partitions
+	// return zero.
+	DropPartitionsBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	// PurgeEventIDs deletes up to limit event ID deduplication records
+	// created before cutoff and returns how many were deleted. Backends
+	// that deduplicate on the events themselves return zero.
+	PurgeEventIDs(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+}
+
+// Store is a storage backend.
+type Store interface {
+	Writer
+	Reader
+	Purger
+	KeyStore
+	SecretStore
+	UsageStore
+	// Ping reports whether the backend is reachable.
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// Driver opens a Store from a backend-specific data source name.
+type Driver func(ctx context.Context, dsn string, obs observability.Provider) (Store, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a storage driver available by name. It panics if the name
+// is registered twice, since that is a programming error.
+func Register(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := drivers[name]; dup {
+		panic("storage: Register called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns the names of the registered drivers, sorted.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens a Store with the named driver.
+func Open(ctx context.Context, driver, dsn string, obs observability.Provider) (Store, error) {
+	driversMu.RLock()
+	open, ok := drivers[driver]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver %q (available: %s)", driver, strings.Join(Drivers(), ", "))
+	}
+	return open(ctx, dsn, obs)
+}
@@ -0,0 +1,340 @@
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carlmjohnson/be"
+	"github.com/kakhavain/telemetry-tracker/internal/storage"
+)
+
+// testStore runs the behaviour every backend must share against a fresh store.
+func testStore(t *testing.T, open func(t *testing.T) storage.Store) {
+	ctx := context.Background()
+	base := time.Date(2024, 3, 28, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	seed := func(t *testing.T, s storage.Store) {
+		_, err := s.StoreEvents(ctx, []storage.Event{
+			{EventType: "click", Timestamp: at(0), Data: json.RawMessage(`{"platform":"ios","tags":["a","b"]}`)},
+			{EventType: "click", Timestamp: at(30), Data: json.RawMessage(`{"platform":"android"}`)},
+			{EventType: "view", Timestamp: at(45), Data: json.RawMessage(`{"platform":"ios","page":{"path":"/"}}`)},
+			{EventType: "click", Timestamp: at(90), Data: json.RawMessage(`{"platform":"ios","count":2}`)},
+			{EventType: "view", Timestamp: at(90), Quarantined: true},
+		})
+		be.NilErr(t, err)
+	}
+
+	t.Run("Ping", func(t *testing.T) {
+		be.NilErr(t, open(t).Ping(ctx))
+	})
+
+	t.Run("Duplicate event ID", func(t *testing.T) {
+		s := open(t)
+		event := storage.Event{EventID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", EventType: "login", Timestamp: base}
+		be.NilErr(t, s.StoreEvent(ctx, event))
+		be.True(t, errors.Is(s.StoreEvent(ctx, event), storage.ErrDuplicateEvent))
+		// Only the repeated IDs are skipped, including a repeat within the batch.
+		duplicates, err := s.StoreEvents(ctx, []storage.Event{event, {EventType: "login", Timestamp: base}, event})
+		be.NilErr(t, err)
+		be.AllEqual(t, []int{0, 2}, duplicates)
+
+		page, err := s.QueryEvents(ctx, storage.EventQuery{Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(page.Events))
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		s := open(t)
+		seed(t, s)
+		yes := true
+
+		tests := []struct {
+			name     string
+			q        storage.EventQuery
+			expected []string
+		}{
+			{"All newest first", storage.EventQuery{}, []string{"view", "click", "view", "click", "click"}},
+			{"Event type", storage.EventQuery{EventTypes: []string{"view"}}, []string{"view", "view"}},
+			{"Time range", storage.EventQuery{Since: at(30), Until: at(90)}, []string{"view", "click"}},
+			{"Containment", storage.EventQuery{DataContains: json.RawMessage(`{"platform":"ios"}`)}, []string{"click", "view", "click"}},
+			{"Nested containment", storage.EventQuery{DataContains: json.RawMessage(`{"page":{"path":"/"}}`)}, []string{"view"}},
+			{"Array containment", storage.EventQuery{DataContains: json.RawMessage(`{"tags":["b"]}`)}, []string{"click"}},
+			{"Quarantined", storage.EventQuery{Quarantined: &yes}, []string{"view"}},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.q.Limit = 10
+				page, err := s.QueryEvents(ctx, tc.q)
+				be.NilErr(t, err)
+				var got []string
+				for _, e := range page.Events {
+					got = append(got, e.EventType)
+				}
+				be.AllEqual(t, tc.expected, got)
+				be.True(t, page.NextCursor == nil)
+			})
+		}
+	})
+
+	t.Run("Cursor pagination", func(t *testing.T) {
+		s := open(t)
+		seed(t, s)
+
+		var ids []int64
+		q := storage.EventQuery{Limit: 2}
+		for pages := 0; ; pages++ {
+			be.True(t, pages < 3)
+			page, err := s.QueryEvents(ctx, q)
+			be.NilErr(t, err)
+			for _, e := range page.Events {
+				ids = append(ids, e.ID)
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			// Events inserted after paging starts must not shift later pages.
+			be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: "late", Timestamp: at(120)}))
+			q.After = page.NextCursor
+		}
+		be.Equal(t, 5, len(ids))
+		seen := make(map[int64]bool)
+		for _, id := range ids {
+			be.False(t, seen[id])
+			seen[id] = true
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		s := open(t)
+		seed(t, s)
+
+		n, err := s.PurgeEvents(ctx, storage.PurgeFilter{EventTypes: []string{"click"}, Before: at(60), Limit: 1})
+		be.NilErr(t, err)
+		be.Equal(t, int64(1), n)
+		n, err = s.PurgeEvents(ctx, storage.PurgeFilter{EventTypes: []string{"click"}, Before: at(60), Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, int64(1), n)
+		n, err = s.PurgeEvents(ctx, storage.PurgeFilter{EventTypes: []string{"click"}, Exclude: true, Before: at(90), Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, int64(1), n)
+
+		page, err := s.QueryEvents(ctx, storage.EventQuery{Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(page.Events))
+		for _, e := range page.Events {
+			be.True(t, e.Timestamp.Equal(at(90)))
+		}
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		s := open(t)
+		seed(t, s)
+
+		rows, err := s.AggregateEvents(ctx, storage.AggregateQuery{
+			Bucket:      "hour",
+			Timezone:    "Asia/Kolkata", // UTC+05:30, so hours align on :30 UTC
+			Since:       at(0),
+			Until:       at(120),
+			EventTypes:  []string{"click"},
+			GroupByPath: []string{"platform"},
+		})
+		be.NilErr(t, err)
+		be.Equal(t, 3, len(rows))
+
+		be.True(t, rows[0].Bucket.Equal(at(-30)))
+		be.Equal(t, "ios", *rows[0].Group)
+		be.Equal(t, int64(1), rows[0].Count)
+
+		be.True(t, rows[1].Bucket.Equal(at(30)))
+		be.Equal(t, "android", *rows[1].Group)
+		be.True(t, rows[2].Bucket.Equal(at(90)))
+		be.Equal(t, "ios", *rows[2].Group)
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		s := open(t)
+		const eventID = "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+		be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventID: eventID, EventType: "login", Timestamp: at(0), TenantID: "acme"}))
+		// The same event ID from another tenant is not a duplicate.
+		be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventID: eventID, EventType: "login", Timestamp: at(0), TenantID: "globex"}))
+		duplicates, err := s.StoreEvents(ctx, []storage.Event{
+			{EventID: eventID, EventType: "login", Timestamp: at(0), TenantID: "acme"},
+			{EventType: "click", Timestamp: at(5), TenantID: "acme"},
+			{EventType: "click", Timestamp: at(5), TenantID: "globex"},
+		})
+		be.NilErr(t, err)
+		be.AllEqual(t, []int{0}, duplicates)
+
+		page, err := s.QueryEvents(ctx, storage.EventQuery{TenantID: "acme", Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(page.Events))
+		for _, e := range page.Events {
+			be.Equal(t, "acme", e.TenantID)
+		}
+		page, err = s.QueryEvents(ctx, storage.EventQuery{TenantID: "initech", Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 0, len(page.Events))
+
+		rows, err := s.AggregateEvents(ctx, storage.AggregateQuery{
+			TenantID: "globex", Bucket: "hour", Timezone: "UTC", Since: at(0), Until: at(60),
+		})
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(rows))
+		be.Equal(t, int64(1), rows[0].Count)
+		be.Equal(t, int64(1), rows[1].Count)
+	})
+
+	t.Run("CloudEvents attributes", func(t *testing.T) {
+		s := open(t)
+		be.NilErr(t, s.StoreEvent(ctx, storage.Event{
+			EventType: "order.created", Timestamp: at(0),
+			Source: "/billing", SourceEventID: "A234-1234", Subject: "orders/42",
+		}))
+		_, err := s.StoreEvents(ctx, []storage.Event{
+			{EventType: "order.paid", Timestamp: at(5), Source: "/billing", SourceEventID: "A234-1235"},
+			{EventType: "click", Timestamp: at(10)},
+		})
+		be.NilErr(t, err)
+
+		page, err := s.QueryEvents(ctx, storage.EventQuery{Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 3, len(page.Events))
+		be.Equal(t, "", page.Events[0].Source)
+		be.Equal(t, "/billing", page.Events[1].Source)
+		be.Equal(t, "A234-1235", page.Events[1].SourceEventID)
+		be.Equal(t, "", page.Events[1].Subject)
+		be.Equal(t, "A234-1234", page.Events[2].SourceEventID)
+		be.Equal(t, "orders/42", page.Events[2].Subject)
+	})
+
+	t.Run("Segment identities", func(t *testing.T) {
+		s := open(t)
+		be.NilErr(t, s.StoreEvent(ctx, storage.Event{EventType: "Order Completed", Timestamp: at(0), UserID: "u-42"}))
+		_, err := s.StoreEvents(ctx, []storage.Event{
+			{EventType: "page", Timestamp: at(5), AnonymousID: "a-7"},
+			{EventType: "identify", Timestamp: at(10), UserID: "u-42", AnonymousID: "a-7"},
+		})
+		be.NilErr(t, err)
+
+		page, err := s.QueryEvents(ctx, storage.EventQuery{Limit: 10})
+		be.NilErr(t, err)
+		be.Equal(t, 3, len(page.Events))
+		be.Equal(t, "u-42", page.Events[0].UserID)
+		be.Equal(t, "a-7", page.Events[0].AnonymousID)
+		be.Equal(t, "", page.Events[1].UserID)
+		be.Equal(t, "a-7", page.Events[1].AnonymousID)
+		be.Equal(t, "u-42", page.Events[2].UserID)
+		be.Equal(t, "", page.Events[2].AnonymousID)
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		s := open(t)
+		key := storage.APIKey{
+			ID: "8b1c6f8e-5a57-4c56-9d53-2f8c2a7d0e11", TenantID: "acme", Name: "ios", Prefix: "tt_abcdefgh",
+			Hash: []byte{1, 2, 3}, CreatedAt: base,
+		}
+		be.NilErr(t, s.CreateAPIKey(ctx, key))
+		be.NilErr(t, s.CreateAPIKey(ctx, storage.APIKey{
+			ID: "0d9e0e8c-8f3b-4a7e-a1f4-3c1b8f0f5d22", TenantID: "globex", Prefix: "tt_ijklmnop",
+			Hash: []byte{4, 5, 6}, CreatedAt: at(1),
+		}))
+
+		got, err := s.LookupAPIKey(ctx, []byte{1, 2, 3})
+		be.NilErr(t, err)
+		be.Equal(t, key.ID, got.ID)
+		be.Equal(t, "acme", got.TenantID)
+		be.True(t, got.CreatedAt.Equal(base))
+		be.True(t, got.ExpiresAt == nil && got.RevokedAt == nil)
+		_, err = s.LookupAPIKey(ctx, []byte{9})
+		be.True(t, errors.Is(err, storage.ErrKeyNotFound))
+
+		all, err := s.ListAPIKeys(ctx, "")
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(all))
+		be.Equal(t, key.ID, all[0].ID)
+		acme, err := s.ListAPIKeys(ctx, "acme")
+		be.NilErr(t, err)
+		be.Equal(t, 1, len(acme))
+
+		// An expiry only ever moves earlier, and revocation keeps its first time.
+		be.NilErr(t, s.ExpireAPIKey(ctx, key.ID, at(60)))
+		be.NilErr(t, s.ExpireAPIKey(ctx, key.ID, at(120)))
+		be.NilErr(t, s.RevokeAPIKey(ctx, key.ID, at(10)))
+		be.NilErr(t, s.RevokeAPIKey(ctx, key.ID, at(20)))
+		got, err = s.GetAPIKey(ctx, key.ID)
+		be.NilErr(t, err)
+		be.True(t, got.ExpiresAt.Equal(at(60)))
+		be.True(t, got.RevokedAt.Equal(at(10)))
+		be.False(t, got.Active(at(5)))
+
+		be.True(t, errors.Is(s.RevokeAPIKey(ctx, "missing", base), storage.ErrKeyNotFound))
+		_, err = s.GetAPIKey(ctx, "missing")
+		be.True(t, errors.Is(err, storage.ErrKeyNotFound))
+	})
+
+	t.Run("Signing secrets", func(t *testing.T) {
+		s := open(t)
+		secret := storage.SigningSecret{
+			ID: "3f2b8c1e-7d4a-4e6b-9a5c-1b2d3e4f5a66", TenantID: "acme", Name: "billing", Secret: "tts_one", CreatedAt: base,
+		}
+		be.NilErr(t, s.CreateSigningSecret(ctx, secret))
+		be.NilErr(t, s.CreateSigningSecret(ctx, storage.SigningSecret{
+			ID: "9c8d7e6f-5a4b-4c3d-8e2f-1a0b9c8d7e77", TenantID: "globex", Secret: "tts_two", CreatedAt: at(1),
+		}))
+
+		got, err := s.GetSigningSecret(ctx, secret.ID)
+		be.NilErr(t, err)
+		be.Equal(t, "tts_one", got.Secret)
+		be.True(t, got.CreatedAt.Equal(base))
+		acme, err := s.ListSigningSecrets(ctx, "acme")
+		be.NilErr(t, err)
+		be.Equal(t, 1, len(acme))
+		all, err := s.ListSigningSecrets(ctx, "")
+		be.NilErr(t, err)
+		be.Equal(t, 2, len(all))
+
+		be.NilErr(t, s.ExpireSigningSecret(ctx, secret.ID, at(60)))
+		be.NilErr(t, s.ExpireSigningSecret(ctx, secret.ID, at(120)))
+		be.NilErr(t, s.RevokeSigningSecret(ctx, secret.ID, at(10)))
+		be.NilErr(t, s.RevokeSigningSecret(ctx, secret.ID, at(20)))
+		got, err = s.GetSigningSecret(ctx, secret.ID)
+		be.NilErr(t, err)
+		be.True(t, got.ExpiresAt.Equal(at(60)))
+		be.True(t, got.RevokedAt.Equal(at(10)))
+
+		be.True(t, errors.Is(s.ExpireSigningSecret(ctx, "missing", base), storage.ErrSecretNotFound))
+		_, err = s.GetSigningSecret(ctx, "missing")
+		be.True(t, errors.Is(err, storage.ErrSecretNotFound))
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		s := open(t)
+		totals, err := s.AddUsage(ctx, base, map[string]int64{"acme": 5, "globex": 1})
+		be.NilErr(t, err)
+		be.Equal(t, int64(5), totals["acme"])
+		// Any time on the same UTC day counts towards the same total.
+		totals, err = s.AddUsage(ctx, at(600), map[string]int64{"acme": 2, "globex": 0})
+		be.NilErr(t, err)
+		be.Equal(t, int64(7), totals["acme"])
+		be.Equal(t, int64(1), totals["globex"])
+		totals, err = s.AddUsage(ctx, base.AddDate(0, 0, 1), map[string]int64{"acme": 0})
+		be.NilErr(t, err)
+		be.Equal(t, int64(0), totals["acme"])
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
+
+func TestOpenUnknownDriver(t *testing.T) {
+	_, err := storage.Open(context.Background(), "oracle", "", nil)
+	be.Nonzero(t, err)
+	be.In(t, "memory", err.Error())
+}
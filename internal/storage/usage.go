@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// UsageStore keeps per-tenant counts of events accepted each UTC day, which
+// daily quotas are enforced against.
+type UsageStore interface {
+	// AddUsage adds counts to the tenants' totals for the day containing
+	// day and returns the new totals. A zero count reads a total back.
+	AddUsage(ctx context.Context, day time.Time, counts map[string]int64) (map[string]int64, error)
+}